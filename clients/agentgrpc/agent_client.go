@@ -22,6 +22,9 @@ const (
 	MethodInitialize    Method = "/network.forta.Agent/Initialize"
 	MethodEvaluateTx    Method = "/network.forta.Agent/EvaluateTx"
 	MethodEvaluateBlock Method = "/network.forta.Agent/EvaluateBlock"
+	// MethodEvaluateAlert takes a NotifyRequest with the alert and its source event,
+	// and it returns findings just like EvaluateTxResponse.
+	MethodEvaluateAlert Method = "/network.forta.Agent/EvaluateAlert"
 )
 
 // Client allows us to communicate with an agent.
@@ -37,13 +40,18 @@ func NewClient() *Client {
 
 // Dial dials an agent using the config.
 func (client *Client) Dial(cfg config.AgentConfig) error {
+	return client.DialAddr(cfg, fmt.Sprintf("%s:%s", cfg.ContainerName(), cfg.GrpcPort()))
+}
+
+// DialAddr dials an agent at the given address instead of the container name.
+func (client *Client) DialAddr(cfg config.AgentConfig, addr string) error {
 	var (
 		conn *grpc.ClientConn
 		err  error
 	)
 	for i := 0; i < 10; i++ {
 		conn, err = grpc.Dial(
-			fmt.Sprintf("%s:%s", cfg.ContainerName(), cfg.GrpcPort()),
+			addr,
 			grpc.WithInsecure(),
 			grpc.WithBlock(),
 			grpc.WithTimeout(10*time.Second),
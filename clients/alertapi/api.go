@@ -12,6 +12,26 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// APIError is the error response from the alert API.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("%d error: %s", err.StatusCode, err.Body)
+}
+
+// Retryable tells if the same request can succeed later. The other client errors mean
+// that the API rejected the request.
+func (err *APIError) Retryable() bool {
+	switch err.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return err.StatusCode < 400 || err.StatusCode >= 500
+}
+
 type client struct {
 	apiUrl string
 }
@@ -45,7 +65,7 @@ func (c *client) post(path string, body interface{}, headers map[string]string,
 			"response": string(b),
 			"status":   resp.StatusCode,
 		}).Error("alert api error")
-		return fmt.Errorf("%d error: %s", resp.StatusCode, string(b))
+		return &APIError{StatusCode: resp.StatusCode, Body: string(b)}
 	}
 	return json.Unmarshal(b, target)
 }
@@ -0,0 +1,187 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultContainerCacheResyncInterval = time.Minute
+	defaultContainerEventsRetryDelay    = time.Second * 5
+)
+
+// ContainerCache keeps the container list in memory and updates it when there is
+// a Docker event. The list is also synced periodically in case an event is missed.
+type ContainerCache struct {
+	ctx            context.Context
+	client         DockerClient
+	resyncInterval time.Duration
+
+	containers DockerContainerList
+	handlers   []func(events.Message)
+	mu         sync.RWMutex
+}
+
+// NewContainerCache creates a new container cache.
+func NewContainerCache(ctx context.Context, client DockerClient) *ContainerCache {
+	return &ContainerCache{
+		ctx:            ctx,
+		client:         client,
+		resyncInterval: defaultContainerCacheResyncInterval,
+	}
+}
+
+// OnEvent adds a handler which is called after the cache is updated with an event.
+func (cc *ContainerCache) OnEvent(handler func(events.Message)) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.handlers = append(cc.handlers, handler)
+}
+
+// Start syncs the container list and starts listening to the events.
+func (cc *ContainerCache) Start() error {
+	if err := cc.Resync(); err != nil {
+		return err
+	}
+	go cc.listenEvents()
+	go cc.resyncLoop()
+	return nil
+}
+
+// Resync replaces the cached container list with the latest list.
+func (cc *ContainerCache) Resync() error {
+	containers, err := cc.client.GetContainers(cc.ctx)
+	if err != nil {
+		return err
+	}
+	cc.mu.Lock()
+	cc.containers = containers
+	cc.mu.Unlock()
+	return nil
+}
+
+func (cc *ContainerCache) resyncLoop() {
+	ticker := time.NewTicker(cc.resyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cc.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cc.Resync(); err != nil {
+				log.WithError(err).Warn("failed to resync the container cache")
+			}
+		}
+	}
+}
+
+func (cc *ContainerCache) listenEvents() {
+	for {
+		msgs, errs := cc.client.Events(cc.ctx)
+		err := cc.handleEvents(msgs, errs)
+		if cc.ctx.Err() != nil {
+			return
+		}
+		log.WithError(err).Warn("docker events subscription failed - retrying")
+		select {
+		case <-cc.ctx.Done():
+			return
+		case <-time.After(defaultContainerEventsRetryDelay):
+		}
+		// the events are missed while the subscription is down
+		if err := cc.Resync(); err != nil {
+			log.WithError(err).Warn("failed to resync the container cache")
+		}
+	}
+}
+
+func (cc *ContainerCache) handleEvents(msgs <-chan events.Message, errs <-chan error) error {
+	for {
+		select {
+		case <-cc.ctx.Done():
+			return cc.ctx.Err()
+		case err := <-errs:
+			return err
+		case msg := <-msgs:
+			log.WithFields(log.Fields{
+				"type":   msg.Type,
+				"action": msg.Action,
+				"id":     msg.Actor.ID,
+			}).Debug("docker event")
+			if err := cc.update(msg); err != nil {
+				log.WithError(err).Warn("failed to update the container cache after event")
+			}
+			cc.mu.RLock()
+			handlers := cc.handlers
+			cc.mu.RUnlock()
+			for _, handler := range handlers {
+				handler(msg)
+			}
+		}
+	}
+}
+
+// update gets only the container of the event and replaces the cached entry. The container
+// is removed from the cache if it does not exist anymore.
+func (cc *ContainerCache) update(msg events.Message) error {
+	containerID := msg.Actor.ID
+	// the actor of the network events is the network
+	if msg.Type == events.NetworkEventType {
+		containerID = msg.Actor.Attributes["container"]
+	}
+	if len(containerID) == 0 {
+		return nil
+	}
+	container, err := cc.client.GetContainerByID(cc.ctx, containerID)
+	if err != nil && !errors.Is(err, ErrContainerNotFound) {
+		return err
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	// the list is copied as the callers can still be reading the old one
+	containers := make(DockerContainerList, 0, len(cc.containers)+1)
+	for _, cached := range cc.containers {
+		if cached.ID != containerID {
+			containers = append(containers, cached)
+		}
+	}
+	if container != nil {
+		containers = append(containers, *container)
+	}
+	cc.containers = containers
+	return nil
+}
+
+// Containers returns the cached container list.
+func (cc *ContainerCache) Containers() DockerContainerList {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.containers
+}
+
+// FindByID finds the container by the ID.
+func (cc *ContainerCache) FindByID(id string) (*types.Container, bool) {
+	return cc.Containers().FindByID(id)
+}
+
+// FindByIPAddress finds the container which has the IP address in one of its networks.
+func (cc *ContainerCache) FindByIPAddress(ipAddr string) (*types.Container, bool) {
+	for _, container := range cc.Containers() {
+		if container.NetworkSettings == nil {
+			continue
+		}
+		for _, network := range container.NetworkSettings.Networks {
+			if network != nil && network.IPAddress == ipAddr {
+				return &container, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,90 @@
+package clients_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/forta-network/forta-node/clients"
+	mock_clients "github.com/forta-network/forta-node/clients/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func testContainer(id, ipAddr string) types.Container {
+	return types.Container{
+		ID:    id,
+		Names: []string{"/" + id},
+		NetworkSettings: &types.SummaryNetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"test-network": {IPAddress: ipAddr},
+			},
+		},
+	}
+}
+
+func TestContainerCache(t *testing.T) {
+	r := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := mock_clients.NewMockDockerClient(gomock.NewController(t))
+	msgs := make(chan events.Message)
+	errs := make(chan error)
+
+	client.EXPECT().GetContainers(gomock.Any()).Return(clients.DockerContainerList{
+		testContainer("container1", "172.0.0.1"),
+	}, nil)
+	client.EXPECT().Events(gomock.Any()).Return(msgs, errs)
+
+	cache := clients.NewContainerCache(ctx, client)
+	handled := make(chan events.Message, 1)
+	cache.OnEvent(func(msg events.Message) {
+		handled <- msg
+	})
+	r.NoError(cache.Start())
+
+	container, ok := cache.FindByIPAddress("172.0.0.1")
+	r.True(ok)
+	r.Equal("container1", container.ID)
+	_, ok = cache.FindByID("container2")
+	r.False(ok)
+
+	// only the container of the event is updated in the cache
+	container2 := testContainer("container2", "172.0.0.2")
+	client.EXPECT().GetContainerByID(gomock.Any(), "container2").Return(&container2, nil)
+	msgs <- events.Message{Type: events.ContainerEventType, Action: "start", Actor: events.Actor{ID: "container2"}}
+
+	select {
+	case msg := <-handled:
+		r.Equal("start", msg.Action)
+	case <-time.After(time.Second):
+		r.FailNow("event was not handled")
+	}
+	container, ok = cache.FindByIPAddress("172.0.0.2")
+	r.True(ok)
+	r.Equal("container2", container.ID)
+	r.Len(cache.Containers(), 2)
+
+	// the container of the network event is removed if it does not exist anymore
+	client.EXPECT().GetContainerByID(gomock.Any(), "container1").Return(nil, clients.ErrContainerNotFound)
+	msgs <- events.Message{
+		Type:   events.NetworkEventType,
+		Action: "connect",
+		Actor:  events.Actor{ID: "network1", Attributes: map[string]string{"container": "container1"}},
+	}
+
+	select {
+	case msg := <-handled:
+		r.Equal("connect", msg.Action)
+	case <-time.After(time.Second):
+		r.FailNow("event was not handled")
+	}
+	_, ok = cache.FindByID("container1")
+	r.False(ok)
+	r.Len(cache.Containers(), 1)
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
@@ -71,6 +72,34 @@ type DockerContainerConfig struct {
 	Cmd             []string
 	DialHost        bool
 	Labels          map[string]string
+	Security        *DockerSecurityConfig
+}
+
+// DockerSecurityConfig hardens the container.
+type DockerSecurityConfig struct {
+	ReadOnlyRootFS bool
+	// Tmpfs contains the tmpfs mount options by the mount path.
+	Tmpfs           map[string]string
+	CapDrop         []string
+	NoNewPrivileges bool
+	PidsLimit       int64
+	User            string
+	// SeccompProfile is the JSON content of a seccomp profile.
+	SeccompProfile string
+}
+
+func (sc *DockerSecurityConfig) apply(cntCfg *container.Config, hostCfg *container.HostConfig) {
+	cntCfg.User = sc.User
+	hostCfg.ReadonlyRootfs = sc.ReadOnlyRootFS
+	hostCfg.Tmpfs = sc.Tmpfs
+	hostCfg.CapDrop = sc.CapDrop
+	hostCfg.Resources.PidsLimit = sc.PidsLimit
+	if sc.NoNewPrivileges {
+		hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, "no-new-privileges")
+	}
+	if len(sc.SeccompProfile) > 0 {
+		hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, fmt.Sprintf("seccomp=%s", sc.SeccompProfile))
+	}
 }
 
 // DockerContainerList contains the full container data.
@@ -299,9 +328,14 @@ func (d *dockerClient) GetContainerByName(ctx context.Context, name string) (*ty
 	return nil, fmt.Errorf("%w with name '%s'", ErrContainerNotFound, name)
 }
 
-// GetContainerByName gets a container by using an ID lookup over all containers.
+// GetContainerByID gets a container by listing only the container with the ID.
 func (d *dockerClient) GetContainerByID(ctx context.Context, id string) (*types.Container, error) {
-	containers, err := d.GetContainers(ctx)
+	filter := d.labelFilter()
+	filter.Add("id", id)
+	containers, err := d.cli.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filter,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -313,6 +347,34 @@ func (d *dockerClient) GetContainerByID(ctx context.Context, id string) (*types.
 	return nil, fmt.Errorf("%w with id '%s'", ErrContainerNotFound, id)
 }
 
+// Events subscribes to the container and the network events which change the state of the containers.
+func (d *dockerClient) Events(ctx context.Context) (<-chan events.Message, <-chan error) {
+	return d.cli.Events(ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", events.ContainerEventType),
+			filters.Arg("type", events.NetworkEventType),
+			filters.Arg("event", "start"),
+			filters.Arg("event", "die"),
+			filters.Arg("event", "oom"),
+			filters.Arg("event", "connect"),
+		),
+	})
+}
+
+// GetContainerStats gets a single sample of the container resource usage stats.
+func (d *dockerClient) GetContainerStats(ctx context.Context, containerID string) (*types.StatsJSON, error) {
+	resp, err := d.cli.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to decode container stats: %v", err)
+	}
+	return &stats, nil
+}
+
 // Nuke makes sure that all running Forta containers are stopped and pruned, quickly enough.
 func (d *dockerClient) Nuke(ctx context.Context) error {
 	var err error
@@ -466,6 +528,10 @@ func (d *dockerClient) StartContainer(ctx context.Context, config DockerContaine
 		},
 	}
 
+	if config.Security != nil {
+		config.Security.apply(cntCfg, hostCfg)
+	}
+
 	if config.DialHost {
 		hostCfg.ExtraHosts = append(hostCfg.ExtraHosts, "host.docker.internal:host-gateway")
 	}
@@ -8,6 +8,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	"github.com/golang/protobuf/proto"
 
 	"github.com/forta-network/forta-core-go/protocol"
@@ -39,6 +40,8 @@ type DockerClient interface {
 	HasLocalImage(ctx context.Context, ref string) bool
 	EnsureLocalImage(ctx context.Context, name, ref string) error
 	GetContainerLogs(ctx context.Context, containerID, tail string, truncate int) (string, error)
+	Events(ctx context.Context) (<-chan events.Message, <-chan error)
+	GetContainerStats(ctx context.Context, containerID string) (*types.StatsJSON, error)
 }
 
 // MessageClient receives and publishes messages.
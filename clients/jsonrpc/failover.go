@@ -0,0 +1,422 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/utils"
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/forta-network/forta-node/config"
+)
+
+const (
+	defaultRequestTimeout     = time.Minute
+	defaultHealthCheckTimeout = time.Second * 10
+)
+
+var errAllEndpointsFailed = errors.New("all json-rpc endpoints failed")
+
+// the json-rpc errors which mean that another endpoint can serve the same request
+var (
+	failoverErrorCodes = map[int]bool{
+		-32005: true, // limit exceeded
+		-32603: true, // internal error
+	}
+	failoverErrorMessages = []string{
+		"header not found",
+		"unknown block",
+		"missing trie node",
+		"rate limit",
+		"limit exceeded",
+		"too many requests",
+	}
+)
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// shouldFailOver tells if the json-rpc error is from the endpoint rather than the request.
+func (rpcErr *rpcError) shouldFailOver() bool {
+	if failoverErrorCodes[rpcErr.Code] {
+		return true
+	}
+	msg := strings.ToLower(rpcErr.Message)
+	for _, failoverMsg := range failoverErrorMessages {
+		if strings.Contains(msg, failoverMsg) {
+			return true
+		}
+	}
+	return false
+}
+
+// failoverError is returned when the endpoint responds but the response is a json-rpc error
+// which should be tried on the next endpoint. It keeps the response so that it can still
+// be returned if all endpoints fail.
+type failoverError struct {
+	rpcErr *rpcError
+	header http.Header
+	body   []byte
+}
+
+func (err *failoverError) Error() string {
+	return fmt.Sprintf("endpoint responded with json-rpc error %d: %s", err.rpcErr.Code, err.rpcErr.Message)
+}
+
+type endpoint struct {
+	index       int
+	url         string
+	host        string
+	headers     map[string]string
+	blockNumber uint64
+	lastErr     error
+	lagging     bool
+}
+
+func (ep *endpoint) isHealthy() bool {
+	return ep.lastErr == nil && !ep.lagging
+}
+
+// FailoverProxy serves the JSON-RPC API locally and forwards the requests to the preferred
+// healthy endpoint. It fails over to the next endpoint if the request fails.
+//
+// The block feed asks for the blocks by number so switching the endpoints does not cause
+// any gaps or duplicates.
+type FailoverProxy struct {
+	ctx        context.Context
+	name       string
+	cfg        config.JsonRpcFailoverConfig
+	endpoints  []*endpoint
+	active     *endpoint
+	httpClient *http.Client
+	server     *http.Server
+	url        string
+	mu         sync.RWMutex
+}
+
+// NewFailoverProxy creates a new failover proxy for the endpoints in the priority order.
+// The websocket endpoints are not supported.
+func NewFailoverProxy(ctx context.Context, name string, endpointCfgs []config.JsonRpcEndpointConfig, cfg config.JsonRpcFailoverConfig) (*FailoverProxy, error) {
+	if len(endpointCfgs) == 0 {
+		return nil, errors.New("no json-rpc endpoints to fail over")
+	}
+	fp := &FailoverProxy{
+		ctx:        ctx,
+		name:       name,
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: defaultRequestTimeout},
+	}
+	for i, endpointCfg := range endpointCfgs {
+		u, err := url.Parse(endpointCfg.Url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json-rpc endpoint url: %v", err)
+		}
+		// the requests are forwarded over http so the subscriptions cannot be proxied
+		if u.Scheme == "ws" || u.Scheme == "wss" {
+			return nil, fmt.Errorf("json-rpc endpoint %d is a websocket url: failover needs http endpoints", i)
+		}
+		fp.endpoints = append(fp.endpoints, &endpoint{
+			index:   i,
+			url:     endpointCfg.Url,
+			host:    u.Hostname(),
+			headers: endpointCfg.Headers,
+		})
+	}
+	fp.active = fp.endpoints[0]
+	return fp, nil
+}
+
+// Start starts listening on a random local port and returns the url to use.
+func (fp *FailoverProxy) Start() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to listen for the json-rpc failover proxy: %v", err)
+	}
+	fp.server = &http.Server{Handler: fp}
+	go func() {
+		if err := fp.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).WithField("proxy", fp.Name()).Error("json-rpc failover proxy failed")
+		}
+	}()
+	go fp.healthCheckLoop()
+	fp.url = fmt.Sprintf("http://%s", listener.Addr().String())
+	return fp.url, nil
+}
+
+// URL returns the local url of the proxy after it is started.
+func (fp *FailoverProxy) URL() string {
+	return fp.url
+}
+
+// Stop stops the proxy.
+func (fp *FailoverProxy) Stop() error {
+	if fp.server != nil {
+		return fp.server.Close()
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (fp *FailoverProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var lastFailoverErr *failoverError
+	for _, ep := range fp.candidates() {
+		resp, err := fp.forward(r.Context(), ep, body)
+		if err != nil {
+			// the caller gave up so the endpoint is not to blame
+			if r.Context().Err() != nil {
+				return
+			}
+			fp.setFailed(ep, err)
+			if failoverErr, ok := err.(*failoverError); ok {
+				lastFailoverErr = failoverErr
+			}
+			continue
+		}
+		fp.setActive(ep)
+		for h, v := range resp.Header {
+			w.Header()[h] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		resp.Body.Close()
+		return
+	}
+	// the agent should see the json-rpc error rather than a generic one
+	if lastFailoverErr != nil {
+		for h, v := range lastFailoverErr.header {
+			w.Header()[h] = v
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(lastFailoverErr.body)
+		return
+	}
+	http.Error(w, errAllEndpointsFailed.Error(), http.StatusBadGateway)
+}
+
+func (fp *FailoverProxy) forward(ctx context.Context, ep *endpoint, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for h, v := range ep.headers {
+		req.Header.Set(h, v)
+	}
+	resp, err := fp.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		return nil, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if rpcErr := findFailoverError(respBody); rpcErr != nil {
+		return nil, &failoverError{rpcErr: rpcErr, header: resp.Header, body: respBody}
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// findFailoverError finds the first error which should fail over in a single or a batch response.
+func findFailoverError(respBody []byte) *rpcError {
+	type rpcResponse struct {
+		Error *rpcError `json:"error"`
+	}
+	var responses []rpcResponse
+	trimmed := bytes.TrimSpace(respBody)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &responses); err != nil {
+			return nil
+		}
+	} else {
+		var single rpcResponse
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return nil
+		}
+		responses = append(responses, single)
+	}
+	for _, resp := range responses {
+		if resp.Error != nil && resp.Error.shouldFailOver() {
+			return resp.Error
+		}
+	}
+	return nil
+}
+
+// candidates returns the healthy endpoints first and then the rest, in the priority order.
+func (fp *FailoverProxy) candidates() []*endpoint {
+	fp.mu.RLock()
+	defer fp.mu.RUnlock()
+	var healthy, unhealthy []*endpoint
+	for _, ep := range fp.endpoints {
+		if ep.isHealthy() {
+			healthy = append(healthy, ep)
+		} else {
+			unhealthy = append(unhealthy, ep)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (fp *FailoverProxy) setFailed(ep *endpoint, err error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	ep.lastErr = err
+	log.WithFields(log.Fields{
+		"proxy":    fp.Name(),
+		"endpoint": ep.host,
+	}).WithError(err).Warn("json-rpc endpoint failed")
+}
+
+func (fp *FailoverProxy) setActive(ep *endpoint) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	if fp.active == ep {
+		return
+	}
+	log.WithFields(log.Fields{
+		"proxy": fp.Name(),
+		"from":  fp.active.host,
+		"to":    ep.host,
+	}).Warn("switched json-rpc endpoint")
+	fp.active = ep
+}
+
+func (fp *FailoverProxy) healthCheckLoop() {
+	fp.checkEndpoints()
+	ticker := time.NewTicker(time.Duration(fp.cfg.HealthCheckIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fp.ctx.Done():
+			fp.Stop()
+			return
+		case <-ticker.C:
+			fp.checkEndpoints()
+		}
+	}
+}
+
+// checkEndpoints gets the latest block number from all endpoints and
+// finds out which ones are failing or lagging behind.
+func (fp *FailoverProxy) checkEndpoints() {
+	type result struct {
+		blockNumber uint64
+		err         error
+	}
+	results := make([]result, len(fp.endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range fp.endpoints {
+		wg.Add(1)
+		go func(i int, ep *endpoint) {
+			defer wg.Done()
+			results[i].blockNumber, results[i].err = fp.getBlockNumber(ep)
+		}(i, ep)
+	}
+	wg.Wait()
+
+	var highest uint64
+	for _, res := range results {
+		if res.err == nil && res.blockNumber > highest {
+			highest = res.blockNumber
+		}
+	}
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	for i, ep := range fp.endpoints {
+		ep.lastErr = results[i].err
+		ep.blockNumber = results[i].blockNumber
+		ep.lagging = results[i].err == nil && highest-ep.blockNumber > fp.cfg.MaxLagBlocks
+	}
+}
+
+func (fp *FailoverProxy) getBlockNumber(ep *endpoint) (uint64, error) {
+	ctx, cancel := context.WithTimeout(fp.ctx, defaultHealthCheckTimeout)
+	defer cancel()
+	resp, err := fp.forward(ctx, ep, []byte(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var rpcResp struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return 0, fmt.Errorf("failed to decode block number response: %v", err)
+	}
+	if rpcResp.Error != nil {
+		return 0, fmt.Errorf("failed to get block number: %s", rpcResp.Error.Message)
+	}
+	blockNumber, err := utils.HexToBigInt(rpcResp.Result)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number: %v", err)
+	}
+	return blockNumber.Uint64(), nil
+}
+
+// Name returns the name of this implementation.
+func (fp *FailoverProxy) Name() string {
+	return fmt.Sprintf("%s-json-rpc-failover", fp.name)
+}
+
+// Health implements the health.Reporter interface.
+func (fp *FailoverProxy) Health() health.Reports {
+	fp.mu.RLock()
+	defer fp.mu.RUnlock()
+
+	var reports health.Reports
+	for _, ep := range fp.endpoints {
+		role := "standby"
+		if ep == fp.active {
+			role = "active"
+		}
+		report := &health.Report{
+			Name:    fmt.Sprintf("endpoint.%d", ep.index),
+			Status:  health.StatusOK,
+			Details: fmt.Sprintf("%s (%s) at block %d", role, ep.host, ep.blockNumber),
+		}
+		switch {
+		case ep.lastErr != nil:
+			report.Status = health.StatusFailing
+			report.Details = fmt.Sprintf("%s (%s) failing: %s", role, ep.host, strings.TrimSpace(ep.lastErr.Error()))
+		case ep.lagging:
+			report.Status = health.StatusLagging
+			report.Details = fmt.Sprintf("%s (%s) lagging at block %d", role, ep.host, ep.blockNumber)
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
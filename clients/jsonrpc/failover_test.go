@@ -0,0 +1,243 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/ethereum"
+	"github.com/forta-network/forta-core-go/feeds"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forta-network/forta-node/config"
+)
+
+func testEndpoint(blockNumber *int, failing *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, *blockNumber)
+	}))
+}
+
+func TestFailoverProxy(t *testing.T) {
+	r := require.New(t)
+
+	var (
+		primaryBlock, fallbackBlock     = 100, 100
+		primaryFailing, fallbackFailing bool
+	)
+	primary := testEndpoint(&primaryBlock, &primaryFailing)
+	defer primary.Close()
+	fallback := testEndpoint(&fallbackBlock, &fallbackFailing)
+	defer fallback.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fp, err := NewFailoverProxy(ctx, "test", config.JsonRpcConfig{
+		Url: primary.URL,
+		Endpoints: []config.JsonRpcEndpointConfig{
+			{Url: fallback.URL, Priority: 1},
+		},
+	}.AllEndpoints(), config.JsonRpcFailoverConfig{HealthCheckIntervalSeconds: 60, MaxLagBlocks: 10})
+	r.NoError(err)
+	proxyUrl, err := fp.Start()
+	r.NoError(err)
+
+	call := func() string {
+		resp, err := http.Post(proxyUrl, "application/json", bytes.NewBufferString(`{}`))
+		r.NoError(err)
+		defer resp.Body.Close()
+		r.Equal(http.StatusOK, resp.StatusCode)
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	// uses the primary endpoint first
+	primaryBlock = 101
+	r.Contains(call(), "0x65")
+
+	// fails over when the primary fails
+	primaryFailing = true
+	r.Contains(call(), "0x64")
+	reports := fp.Health()
+	r.Equal(health.StatusFailing, reports[0].Status)
+	r.Contains(reports[1].Details, "active")
+
+	// goes back to the primary after it recovers
+	primaryFailing = false
+	fp.checkEndpoints()
+	r.Contains(call(), "0x65")
+
+	// fails over when the primary lags
+	fallbackBlock = 200
+	fp.checkEndpoints()
+	r.Equal(health.StatusLagging, fp.Health()[0].Status)
+	r.Contains(call(), "0xc8")
+}
+
+func TestFailoverProxy_RPCError(t *testing.T) {
+	r := require.New(t)
+
+	var primaryResp, fallbackResp atomic.Value
+	rpcEndpoint := func(resp *atomic.Value) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			// the health checks succeed
+			if bytes.Contains(b, []byte("eth_blockNumber")) {
+				fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x64"}`)
+				return
+			}
+			fmt.Fprint(w, resp.Load())
+		}))
+	}
+	primaryResp.Store(`{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted"}}`)
+	fallbackResp.Store(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`)
+	primary := rpcEndpoint(&primaryResp)
+	defer primary.Close()
+	fallback := rpcEndpoint(&fallbackResp)
+	defer fallback.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fp, err := NewFailoverProxy(ctx, "test", config.JsonRpcConfig{
+		Url: primary.URL,
+		Endpoints: []config.JsonRpcEndpointConfig{
+			{Url: fallback.URL, Priority: 1},
+		},
+	}.AllEndpoints(), config.JsonRpcFailoverConfig{HealthCheckIntervalSeconds: 60, MaxLagBlocks: 10})
+	r.NoError(err)
+	proxyUrl, err := fp.Start()
+	r.NoError(err)
+
+	call := func() string {
+		resp, err := http.Post(proxyUrl, "application/json", bytes.NewBufferString(`{}`))
+		r.NoError(err)
+		defer resp.Body.Close()
+		r.Equal(http.StatusOK, resp.StatusCode)
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	// the request errors are returned from the primary
+	r.Contains(call(), "execution reverted")
+	r.Equal(health.StatusOK, fp.Health()[0].Status)
+
+	// fails over when the primary cannot serve the request
+	primaryResp.Store(`[{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}]`)
+	r.Contains(call(), "0x1")
+	r.Equal(health.StatusFailing, fp.Health()[0].Status)
+
+	// returns the json-rpc error when no endpoint can serve the request
+	fallbackResp.Store(`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"limit exceeded"}}`)
+	r.Contains(call(), `"error"`)
+	r.Equal(health.StatusFailing, fp.Health()[1].Status)
+}
+
+func TestFailoverProxy_Websocket(t *testing.T) {
+	_, err := NewFailoverProxy(context.Background(), "test", config.JsonRpcConfig{
+		Url: "ws://primary",
+		Endpoints: []config.JsonRpcEndpointConfig{
+			{Url: "http://fallback", Priority: 1},
+		},
+	}.AllEndpoints(), config.JsonRpcFailoverConfig{HealthCheckIntervalSeconds: 60, MaxLagBlocks: 10})
+	require.Error(t, err)
+}
+
+// chainEndpoint serves the same chain of blocks and stops serving after the block number
+// in stopAfter, if it is set.
+func chainEndpoint(stopAfter uint64) *httptest.Server {
+	var stopped int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&stopped) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var result interface{}
+		switch req.Method {
+		case "eth_blockNumber":
+			result = "0x14"
+		case "eth_getLogs":
+			result = []interface{}{}
+		case "eth_getBlockByNumber":
+			var number string
+			json.Unmarshal(req.Params[0], &number)
+			if number == "latest" {
+				number = "0x14"
+			}
+			n, _ := new(big.Int).SetString(number[2:], 16)
+			result = map[string]interface{}{
+				"number":       number,
+				"hash":         fmt.Sprintf("0x%064x", n),
+				"parentHash":   fmt.Sprintf("0x%064x", n.Int64()-1),
+				"timestamp":    "0x1",
+				"transactions": []interface{}{},
+			}
+			if stopAfter > 0 && n.Uint64() == stopAfter {
+				atomic.StoreInt32(&stopped, 1)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+}
+
+func TestFailoverProxy_BlockFeed(t *testing.T) {
+	r := require.New(t)
+
+	primary := chainEndpoint(5)
+	defer primary.Close()
+	fallback := chainEndpoint(0)
+	defer fallback.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fp, err := NewFailoverProxy(ctx, "test", config.JsonRpcConfig{
+		Url: primary.URL,
+		Endpoints: []config.JsonRpcEndpointConfig{
+			{Url: fallback.URL, Priority: 1},
+		},
+	}.AllEndpoints(), config.JsonRpcFailoverConfig{HealthCheckIntervalSeconds: 60, MaxLagBlocks: 10})
+	r.NoError(err)
+	proxyUrl, err := fp.Start()
+	r.NoError(err)
+
+	client, err := ethereum.NewStreamEthClient(ctx, "test", proxyUrl)
+	r.NoError(err)
+	blockFeed, err := feeds.NewBlockFeed(ctx, client, client, feeds.BlockFeedConfig{ChainID: big.NewInt(1)})
+	r.NoError(err)
+	var blocks []string
+	errCh := blockFeed.Subscribe(func(evt *domain.BlockEvent) error {
+		blocks = append(blocks, evt.Block.Number)
+		return nil
+	})
+	blockFeed.StartRange(1, 10, 0)
+
+	select {
+	case err := <-errCh:
+		r.Equal(feeds.ErrEndBlockReached, err)
+	case <-time.After(time.Second * 10):
+		r.FailNow("block feed did not reach the end block")
+	}
+
+	// the feed switches to the fallback after the block 5 without any gaps or duplicates
+	r.Equal(health.StatusFailing, fp.Health()[0].Status)
+	r.Equal([]string{"0x1", "0x2", "0x3", "0x4", "0x5", "0x6", "0x7", "0x8", "0x9", "0xa"}, blocks)
+}
@@ -1,6 +1,7 @@
 package messaging
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -18,8 +19,9 @@ var (
 
 // Client wraps the NATS client to publish and receive our messages.
 type Client struct {
-	logger *log.Entry
-	nc     *nats.Conn
+	logger  *log.Entry
+	nc      *nats.Conn
+	chainID int
 }
 
 // NewClient creates and starts a new client.
@@ -49,45 +51,73 @@ func NewClient(name, natsURL string) *Client {
 	return client
 }
 
+// ForChain returns a client which scopes the subjects to one of the additional chains
+// so the services of different chains do not receive each other's messages.
+func (client *Client) ForChain(chainID int) *Client {
+	if chainID == 0 {
+		return client
+	}
+	return &Client{
+		logger:  client.logger.WithField("chainId", chainID),
+		nc:      client.nc,
+		chainID: chainID,
+	}
+}
+
 // AgentsHandler handles agents.* subjects.
 type AgentsHandler func(AgentPayload) error
 type AgentMetricHandler func(*protocol.AgentMetricList) error
 type ScannerHandler func(ScannerPayload) error
+type ReorgHandler func(ReorgPayload) error
+
+var errNoHandler = errors.New("no handler found")
+
+// handle decodes the message data for the handler and invokes it.
+func handle(handler interface{}, data []byte) error {
+	switch h := handler.(type) {
+	case AgentsHandler:
+		var payload AgentPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		return h(payload)
+
+	case AgentMetricHandler:
+		var payload protocol.AgentMetricList
+		if err := proto.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		return h(&payload)
+
+	case ScannerHandler:
+		var payload ScannerPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		return h(payload)
+
+	case ReorgHandler:
+		var payload ReorgPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		return h(payload)
+
+	default:
+		return errNoHandler
+	}
+}
 
 // Subscribe subscribes the consumer to this client.
 func (client *Client) Subscribe(subject string, handler interface{}) {
+	subject = ChainSubject(subject, client.chainID)
 	// TODO: Configure redelivery options somehow.
 	logger := client.logger.WithField("subject", subject)
 	_, err := client.nc.Subscribe(subject, func(m *nats.Msg) {
 		logger.Debugf("received: %s", string(m.Data))
 
-		var err error
-		switch h := handler.(type) {
-		case AgentsHandler:
-			var payload AgentPayload
-			err = json.Unmarshal(m.Data, &payload)
-			if err != nil {
-				break
-			}
-			err = h(payload)
-
-		case AgentMetricHandler:
-			var payload protocol.AgentMetricList
-			err = proto.Unmarshal(m.Data, &payload)
-			if err != nil {
-				break
-			}
-			err = h(&payload)
-
-		case ScannerHandler:
-			var payload ScannerPayload
-			err = json.Unmarshal(m.Data, &payload)
-			if err != nil {
-				break
-			}
-			err = h(payload)
-
-		default:
+		err := handle(handler, m.Data)
+		if err == errNoHandler {
 			logger.Panicf("no handler found")
 		}
 
@@ -106,6 +136,7 @@ func (client *Client) Subscribe(subject string, handler interface{}) {
 
 // Publish publishes new messages.
 func (client *Client) Publish(subject string, payload interface{}) {
+	subject = ChainSubject(subject, client.chainID)
 	logger := client.logger.WithField("subject", subject)
 	data, _ := json.Marshal(payload)
 	if err := client.nc.Publish(subject, data); err != nil {
@@ -116,6 +147,7 @@ func (client *Client) Publish(subject string, payload interface{}) {
 
 // PublishProto publishes new messages.
 func (client *Client) PublishProto(subject string, payload proto.Message) {
+	subject = ChainSubject(subject, client.chainID)
 	logger := client.logger.WithField("subject", subject)
 	data, _ := proto.Marshal(payload)
 	if err := client.nc.Publish(subject, data); err != nil {
@@ -0,0 +1,79 @@
+package messaging
+
+import (
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+)
+
+type localSubscription struct {
+	handler interface{}
+	msgCh   chan []byte
+}
+
+// LocalClient delivers the messages within the process without a NATS server.
+// Like NATS subscriptions, each handler receives the messages in order.
+type LocalClient struct {
+	logger        *log.Entry
+	subscriptions map[string][]*localSubscription
+	mu            sync.RWMutex
+}
+
+// NewLocalClient creates a new local client.
+func NewLocalClient(name string) *LocalClient {
+	return &LocalClient{
+		logger:        log.WithField("name", name+"/local-messaging"),
+		subscriptions: make(map[string][]*localSubscription),
+	}
+}
+
+// Subscribe subscribes the consumer to this client.
+func (client *LocalClient) Subscribe(subject string, handler interface{}) {
+	logger := client.logger.WithField("subject", subject)
+	sub := &localSubscription{
+		handler: handler,
+		msgCh:   make(chan []byte, BufferSize),
+	}
+	go func() {
+		for data := range sub.msgCh {
+			err := handle(sub.handler, data)
+			if err == errNoHandler {
+				logger.Panicf("no handler found")
+			}
+			if err != nil {
+				logger.Errorf("failed to handle msg: %v", err)
+			}
+		}
+	}()
+
+	client.mu.Lock()
+	client.subscriptions[subject] = append(client.subscriptions[subject], sub)
+	client.mu.Unlock()
+	logger.Debug("subscribed")
+}
+
+// Publish publishes new messages.
+func (client *LocalClient) Publish(subject string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	client.dispatch(subject, data)
+}
+
+// PublishProto publishes new messages.
+func (client *LocalClient) PublishProto(subject string, payload proto.Message) {
+	data, _ := proto.Marshal(payload)
+	client.dispatch(subject, data)
+}
+
+func (client *LocalClient) dispatch(subject string, data []byte) {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	for _, sub := range client.subscriptions[subject] {
+		select {
+		case sub.msgCh <- data:
+		default:
+			client.logger.WithField("subject", subject).Warn("subscription buffer is full - dropping msg")
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/forta-network/forta-node/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalClient(t *testing.T) {
+	r := require.New(t)
+
+	client := NewLocalClient("test")
+	received := make(chan AgentPayload, 2)
+	client.Subscribe(SubjectAgentsActionRun, AgentsHandler(func(payload AgentPayload) error {
+		received <- payload
+		return nil
+	}))
+
+	client.Publish(SubjectAgentsActionRun, AgentPayload{{ID: "agent1"}})
+	client.Publish(SubjectAgentsActionRun, AgentPayload{{ID: "agent2"}})
+	client.Publish(SubjectAgentsActionStop, AgentPayload{{ID: "agent3"}})
+
+	for _, expected := range []string{"agent1", "agent2"} {
+		select {
+		case payload := <-received:
+			r.Equal(AgentPayload{config.AgentConfig{ID: expected}}, payload)
+		case <-time.After(time.Second):
+			r.FailNow("timed out waiting for the message")
+		}
+	}
+	r.Len(received, 0)
+}
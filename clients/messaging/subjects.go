@@ -1,6 +1,8 @@
 package messaging
 
 import (
+	"fmt"
+
 	"github.com/forta-network/forta-core-go/protocol"
 	"github.com/forta-network/forta-node/config"
 )
@@ -8,15 +10,27 @@ import (
 // Message types
 const (
 	SubjectAgentsVersionsLatest = "agents.versions.latest"
+	SubjectAgentsVersionsGet    = "agents.versions.get"
 	SubjectAgentsActionRun      = "agents.action.run"
 	SubjectAgentsActionStop     = "agents.action.stop"
 	SubjectAgentsStatusRunning  = "agents.status.running"
 	SubjectAgentsStatusAttached = "agents.status.attached"
 	SubjectAgentsStatusStopped  = "agents.status.stopped"
+	SubjectAgentsStatusFailed   = "agents.status.failed"
 	SubjectMetricAgent          = "metric.agent"
 	SubjectScannerBlock         = "scanner.block"
+	SubjectScannerReorg         = "scanner.reorg"
 )
 
+// ChainSubject scopes the subject to one of the additional chains. The subjects for
+// the primary chain (zero) are not scoped.
+func ChainSubject(subject string, chainID int) string {
+	if chainID == 0 {
+		return subject
+	}
+	return fmt.Sprintf("chain.%d.%s", chainID, subject)
+}
+
 // AgentPayload is the message payload.
 type AgentPayload []config.AgentConfig
 
@@ -27,3 +41,14 @@ type AgentMetricPayload *protocol.AgentMetricList
 type ScannerPayload struct {
 	LatestBlockInput uint64 `json:"latestBlockInput"`
 }
+
+// ReorgPayload is the message payload for the blocks orphaned by a chain reorg.
+type ReorgPayload struct {
+	OrphanedBlocks []OrphanedBlock `json:"orphanedBlocks"`
+}
+
+// OrphanedBlock is a block which is not in the canonical chain anymore.
+type OrphanedBlock struct {
+	Number uint64 `json:"number"`
+	Hash   string `json:"hash"`
+}
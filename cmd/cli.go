@@ -121,6 +121,12 @@ publishes alerts about them`,
 		RunE:  handleFortaBatchDecode,
 	}
 
+	cmdFortaReplay = &cobra.Command{
+		Use:   "replay",
+		Short: "run agents on a past block range and write the findings to a file",
+		RunE:  withInitialized(withValidConfig(handleFortaReplay)),
+	}
+
 	cmdFortaStatus = &cobra.Command{
 		Use:   "status",
 		Short: "display statuses of node services",
@@ -171,6 +177,8 @@ func init() {
 	cmdForta.AddCommand(cmdFortaBatch)
 	cmdFortaBatch.AddCommand(cmdFortaBatchDecode)
 
+	cmdForta.AddCommand(cmdFortaReplay)
+
 	cmdForta.AddCommand(cmdFortaStatus)
 
 	cmdForta.AddCommand(cmdFortaRegister)
@@ -207,6 +215,16 @@ func init() {
 	cmdFortaBatchDecode.Flags().String("o", "alert-batch.json", "output file name (default: alert-batch.json)")
 	cmdFortaBatchDecode.Flags().Bool("stdout", false, "print to stdout instead of writing to a file")
 
+	// forta replay
+	cmdFortaReplay.Flags().Uint64("from", 0, "first block of the range")
+	cmdFortaReplay.MarkFlagRequired("from")
+	cmdFortaReplay.Flags().Uint64("to", 0, "last block of the range")
+	cmdFortaReplay.MarkFlagRequired("to")
+	cmdFortaReplay.Flags().StringSlice("agent", nil, "agent image to replay with (can be repeated, default: local agents)")
+	cmdFortaReplay.Flags().String("o", "findings.jsonl", "output file name (default: findings.jsonl)")
+	cmdFortaReplay.Flags().Int64("rate", 200, "milliseconds to wait between blocks (default: 200)")
+	cmdFortaReplay.Flags().String("events", "", "recorded events file to replay instead of getting the blocks from the chain")
+
 	// forta status
 	cmdFortaStatus.Flags().String("format", StatusFormatPretty, "output formatting/encoding: pretty (default), oneline, json, csv")
 	cmdFortaStatus.Flags().Bool("no-color", false, "disable colors")
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/services/replay"
+)
+
+func handleFortaReplay(cmd *cobra.Command, args []string) error {
+	from, err := cmd.Flags().GetUint64("from")
+	if err != nil {
+		return err
+	}
+	to, err := cmd.Flags().GetUint64("to")
+	if err != nil {
+		return err
+	}
+	agentImages, err := cmd.Flags().GetStringSlice("agent")
+	if err != nil {
+		return err
+	}
+	outputPath, err := cmd.Flags().GetString("o")
+	if err != nil {
+		return err
+	}
+	rate, err := cmd.Flags().GetInt64("rate")
+	if err != nil {
+		return err
+	}
+
+	eventsPath, err := cmd.Flags().GetString("events")
+	if err != nil {
+		return err
+	}
+
+	if cfg.Scan.JsonRpc.Url == "" && eventsPath == "" {
+		return errors.New("scan.jsonRpc.url is required if no recorded events are provided")
+	}
+
+	var agents []config.AgentConfig
+	for i, agentImage := range agentImages {
+		// replay-1, replay-2, replay-3, ...
+		agents = append(agents, config.AgentConfig{
+			ID:      fmt.Sprintf("replay-%d", i+1),
+			Image:   agentImage,
+			IsLocal: true,
+		})
+	}
+	// fall back to the local agents list
+	if len(agents) == 0 {
+		for _, localAgent := range cfg.LocalAgents {
+			agents = append(agents, *localAgent)
+		}
+	}
+	if len(agents) == 0 {
+		return fmt.Errorf("please specify an agent image with --agent or add agents to %s", cfg.LocalAgentsPath)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigc
+		cancel()
+	}()
+
+	cmd.PrintErrf("Replaying blocks %d-%d with %d agent(s)...\n", from, to, len(agents))
+	result, err := replay.Run(ctx, replay.Config{
+		ChainID:    cfg.ChainID,
+		From:       from,
+		To:         to,
+		RateMs:     rate,
+		Agents:     agents,
+		OutputPath: outputPath,
+		JsonRpc:    cfg.Scan.JsonRpc,
+		Trace:      cfg.Trace,
+		EventsPath: eventsPath,
+	})
+	if err != nil {
+		return fmt.Errorf("replay failed: %v", err)
+	}
+
+	greenBold("Replay finished with %d finding(s) written to %s\n", result.FindingCount, outputPath)
+	return nil
+}
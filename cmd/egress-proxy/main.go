@@ -0,0 +1,41 @@
+package egress_proxy
+
+import (
+	"context"
+
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/healthutils"
+	"github.com/forta-network/forta-node/services"
+	"github.com/forta-network/forta-node/services/egress"
+)
+
+func initServices(ctx context.Context, cfg config.Config) ([]services.Service, error) {
+	proxy, err := egress.NewEgressProxy(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return []services.Service{
+		health.NewService(
+			ctx, "", healthutils.DefaultHealthServerErrHandler,
+			health.CheckerFrom(summarizeReports, proxy),
+		),
+		proxy,
+	}, nil
+}
+
+func summarizeReports(reports health.Reports) *health.Report {
+	summary := health.NewSummary()
+
+	blocked, ok := reports.NameContains("service.egress-proxy.requests.blocked")
+	if ok && len(blocked.Details) > 0 && blocked.Details != "0" {
+		summary.Addf("blocked %s requests from the agents.", blocked.Details)
+	}
+
+	return summary.Finish()
+}
+
+func Run() {
+	services.ContainerMain("egress-proxy", initServices)
+}
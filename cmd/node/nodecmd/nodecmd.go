@@ -1,6 +1,7 @@
 package nodecmd
 
 import (
+	egress_proxy "github.com/forta-network/forta-node/cmd/egress-proxy"
 	json_rpc "github.com/forta-network/forta-node/cmd/json-rpc"
 	"github.com/forta-network/forta-node/cmd/publisher"
 	"github.com/forta-network/forta-node/cmd/scanner"
@@ -57,6 +58,14 @@ var (
 			return nil
 		},
 	}
+
+	cmdEgressProxy = &cobra.Command{
+		Use: "egress-proxy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			egress_proxy.Run()
+			return nil
+		},
+	}
 )
 
 func init() {
@@ -65,6 +74,7 @@ func init() {
 	cmdFortaNode.AddCommand(cmdScanner)
 	cmdFortaNode.AddCommand(cmdPublisher)
 	cmdFortaNode.AddCommand(cmdJsonRpc)
+	cmdFortaNode.AddCommand(cmdEgressProxy)
 }
 
 func Run() error {
@@ -44,6 +44,16 @@ func summarizeReports(reports health.Reports) *health.Report {
 		summary.Status(health.StatusFailing)
 	}
 
+	outboxPending, ok := reports.NameContains("publisher.outbox.pending")
+	if ok && len(outboxPending.Details) > 0 && outboxPending.Details != "0" {
+		summary.Punc(".")
+		summary.Addf("%s batches are waiting to be published", outboxPending.Details)
+		outboxOldestAge, ok := reports.NameContains("publisher.outbox.oldest-age")
+		if ok && outboxOldestAge.Status == health.StatusLagging {
+			summary.Addf("and the oldest one is waiting for %s", outboxOldestAge.Details)
+		}
+	}
+
 	return summary.Finish()
 }
 
@@ -3,6 +3,8 @@ package scanner
 import (
 	"context"
 	"fmt"
+	"math/big"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -13,11 +15,13 @@ import (
 	gethlog "github.com/ethereum/go-ethereum/log"
 
 	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/domain"
 	"github.com/forta-network/forta-core-go/ethereum"
 	"github.com/forta-network/forta-core-go/feeds"
 	"github.com/forta-network/forta-core-go/security"
 	"github.com/forta-network/forta-core-go/utils"
 	"github.com/forta-network/forta-node/clients"
+	"github.com/forta-network/forta-node/clients/jsonrpc"
 	"github.com/forta-network/forta-node/clients/messaging"
 	"github.com/forta-network/forta-node/config"
 	"github.com/forta-network/forta-node/healthutils"
@@ -25,9 +29,13 @@ import (
 	"github.com/forta-network/forta-node/services/registry"
 	"github.com/forta-network/forta-node/services/scanner"
 	"github.com/forta-network/forta-node/services/scanner/agentpool"
+	"github.com/forta-network/forta-node/services/scanner/catchup"
+	"github.com/forta-network/forta-node/services/scanner/fixtures"
+	"github.com/forta-network/forta-node/store"
+	log "github.com/sirupsen/logrus"
 )
 
-func initTxStream(ctx context.Context, ethClient, traceClient ethereum.Client, cfg config.Config) (*scanner.TxStreamService, feeds.BlockFeed, error) {
+func initTxStream(ctx context.Context, ethClient, traceClient ethereum.Client, agentPool *agentpool.AgentPool, msgClient clients.MessageClient, cfg config.Config) (*scanner.TxStreamService, feeds.BlockFeed, error) {
 	cfg.Scan.JsonRpc.Url = utils.ConvertToDockerHostURL(cfg.Scan.JsonRpc.Url)
 	cfg.Registry.JsonRpc.Url = utils.ConvertToDockerHostURL(cfg.Registry.JsonRpc.Url)
 	cfg.Registry.IPFS.APIURL = utils.ConvertToDockerHostURL(cfg.Registry.IPFS.APIURL)
@@ -48,25 +56,94 @@ func initTxStream(ctx context.Context, ethClient, traceClient ethereum.Client, c
 		rateLimit = time.NewTicker(time.Duration(cfg.Scan.BlockRateLimit) * time.Millisecond)
 	}
 
-	var maxAge time.Duration
-	if cfg.Scan.BlockMaxAgeSeconds > 0 {
-		maxAge = time.Duration(cfg.Scan.BlockMaxAgeSeconds) * time.Second
+	confirmations := cfg.Scan.GetConfirmations(cfg.ChainID)
+	if err := confirmations.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid confirmations for chain %d: %v", cfg.ChainID, err)
+	}
+	// the blocks behind the latest are older so the max age must allow for the confirmations
+	skipBlocksOlderThan := cfg.Scan.GetBlockMaxAge(cfg.ChainID)
+
+	var (
+		blockFeed     feeds.BlockFeed
+		checkpointer  *scanner.BlockCheckpointer
+		reorgDetector *scanner.ReorgDetector
+		mempool       *scanner.MempoolFeed
+		catchUpTo     uint64
+		err           error
+	)
+	if len(cfg.Scan.Fixtures.ReplayPath) > 0 {
+		// recorded blocks can be of any age
+		skipBlocksOlderThan = nil
+		blockFeed, err = fixtures.NewReplayFeed(ctx, fortaDirPath(cfg, cfg.Scan.Fixtures.ReplayPath), fixtures.ReplayFeedConfig{
+			WithTiming: cfg.Scan.Fixtures.ReplayWithTiming,
+		})
+	} else {
+		checkpointer = scanner.NewBlockCheckpointer(path.Join(cfg.ChainDir(), config.DefaultCheckpointFileName))
+		if !cfg.Scan.Reorg.Disable {
+			var reorgTraceClient ethereum.Client
+			if cfg.Trace.Enabled {
+				reorgTraceClient = traceClient
+			}
+			reorgDetector = scanner.NewReorgDetector(ctx, ethClient, reorgTraceClient, cfg.Scan.Reorg.MaxDepth, !cfg.Scan.Reorg.DisableReevaluation)
+		}
+		if cfg.Scan.Mempool.Enable {
+			mempoolCfg := cfg.Scan.Mempool
+			if len(mempoolCfg.JsonRpc.Url) == 0 {
+				mempoolCfg.JsonRpc = cfg.Scan.JsonRpc
+			}
+			mempoolCfg.JsonRpc.Url = utils.ConvertToDockerHostURL(mempoolCfg.JsonRpc.Url)
+			mempool, err = scanner.NewMempoolFeed(ctx, mempoolCfg, cfg.ChainID)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		liveFeedCfg := feeds.BlockFeedConfig{
+			ChainID:             chainID,
+			Tracing:             cfg.Trace.Enabled,
+			RateLimit:           rateLimit,
+			SkipBlocksOlderThan: skipBlocksOlderThan,
+			Offset:              confirmations.BlockOffset(),
+		}
+		var catchUpRange *catchup.Range
+		if cfg.Scan.CatchUp.Enable {
+			catchUpRange, err = getCatchUpRange(ctx, ethClient, cfg)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if catchUpRange != nil {
+			// the missed blocks are old so the tx stream skips only the old blocks after them
+			catchUpTo = catchUpRange.To
+			liveFeedCfg.Start = big.NewInt(int64(catchUpRange.To + 1 + uint64(liveFeedCfg.Offset)))
+			blockFeed, err = initCatchUpFeed(ctx, ethClient, traceClient, chainID, cfg, liveFeedCfg, *catchUpRange)
+		} else {
+			blockFeed, err = feeds.NewBlockFeed(ctx, ethClient, traceClient, liveFeedCfg)
+		}
 	}
-	blockFeed, err := feeds.NewBlockFeed(ctx, ethClient, traceClient, feeds.BlockFeedConfig{
-		ChainID:             chainID,
-		Tracing:             cfg.Trace.Enabled,
-		RateLimit:           rateLimit,
-		SkipBlocksOlderThan: &maxAge,
-		Offset:              config.GetBlockOffset(cfg.ChainID),
-	})
 	if err != nil {
 		return nil, nil, err
 	}
 
+	var recorder scanner.EventRecorder
+	if len(cfg.Scan.Fixtures.RecordPath) > 0 {
+		recorder, err = fixtures.NewRecorder(fortaDirPath(cfg, cfg.Scan.Fixtures.RecordPath))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	txStream, err := scanner.NewTxStreamService(ctx, ethClient, blockFeed, scanner.TxStreamServiceConfig{
 		JsonRpcConfig:       cfg.Scan.JsonRpc,
 		TraceJsonRpcConfig:  cfg.Trace.JsonRpc,
-		SkipBlocksOlderThan: &maxAge,
+		SkipBlocksOlderThan: skipBlocksOlderThan,
+		Recorder:            recorder,
+		Throttler:           agentPool,
+		Checkpointer:        checkpointer,
+		ReorgDetector:       reorgDetector,
+		MsgClient:           msgClient,
+		Confirmations:       confirmations,
+		Mempool:             mempool,
+		CatchUpTo:           catchUpTo,
 	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create the tx stream service: %v", err)
@@ -75,21 +152,149 @@ func initTxStream(ctx context.Context, ethClient, traceClient ethereum.Client, c
 	return txStream, blockFeed, nil
 }
 
+// getCatchUpRange finds the blocks which were missed since the last checkpoint.
+func getCatchUpRange(ctx context.Context, ethClient ethereum.Client, cfg config.Config) (*catchup.Range, error) {
+	checkpoint, err := store.ReadCheckpoint(path.Join(cfg.ChainDir(), config.DefaultCheckpointFileName))
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint == nil {
+		log.Info("no checkpoint found - not catching up")
+		return nil, nil
+	}
+
+	latest, err := ethClient.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the latest block number: %v", err)
+	}
+	// the live feed analyzes the blocks behind the latest by the offset
+	offset := uint64(cfg.Scan.GetConfirmations(cfg.ChainID).BlockOffset())
+	if latest.Uint64() < offset {
+		return nil, nil
+	}
+	to := latest.Uint64() - offset
+	from := checkpoint.BlockNumber + 1
+
+	// scan the checkpoint block again if it was reorged out
+	block, err := ethClient.BlockByNumber(ctx, new(big.Int).SetUint64(checkpoint.BlockNumber))
+	if err == nil && block.Hash != checkpoint.BlockHash {
+		log.WithField("block", checkpoint.BlockNumber).Warn("checkpoint block has a different hash now")
+		from = checkpoint.BlockNumber
+	}
+
+	if from > to {
+		return nil, nil
+	}
+	if to-from+1 > cfg.Scan.CatchUp.MaxBlocks {
+		newFrom := to - cfg.Scan.CatchUp.MaxBlocks + 1
+		log.WithFields(log.Fields{
+			"from":    from,
+			"skipped": newFrom - from,
+		}).Warn("too many missed blocks - skipping the oldest ones")
+		from = newFrom
+	}
+	return &catchup.Range{
+		From:   from,
+		To:     to,
+		RateMs: int64(cfg.Scan.CatchUp.BlockRateLimit),
+	}, nil
+}
+
+// initCatchUpFeed creates a feed which scans the missed blocks before the live feed.
+func initCatchUpFeed(
+	ctx context.Context, ethClient, traceClient ethereum.Client, chainID *big.Int, cfg config.Config,
+	liveFeedCfg feeds.BlockFeedConfig, catchUpRange catchup.Range,
+) (feeds.BlockFeed, error) {
+	catchUpFeed, err := feeds.NewBlockFeed(ctx, ethClient, traceClient, feeds.BlockFeedConfig{
+		ChainID: chainID,
+		Tracing: cfg.Trace.Enabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	liveFeed, err := feeds.NewBlockFeed(ctx, ethClient, traceClient, liveFeedCfg)
+	if err != nil {
+		return nil, err
+	}
+	return catchup.NewFeed(catchUpFeed, liveFeed, catchUpRange), nil
+}
+
+// initFeedClient wraps the client so the block feed follows the tagged block, if the confirmations have a tag.
+func initFeedClient(ctx context.Context, ethClient ethereum.Client, failover *jsonrpc.FailoverProxy, cfg config.Config) (ethereum.Client, error) {
+	tag := cfg.Scan.GetConfirmations(cfg.ChainID).Tag
+	if len(tag) == 0 {
+		return ethClient, nil
+	}
+	if !config.GetChainSettings(cfg.ChainID).BlockTags {
+		log.WithField("tag", tag).Warn("chain is not known to support the block tag")
+	}
+	url := utils.ConvertToDockerHostURL(cfg.Scan.JsonRpc.Url)
+	if failover != nil {
+		url = failover.URL()
+	}
+	return scanner.NewTaggedBlockClient(ctx, ethClient, url, tag)
+}
+
+// initEthClient creates a client for the JSON-RPC API. If there are other endpoints to fail over to,
+// the client sends the requests through a local failover proxy.
+func initEthClient(ctx context.Context, apiName string, jsonRpcCfg config.JsonRpcConfig, failoverCfg config.JsonRpcFailoverConfig) (ethereum.Client, *jsonrpc.FailoverProxy, error) {
+	endpoints := jsonRpcCfg.AllEndpoints()
+	if len(endpoints) < 2 {
+		client, err := ethereum.NewStreamEthClient(ctx, apiName, jsonRpcCfg.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, nil, nil
+	}
+
+	for i := range endpoints {
+		endpoints[i].Url = utils.ConvertToDockerHostURL(endpoints[i].Url)
+	}
+	failover, err := jsonrpc.NewFailoverProxy(ctx, apiName, endpoints, failoverCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyUrl, err := failover.Start()
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := ethereum.NewStreamEthClient(ctx, apiName, proxyUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, failover, nil
+}
+
+// fortaDirPath resolves the relative paths from the config in the Forta dir.
+func fortaDirPath(cfg config.Config, p string) string {
+	if path.IsAbs(p) {
+		return p
+	}
+	return path.Join(cfg.FortaDir, p)
+}
+
 func initTxAnalyzer(ctx context.Context, cfg config.Config, as clients.AlertSender, stream *scanner.TxStreamService, ap *agentpool.AgentPool, msgClient clients.MessageClient) (*scanner.TxAnalyzerService, error) {
+	var pendingTxChannel <-chan *domain.TransactionEvent
+	if mempool := stream.Mempool(); mempool != nil {
+		pendingTxChannel = mempool.ReadOnlyPendingTxStream()
+	}
 	return scanner.NewTxAnalyzerService(ctx, scanner.TxAnalyzerServiceConfig{
-		TxChannel:   stream.ReadOnlyTxStream(),
-		AlertSender: as,
-		AgentPool:   ap,
-		MsgClient:   msgClient,
+		TxChannel:        stream.ReadOnlyTxStream(),
+		PendingTxChannel: pendingTxChannel,
+		AlertSender:      as,
+		AgentPool:        ap,
+		MsgClient:        msgClient,
+		FindingValidator: scanner.NewFindingValidator(cfg.Scan.Findings),
 	})
 }
 
 func initBlockAnalyzer(ctx context.Context, cfg config.Config, as clients.AlertSender, stream *scanner.TxStreamService, ap *agentpool.AgentPool, msgClient clients.MessageClient) (*scanner.BlockAnalyzerService, error) {
 	return scanner.NewBlockAnalyzerService(ctx, scanner.BlockAnalyzerServiceConfig{
-		BlockChannel: stream.ReadOnlyBlockStream(),
-		AlertSender:  as,
-		AgentPool:    ap,
-		MsgClient:    msgClient,
+		BlockChannel:     stream.ReadOnlyBlockStream(),
+		AlertSender:      as,
+		AgentPool:        ap,
+		MsgClient:        msgClient,
+		FindingValidator: scanner.NewFindingValidator(cfg.Scan.Findings),
 	})
 }
 
@@ -113,7 +318,7 @@ func initServices(ctx context.Context, cfg config.Config) ([]services.Service, e
 	cfg.Publish.IPFS.GatewayURL = utils.ConvertToDockerHostURL(cfg.Publish.IPFS.GatewayURL)
 	cfg.PrivateModeConfig.WebhookURL = utils.ConvertToDockerHostURL(cfg.PrivateModeConfig.WebhookURL)
 
-	msgClient := messaging.NewClient("scanner", fmt.Sprintf("%s:%s", config.DockerNatsContainerName, config.DefaultNatsPort))
+	msgClient := messaging.NewClient("scanner", fmt.Sprintf("%s:%s", config.DockerNatsContainerName, config.DefaultNatsPort)).ForChain(cfg.ScopedChainID())
 
 	key, err := security.LoadKey(config.DefaultContainerKeyDirPath)
 	if err != nil {
@@ -130,17 +335,24 @@ func initServices(ctx context.Context, cfg config.Config) ([]services.Service, e
 		return nil, err
 	}
 
-	ethClient, err := ethereum.NewStreamEthClient(ctx, "chain", cfg.Scan.JsonRpc.Url)
+	ethClient, ethFailover, err := initEthClient(ctx, "chain", cfg.Scan.JsonRpc, cfg.Scan.JsonRpcFailover)
 	if err != nil {
 		return nil, err
 	}
 
-	traceClient, err := ethereum.NewStreamEthClient(ctx, "trace", cfg.Trace.JsonRpc.Url)
+	traceClient, traceFailover, err := initEthClient(ctx, "trace", cfg.Trace.JsonRpc, cfg.Trace.JsonRpcFailover)
 	if err != nil {
 		return nil, err
 	}
 
-	txStream, blockFeed, err := initTxStream(ctx, ethClient, traceClient, cfg)
+	feedClient, err := initFeedClient(ctx, ethClient, ethFailover, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Scan.Shadow.ReportsDir = path.Join(cfg.ChainDir(), config.DefaultShadowReportsDir)
+	agentPool := agentpool.NewAgentPool(ctx, cfg.Scan, msgClient)
+	txStream, blockFeed, err := initTxStream(ctx, feedClient, traceClient, agentPool, msgClient, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +363,6 @@ func initServices(ctx context.Context, cfg config.Config) ([]services.Service, e
 	}
 
 	registryService := registry.New(cfg, key.Address, msgClient, registryClient)
-	agentPool := agentpool.NewAgentPool(ctx, cfg.Scan, msgClient)
 	txAnalyzer, err := initTxAnalyzer(ctx, cfg, as, txStream, agentPool, msgClient)
 	if err != nil {
 		return nil, err
@@ -166,20 +377,44 @@ func initServices(ctx context.Context, cfg config.Config) ([]services.Service, e
 		blockFeed.Start()
 	}
 
+	reporters := []health.Reporter{
+		ethClient, traceClient, blockFeed, txStream, txAnalyzer, blockAnalyzer, agentPool, registryService,
+		publisherSvc,
+	}
+	if checkpointer := txStream.Checkpointer(); checkpointer != nil {
+		reporters = append(reporters, checkpointer)
+	}
+	if reorgDetector := txStream.ReorgDetector(); reorgDetector != nil {
+		reporters = append(reporters, reorgDetector)
+	}
+	if mempool := txStream.Mempool(); mempool != nil {
+		reporters = append(reporters, mempool)
+	}
+	if taggedClient, ok := feedClient.(*scanner.TaggedBlockClient); ok {
+		reporters = append(reporters, taggedClient)
+	}
+	for _, failover := range []*jsonrpc.FailoverProxy{ethFailover, traceFailover} {
+		if failover != nil {
+			reporters = append(reporters, failover)
+		}
+	}
+
 	svcs := []services.Service{
 		health.NewService(ctx, "", healthutils.DefaultHealthServerErrHandler, health.CheckerFrom(
-			summarizeReports,
-			ethClient, traceClient, blockFeed, txStream, txAnalyzer, blockAnalyzer, agentPool, registryService,
-			publisherSvc,
+			summarizeReports, reporters...,
 		)),
 		txStream,
 		txAnalyzer,
 		blockAnalyzer,
-		scanner.NewScannerAPI(ctx, blockFeed),
+		scanner.NewScannerAPI(ctx, blockFeed, publisherSvc.AlertStore()),
 		scanner.NewTxLogger(ctx),
 		publisherSvc,
 	}
 
+	if mempool := txStream.Mempool(); mempool != nil {
+		svcs = append(svcs, mempool)
+	}
+
 	// for performance tests, this flag avoids using registry service
 	if !cfg.Registry.Disable {
 		svcs = append(svcs, registryService)
@@ -235,6 +470,14 @@ func summarizeReports(reports health.Reports) *health.Report {
 		summary.Addf("at block %s.", lastBlock.Details)
 	}
 
+	throttling, ok := reports.NameContains("agent-pool.agents.throttling")
+	if ok && throttling.Details == "true" {
+		lagging, ok := reports.NameContains("agent-pool.agents.lagging")
+		if ok {
+			summary.Addf("throttling the block feed because %s agents are lagging.", lagging.Details)
+		}
+	}
+
 	// report block request failures but ignore "not found"s because we hit them when we are
 	// asking for the latest block that is not just yet available
 	blockByNumberErr, ok := reports.NameContains("chain-json-rpc-client.request.block-by-number.error")
@@ -273,6 +516,16 @@ func summarizeReports(reports health.Reports) *health.Report {
 		summary.Status(health.StatusFailing)
 	}
 
+	outboxPending, ok := reports.NameContains("publisher.outbox.pending")
+	if ok && len(outboxPending.Details) > 0 && outboxPending.Details != "0" {
+		summary.Punc(".")
+		summary.Addf("%s batches are waiting to be published", outboxPending.Details)
+		outboxOldestAge, ok := reports.NameContains("publisher.outbox.oldest-age")
+		if ok && outboxOldestAge.Status == health.StatusLagging {
+			summary.Addf("and the oldest one is waiting for %s", outboxOldestAge.Details)
+		}
+	}
+
 	return summary.Finish()
 }
 
@@ -49,11 +49,23 @@ func summarizeReports(reports health.Reports) *health.Report {
 	containersManager, ok := reports.NameContains("containers.managed")
 	if ok {
 		count, _ := strconv.Atoi(containersManager.Details)
-		if count < config.DockerSupervisorManagedContainers {
-			summary.Addf("missing %d containers.", config.DockerSupervisorManagedContainers-count)
+		expected := config.DockerSupervisorManagedContainers
+		if containersExpected, ok := reports.NameContains("containers.expected"); ok {
+			expected, _ = strconv.Atoi(containersExpected.Details)
+		}
+		if count < expected {
+			summary.Addf("missing %d containers.", expected-count)
 			summary.Status(health.StatusFailing)
 		} else {
-			summary.Addf("all %d service containers are running.", config.DockerSupervisorManagedContainers)
+			summary.Addf("all %d service containers are running.", expected)
+		}
+	}
+
+	containersFailed, ok := reports.NameContains("containers.failed")
+	if ok && len(containersFailed.Details) > 0 {
+		summary.Addf("containers failed after too many restarts: %s.", containersFailed.Details)
+		if containersFailed.Status == health.StatusFailing {
+			summary.Status(health.StatusFailing)
 		}
 	}
 
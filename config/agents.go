@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/forta-network/forta-core-go/protocol"
 	"github.com/forta-network/forta-core-go/utils"
@@ -12,12 +14,63 @@ const (
 )
 
 type AgentConfig struct {
-	ID         string  `yaml:"id" json:"id"`
-	Image      string  `yaml:"image" json:"image"`
-	Manifest   string  `yaml:"manifest" json:"manifest"`
-	IsLocal    bool    `yaml:"isLocal" json:"isLocal"`
-	StartBlock *uint64 `yaml:"startBlock" json:"startBlock,omitempty"`
-	StopBlock  *uint64 `yaml:"stopBlock" json:"stopBlock,omitempty"`
+	ID         string     `yaml:"id" json:"id"`
+	Image      string     `yaml:"image" json:"image"`
+	Manifest   string     `yaml:"manifest" json:"manifest"`
+	IsLocal    bool       `yaml:"isLocal" json:"isLocal"`
+	StartBlock *uint64    `yaml:"startBlock" json:"startBlock,omitempty"`
+	StopBlock  *uint64    `yaml:"stopBlock" json:"stopBlock,omitempty"`
+	Filters    *TxFilters `yaml:"filters" json:"filters,omitempty"`
+	// Concurrency is the max number of tx requests that can be in flight for the agent.
+	Concurrency int `yaml:"concurrency" json:"concurrency,omitempty"`
+	// BlockOrdering makes the agent finish the tx requests from a block before the next block.
+	BlockOrdering bool `yaml:"blockOrdering" json:"blockOrdering,omitempty"`
+	// AlertSubscriptions are the alerts from the other agents on the node that the agent receives.
+	AlertSubscriptions []AlertSubscription `yaml:"alertSubscriptions" json:"alertSubscriptions,omitempty"`
+	// PendingTxs makes the agent receive the pending transactions from the mempool as well.
+	PendingTxs bool `yaml:"pendingTransactions" json:"pendingTransactions,omitempty"`
+	// TimeoutSeconds is the timeout of the requests to the agent.
+	TimeoutSeconds int `yaml:"timeoutSeconds" json:"timeoutSeconds,omitempty"`
+	// MaxFindings is the max number of findings that are kept from a response.
+	MaxFindings int `yaml:"maxFindings" json:"maxFindings,omitempty"`
+	// ChainID is set if the agent runs for one of the additional chains.
+	ChainID int `yaml:"-" json:"chainId,omitempty"`
+}
+
+// TxFilters limits the transactions that are sent to an agent. A transaction is sent
+// if it matches any of the filters. All transactions are sent if there are no filters.
+type TxFilters struct {
+	// Addresses are matched with all of the addresses involved in the transaction.
+	Addresses []string `yaml:"addresses" json:"addresses,omitempty"`
+	// Topics are matched with the log topics.
+	Topics []string `yaml:"topics" json:"topics,omitempty"`
+	// Selectors are matched with the first four bytes of the transaction input.
+	Selectors []string `yaml:"selectors" json:"selectors,omitempty"`
+}
+
+// AlertSubscription subscribes to the alerts of an agent.
+type AlertSubscription struct {
+	AgentID string `yaml:"agentId" json:"agentId"`
+	// AlertID is a pattern like "SUSPICIOUS-*" to match the alert IDs of the findings.
+	// All alerts of the agent are matched if it is empty.
+	AlertID string `yaml:"alertId" json:"alertId,omitempty"`
+}
+
+// Matches tells if the alert from the agent matches the subscription.
+func (sub AlertSubscription) Matches(agentID, alertID string) bool {
+	if !strings.EqualFold(sub.AgentID, agentID) {
+		return false
+	}
+	if len(sub.AlertID) == 0 {
+		return true
+	}
+	matched, _ := path.Match(sub.AlertID, alertID)
+	return matched
+}
+
+// IsEmpty tells if there are no filters.
+func (filters *TxFilters) IsEmpty() bool {
+	return filters == nil || (len(filters.Addresses) == 0 && len(filters.Topics) == 0 && len(filters.Selectors) == 0)
 }
 
 // ToAgentInfo transforms the agent config to the agent info.
@@ -37,10 +90,16 @@ func (ac AgentConfig) ImageHash() string {
 
 func (ac AgentConfig) ContainerName() string {
 	_, digest := utils.SplitImageRef(ac.Image)
+	var name string
 	if ac.IsLocal {
-		return fmt.Sprintf("%s-agent-%s", ContainerNamePrefix, utils.ShortenString(ac.ID, 8))
+		name = fmt.Sprintf("%s-agent-%s", ContainerNamePrefix, utils.ShortenString(ac.ID, 8))
+	} else {
+		name = fmt.Sprintf("%s-agent-%s-%s", ContainerNamePrefix, utils.ShortenString(ac.ID, 8), utils.ShortenString(digest, 4))
+	}
+	if ac.ChainID != 0 {
+		name = fmt.Sprintf("%s-%d", name, ac.ChainID)
 	}
-	return fmt.Sprintf("%s-agent-%s-%s", ContainerNamePrefix, utils.ShortenString(ac.ID, 8), utils.ShortenString(digest, 4))
+	return name
 }
 
 func (ac AgentConfig) GrpcPort() string {
@@ -13,6 +13,8 @@ type ChainSettings struct {
 	ChainID             int
 	Offset              int
 	JsonRpcRateLimiting *RateLimitConfig
+	// BlockTags is true if the chain has the "finalized" and "safe" blocks.
+	BlockTags bool
 }
 
 var allChainSettings = []ChainSettings{
@@ -21,6 +23,7 @@ var allChainSettings = []ChainSettings{
 		ChainID:             1,
 		Offset:              defaultBlockOffset,
 		JsonRpcRateLimiting: defaultRateLimiting,
+		BlockTags:           true,
 	},
 	{
 		Name:                "BSC",
@@ -45,12 +48,14 @@ var allChainSettings = []ChainSettings{
 		ChainID:             42161,
 		Offset:              defaultBlockOffset,
 		JsonRpcRateLimiting: defaultRateLimiting,
+		BlockTags:           true,
 	},
 	{
 		Name:                "Optimism",
 		ChainID:             10,
 		Offset:              defaultBlockOffset,
 		JsonRpcRateLimiting: defaultRateLimiting,
+		BlockTags:           true,
 	},
 }
 
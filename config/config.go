@@ -2,8 +2,13 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/creasty/defaults"
 )
@@ -11,20 +16,242 @@ import (
 type JsonRpcConfig struct {
 	Url     string            `yaml:"url" json:"url" validate:"omitempty,url"`
 	Headers map[string]string `yaml:"headers" json:"headers"`
+	// Endpoints are the other endpoints that the scanner fails over to. All of the urls
+	// must be http urls when there are other endpoints.
+	Endpoints []JsonRpcEndpointConfig `yaml:"endpoints" json:"endpoints" validate:"dive"`
+}
+
+// JsonRpcEndpointConfig is an endpoint to fail over to. Lower priority values are preferred
+// and the main url has the priority zero.
+type JsonRpcEndpointConfig struct {
+	Url      string            `yaml:"url" json:"url" validate:"url"`
+	Headers  map[string]string `yaml:"headers" json:"headers"`
+	Priority int               `yaml:"priority" json:"priority"`
+}
+
+// AllEndpoints returns the main url and the other endpoints in the priority order.
+func (cfg JsonRpcConfig) AllEndpoints() []JsonRpcEndpointConfig {
+	var endpoints []JsonRpcEndpointConfig
+	if len(cfg.Url) > 0 {
+		endpoints = append(endpoints, JsonRpcEndpointConfig{
+			Url:     cfg.Url,
+			Headers: cfg.Headers,
+		})
+	}
+	endpoints = append(endpoints, cfg.Endpoints...)
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Priority < endpoints[j].Priority
+	})
+	return endpoints
+}
+
+type JsonRpcFailoverConfig struct {
+	HealthCheckIntervalSeconds int    `yaml:"healthCheckIntervalSeconds" json:"healthCheckIntervalSeconds" default:"15" validate:"min=1"`
+	MaxLagBlocks               uint64 `yaml:"maxLagBlocks" json:"maxLagBlocks" default:"10"`
+}
+
+type FixturesConfig struct {
+	RecordPath       string `yaml:"recordPath" json:"recordPath"`
+	ReplayPath       string `yaml:"replayPath" json:"replayPath"`
+	ReplayWithTiming bool   `yaml:"replayWithTiming" json:"replayWithTiming"`
 }
 
 type ScannerConfig struct {
-	StartBlock         int           `yaml:"-" json:"_startBlock"`
-	EndBlock           int           `yaml:"-" json:"_endBlock"`
-	JsonRpc            JsonRpcConfig `yaml:"jsonRpc" json:"jsonRpc"`
-	DisableAutostart   bool          `yaml:"disableAutostart" json:"disableAutostart"`
-	BlockRateLimit     int           `yaml:"blockRateLimit" json:"blockRateLimit" default:"200"`
-	BlockMaxAgeSeconds int64         `json:"blockMaxAgeSeconds" json:"blockMaxAgeSeconds" default:"600"`
+	StartBlock         int            `yaml:"-" json:"_startBlock"`
+	EndBlock           int            `yaml:"-" json:"_endBlock"`
+	JsonRpc            JsonRpcConfig  `yaml:"jsonRpc" json:"jsonRpc"`
+	DisableAutostart   bool           `yaml:"disableAutostart" json:"disableAutostart"`
+	BlockRateLimit     int            `yaml:"blockRateLimit" json:"blockRateLimit" default:"200"`
+	BlockMaxAgeSeconds int64          `json:"blockMaxAgeSeconds" json:"blockMaxAgeSeconds" default:"600"`
+	Fixtures           FixturesConfig `yaml:"fixtures" json:"fixtures"`
+	// AgentConcurrency is the default max number of tx requests in flight per agent.
+	AgentConcurrency int `yaml:"agentConcurrency" json:"agentConcurrency" default:"1" validate:"min=1"`
+	// AgentBlockOrdering makes the agents finish the tx requests from a block before the next block.
+	AgentBlockOrdering bool                  `yaml:"agentBlockOrdering" json:"agentBlockOrdering"`
+	Backpressure       BackpressureConfig    `yaml:"backpressure" json:"backpressure"`
+	JsonRpcFailover    JsonRpcFailoverConfig `yaml:"jsonRpcFailover" json:"jsonRpcFailover"`
+	CatchUp            CatchUpConfig         `yaml:"catchUp" json:"catchUp"`
+	Reorg              ReorgConfig           `yaml:"reorg" json:"reorg"`
+	// Confirmations overrides the default confirmations of the chains, by the chain ID.
+	Confirmations map[int]ConfirmationsConfig `yaml:"confirmations" json:"confirmations" validate:"dive"`
+	Mempool       MempoolConfig               `yaml:"mempool" json:"mempool"`
+	Shadow        ShadowConfig                `yaml:"shadow" json:"shadow"`
+	Findings      FindingsConfig              `yaml:"findings" json:"findings"`
+	AgentLimits   AgentLimitsConfig           `yaml:"agentLimits" json:"agentLimits"`
+}
+
+// Block tags for the confirmations
+const (
+	BlockTagFinalized = "finalized"
+	BlockTagSafe      = "safe"
+)
+
+// maxExpectedBlockTime is a conservative block time to estimate the lag of the blocks behind the latest.
+const maxExpectedBlockTime = time.Second * 15
+
+// ConfirmationsConfig decides how far behind the latest block the scanner stays
+// so the blocks are less likely to be reorged after they are scanned.
+type ConfirmationsConfig struct {
+	// Depth is how many blocks behind the latest block to scan.
+	Depth *int `yaml:"depth" json:"depth" validate:"omitempty,min=0"`
+	// Tag makes the scanner follow the "finalized" or "safe" block instead of a fixed depth.
+	Tag string `yaml:"tag" json:"tag" validate:"omitempty,oneof=finalized safe"`
+}
+
+// BlockOffset returns the block feed offset. The tagged blocks do not need an offset.
+func (cfg ConfirmationsConfig) BlockOffset() int {
+	if len(cfg.Tag) > 0 || cfg.Depth == nil {
+		return 0
+	}
+	return *cfg.Depth
+}
+
+// MaxBlockAge returns how old the blocks can be before the block feed skips them. The blocks which
+// are the depth behind the latest are already older by about the depth times the block time. The age
+// of the tagged blocks varies too much so they are never skipped.
+func (cfg ConfirmationsConfig) MaxBlockAge(maxAge time.Duration) *time.Duration {
+	if maxAge <= 0 || len(cfg.Tag) > 0 {
+		return nil
+	}
+	maxAge += time.Duration(cfg.BlockOffset()) * maxExpectedBlockTime
+	return &maxAge
+}
+
+// Validate checks that the depth and the tag are not set together.
+func (cfg ConfirmationsConfig) Validate() error {
+	if len(cfg.Tag) > 0 && cfg.Depth != nil {
+		return fmt.Errorf("confirmations can have either the depth (%d) or the tag (%s)", *cfg.Depth, cfg.Tag)
+	}
+	return nil
+}
+
+func (cfg ConfirmationsConfig) String() string {
+	if len(cfg.Tag) > 0 {
+		return fmt.Sprintf("following the %s block", cfg.Tag)
+	}
+	return fmt.Sprintf("%d block(s) behind the latest block", cfg.BlockOffset())
+}
+
+// GetConfirmations returns the configured confirmations for the chain or the chain default.
+func (cfg ScannerConfig) GetConfirmations(chainID int) ConfirmationsConfig {
+	confirmations, ok := cfg.Confirmations[chainID]
+	if ok && (confirmations.Depth != nil || len(confirmations.Tag) > 0) {
+		return confirmations
+	}
+	offset := GetBlockOffset(chainID)
+	return ConfirmationsConfig{Depth: &offset}
+}
+
+// GetBlockMaxAge returns how old the scanned blocks of the chain can be, given the confirmations.
+// It is nil if the old blocks should not be skipped.
+func (cfg ScannerConfig) GetBlockMaxAge(chainID int) *time.Duration {
+	return cfg.GetConfirmations(chainID).MaxBlockAge(time.Duration(cfg.BlockMaxAgeSeconds) * time.Second)
+}
+
+// FindingsConfig limits the findings from the agents. The findings which exceed the limits
+// are repaired before they become alerts.
+type FindingsConfig struct {
+	MaxNameLength        int `yaml:"maxNameLength" json:"maxNameLength" default:"256" validate:"min=1"`
+	MaxDescriptionLength int `yaml:"maxDescriptionLength" json:"maxDescriptionLength" default:"2048" validate:"min=1"`
+	MaxAddresses         int `yaml:"maxAddresses" json:"maxAddresses" default:"100" validate:"min=0"`
+	MaxMetadataKeys      int `yaml:"maxMetadataKeys" json:"maxMetadataKeys" default:"50" validate:"min=0"`
+	// MaxMetadataSize is the max total length of the metadata keys and values.
+	MaxMetadataSize int `yaml:"maxMetadataSize" json:"maxMetadataSize" default:"10240" validate:"min=0"`
+}
+
+// AgentLimitsConfig contains the defaults and the node-wide bounds of the agent settings
+// which can be set per agent.
+type AgentLimitsConfig struct {
+	DefaultTimeoutSeconds int `yaml:"defaultTimeoutSeconds" json:"defaultTimeoutSeconds" default:"30" validate:"min=1"`
+	MaxTimeoutSeconds     int `yaml:"maxTimeoutSeconds" json:"maxTimeoutSeconds" default:"300" validate:"gtefield=DefaultTimeoutSeconds"`
+	DefaultMaxFindings    int `yaml:"defaultMaxFindings" json:"defaultMaxFindings" default:"10" validate:"min=1"`
+	MaxFindings           int `yaml:"maxFindings" json:"maxFindings" default:"50" validate:"gtefield=DefaultMaxFindings"`
+}
+
+// ApplyTo sets the defaults for the agent settings which are not set
+// and keeps the agent settings within the bounds.
+func (limits AgentLimitsConfig) ApplyTo(agentCfg *AgentConfig) {
+	agentCfg.TimeoutSeconds = applyLimit(agentCfg.TimeoutSeconds, limits.DefaultTimeoutSeconds, limits.MaxTimeoutSeconds)
+	agentCfg.MaxFindings = applyLimit(agentCfg.MaxFindings, limits.DefaultMaxFindings, limits.MaxFindings)
+}
+
+func applyLimit(value, defaultValue, maxValue int) int {
+	if value <= 0 {
+		value = defaultValue
+	}
+	if maxValue > 0 && value > maxValue {
+		value = maxValue
+	}
+	return value
+}
+
+// ShadowConfig makes the new agent versions run next to the old versions for a while
+// before the old versions are stopped. Only the findings of the old versions are published.
+type ShadowConfig struct {
+	Enable bool `yaml:"enable" json:"enable"`
+	// Blocks is how many blocks both versions run for before the switch.
+	Blocks int `yaml:"blocks" json:"blocks" default:"100" validate:"min=1"`
+	// ReportsDir is where the diff reports of the versions are written to.
+	ReportsDir string `yaml:"-" json:"-"`
+}
+
+// Mempool feed modes
+const (
+	MempoolModeSubscribe = "subscribe"
+	MempoolModePoll      = "poll"
+)
+
+// MempoolConfig configures the pending transaction feed for the agents which opt in.
+type MempoolConfig struct {
+	Enable bool `yaml:"enable" json:"enable"`
+	// Mode is either "subscribe" to use eth_subscribe or "poll" to poll txpool_content.
+	Mode string `yaml:"mode" json:"mode" default:"subscribe" validate:"oneof=subscribe poll"`
+	// JsonRpc is the API to read the pending transactions from. The scan API is used by default
+	// and it should be a websocket URL for the subscriptions, otherwise the feed polls.
+	JsonRpc             JsonRpcConfig `yaml:"jsonRpc" json:"jsonRpc"`
+	PollIntervalSeconds int           `yaml:"pollIntervalSeconds" json:"pollIntervalSeconds" default:"2" validate:"min=1"`
+	// RateLimit is the max number of pending transactions sent to the agents per second.
+	RateLimit int `yaml:"rateLimit" json:"rateLimit" default:"100" validate:"min=1"`
+}
+
+// ReorgConfig configures how the scanner handles the chain reorgs.
+type ReorgConfig struct {
+	Disable bool `yaml:"disable" json:"disable"`
+	// MaxDepth is how many of the latest block hashes are kept to compare with.
+	MaxDepth int `yaml:"maxDepth" json:"maxDepth" default:"64" validate:"min=1"`
+	// DisableReevaluation stops sending the canonical replacements of the orphaned blocks to the agents.
+	DisableReevaluation bool `yaml:"disableReevaluation" json:"disableReevaluation"`
+}
+
+// CatchUpConfig makes the scanner scan the blocks it missed since the last checkpoint before following the chain.
+type CatchUpConfig struct {
+	Enable bool `yaml:"enable" json:"enable"`
+	// MaxBlocks limits how many of the latest missed blocks are scanned.
+	MaxBlocks uint64 `yaml:"maxBlocks" json:"maxBlocks" default:"1000" validate:"min=1"`
+	// BlockRateLimit is the wait between the missed blocks in milliseconds.
+	BlockRateLimit int `yaml:"blockRateLimit" json:"blockRateLimit" default:"50"`
+}
+
+// Backpressure policies
+const (
+	// BackpressurePolicyDrop drops the requests for the agents with full buffers.
+	BackpressurePolicyDrop = "drop"
+	// BackpressurePolicyThrottle slows down the block feed while too many agents are lagging.
+	BackpressurePolicyThrottle = "throttle"
+)
+
+type BackpressureConfig struct {
+	Policy string `yaml:"policy" json:"policy" default:"drop" validate:"oneof=drop throttle"`
+	// LaggingRatio is the fraction of the agents with full buffers that starts throttling.
+	LaggingRatio float64 `yaml:"laggingRatio" json:"laggingRatio" default:"0.5" validate:"gt=0,lte=1"`
+	// MaxWaitSeconds limits how long a block can be held back.
+	MaxWaitSeconds int `yaml:"maxWaitSeconds" json:"maxWaitSeconds" default:"60" validate:"min=1"`
 }
 
 type TraceConfig struct {
-	JsonRpc JsonRpcConfig `yaml:"jsonRpc" json:"jsonRpc"`
-	Enabled bool          `yaml:"enabled" json:"enabled"`
+	JsonRpc         JsonRpcConfig         `yaml:"jsonRpc" json:"jsonRpc"`
+	Enabled         bool                  `yaml:"enabled" json:"enabled"`
+	JsonRpcFailover JsonRpcFailoverConfig `yaml:"jsonRpcFailover" json:"jsonRpcFailover"`
 }
 
 type RateLimitConfig struct {
@@ -65,6 +292,8 @@ type BatchConfig struct {
 	SkipEmpty       bool `yaml:"skipEmpty" json:"skipEmpty"`
 	IntervalSeconds *int `yaml:"intervalSeconds" json:"intervalSeconds" default:"15" `
 	MaxAlerts       *int `yaml:"maxAlerts" json:"maxAlerts" default:"1000" `
+	// FastPathSeverity closes and publishes the batch as soon as an alert with this severity or higher arrives.
+	FastPathSeverity string `yaml:"fastPathSeverity" json:"fastPathSeverity" validate:"omitempty,oneof=INFO LOW MEDIUM HIGH CRITICAL"`
 }
 
 type TestAlertsConfig struct {
@@ -80,12 +309,103 @@ type PublisherConfig struct {
 	TestAlerts  TestAlertsConfig `yaml:"testAlerts" json:"testAlerts"`
 }
 
+type LocalAlertsConfig struct {
+	Disable        bool   `yaml:"disable" json:"disable"`
+	RetentionHours int    `yaml:"retentionHours" json:"retentionHours" default:"168" validate:"min=1"`
+	Port           string `yaml:"port" json:"port" default:"8989"`
+}
+
 type ResourcesConfig struct {
 	DisableAgentLimits bool    `yaml:"disableAgentLimits" json:"disableAgentLimits" default:"false" `
 	AgentMaxMemoryMiB  int     `yaml:"agentMaxMemoryMib" json:"agentMaxMemoryMib" validate:"omitempty,min=100"`
 	AgentMaxCPUs       float64 `yaml:"agentMaxCpus" json:"agentMaxCpus" validate:"omitempty,gt=0"`
 }
 
+// AgentSecurityConfig hardens the agent containers. By default, the agents only run without
+// new privileges, with a PID limit and with the seccomp profile. The strict mode is opt-in
+// because it breaks the existing agents which write to the disk or run as root.
+type AgentSecurityConfig struct {
+	Disable bool `yaml:"disable" json:"disable"`
+	// Strict runs the agents with a read-only root filesystem, a noexec /tmp dir, the non-root
+	// user and without the capabilities.
+	Strict bool `yaml:"strict" json:"strict"`
+	// User is the non-root user and group that the agents run as in the strict mode.
+	User      string `yaml:"user" json:"user" default:"65534:65534"`
+	PidsLimit int64  `yaml:"pidsLimit" json:"pidsLimit" default:"256" validate:"min=1"`
+	// TmpfsSizeMiB is the size of the writable /tmp dir in the read-only root filesystem.
+	TmpfsSizeMiB int `yaml:"tmpfsSizeMib" json:"tmpfsSizeMib" default:"64" validate:"min=1"`
+	// SeccompProfile is the path of a seccomp profile file, relative to the Forta dir.
+	// The Docker default profile is used if it is not set.
+	SeccompProfile string `yaml:"seccompProfile" json:"seccompProfile"`
+	// CompatibilityAgents are the IDs or the images of the agents which are excluded from the
+	// strict mode as they need a writable root filesystem, the root user or the default capabilities.
+	CompatibilityAgents []string `yaml:"compatibilityAgents" json:"compatibilityAgents"`
+}
+
+// IsCompatibilityAgent tells if the agent should run in the compatibility mode.
+func (cfg AgentSecurityConfig) IsCompatibilityAgent(agent AgentConfig) bool {
+	for _, agentRef := range cfg.CompatibilityAgents {
+		if strings.EqualFold(agentRef, agent.ID) || agentRef == agent.Image {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentEgressConfig limits the network access of the agents.
+type AgentEgressConfig struct {
+	// Restrict places the agents on internal networks which can only reach the JSON-RPC proxy
+	// and the allowed hosts through the egress proxy.
+	Restrict bool `yaml:"restrict" json:"restrict"`
+	// AllowedHosts are the hosts like "api.example.com" or "*.example.com" that all restricted agents can reach.
+	AllowedHosts []string `yaml:"allowedHosts" json:"allowedHosts"`
+	// AgentAllowedHosts are the additional allowed hosts by the agent ID.
+	AgentAllowedHosts map[string][]string `yaml:"agentAllowedHosts" json:"agentAllowedHosts"`
+	// UnrestrictedAgents are the IDs or the images of the agents which keep the public network.
+	UnrestrictedAgents []string `yaml:"unrestrictedAgents" json:"unrestrictedAgents"`
+}
+
+// IsRestricted tells if the agent should be placed on an internal network.
+func (cfg AgentEgressConfig) IsRestricted(agent AgentConfig) bool {
+	if !cfg.Restrict {
+		return false
+	}
+	for _, agentRef := range cfg.UnrestrictedAgents {
+		if strings.EqualFold(agentRef, agent.ID) || agentRef == agent.Image {
+			return false
+		}
+	}
+	return true
+}
+
+// NeedsProxy tells if the egress proxy is needed for reaching the allowed hosts.
+func (cfg AgentEgressConfig) NeedsProxy() bool {
+	return cfg.Restrict && (len(cfg.AllowedHosts) > 0 || len(cfg.AgentAllowedHosts) > 0)
+}
+
+// IsHostAllowed tells if the agent can reach the host.
+func (cfg AgentEgressConfig) IsHostAllowed(agentID, host string) bool {
+	return matchesHost(cfg.AllowedHosts, host) || matchesHost(cfg.AgentAllowedHosts[agentID], host)
+}
+
+func matchesHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(host)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RestartPolicyConfig decides how the exited containers are restarted. The containers which
+// exit too many times in the window are marked as failed and are not restarted anymore.
+type RestartPolicyConfig struct {
+	InitialBackoffSeconds int `yaml:"initialBackoffSeconds" json:"initialBackoffSeconds" default:"5" validate:"min=1"`
+	MaxBackoffSeconds     int `yaml:"maxBackoffSeconds" json:"maxBackoffSeconds" default:"300" validate:"gtefield=InitialBackoffSeconds"`
+	MaxRestarts           int `yaml:"maxRestarts" json:"maxRestarts" default:"5" validate:"min=1"`
+	WindowSeconds         int `yaml:"windowSeconds" json:"windowSeconds" default:"3600" validate:"min=1"`
+}
+
 type ENSConfig struct {
 	DefaultContract bool          `yaml:"defaultContract" json:"defaultContract" default:"false" `
 	ContractAddress string        `yaml:"contractAddress" json:"contractAddress" validate:"omitempty,eth_addr" default:"0x08f42fcc52a9C2F391bF507C4E8688D0b53e1bd7"`
@@ -118,6 +438,26 @@ type PrivateModeConfig struct {
 	AgentImages       []string                 `yaml:"agentImages" json:"agentImages" validate:"required_if=Enable true"`
 	WebhookURL        string                   `yaml:"webhookUrl" json:"webhookUrl" validate:"required_if=Enable true"`
 	ContainerRegistry *ContainerRegistryConfig `yaml:"containerRegistry" json:"containerRegistry"`
+	// AgentFilters are the transaction filters for the agent images.
+	AgentFilters map[string]*TxFilters `yaml:"agentFilters" json:"agentFilters"`
+	// PendingTxAgents are the agent images which receive the pending transactions.
+	PendingTxAgents map[string]bool `yaml:"pendingTxAgents" json:"pendingTxAgents"`
+	// AgentAlertSubscriptions are the alert subscriptions for the agent images.
+	AgentAlertSubscriptions map[string][]AlertSubscription `yaml:"agentAlertSubscriptions" json:"agentAlertSubscriptions"`
+	// AgentTimeoutSeconds are the request timeouts for the agent images.
+	AgentTimeoutSeconds map[string]int `yaml:"agentTimeoutSeconds" json:"agentTimeoutSeconds"`
+	// AgentMaxFindings are the max numbers of findings per request for the agent images.
+	AgentMaxFindings map[string]int `yaml:"agentMaxFindings" json:"agentMaxFindings"`
+}
+
+// ChainConfig is an additional chain to scan with a separate scanner and JSON-RPC proxy.
+type ChainConfig struct {
+	ChainID      int                `yaml:"chainId" json:"chainId" validate:"required"`
+	Scan         ScannerConfig      `yaml:"scan" json:"scan"`
+	Trace        TraceConfig        `yaml:"trace" json:"trace"`
+	JsonRpcProxy JsonRpcProxyConfig `yaml:"jsonRpcProxy" json:"jsonRpcProxy"`
+	// LocalAlertsPort is the host port for querying the local alerts from this chain.
+	LocalAlertsPort string `yaml:"localAlertsPort" json:"localAlertsPort"`
 }
 
 type Config struct {
@@ -133,6 +473,7 @@ type Config struct {
 	AgentRegistryContractAddress   string         `yaml:"-" json:"_agentRegistryContractAddress"`
 	ScannerVersionContractAddress  string         `yaml:"-" json:"_scannerVersionContractAddress"`
 	ScannerRegistryContractAddress string         `yaml:"-" json:"_scannerRegistryContractAddress"`
+	PrimaryChainID                 int            `yaml:"-" json:"_primaryChainId"`
 
 	// yaml config values
 
@@ -151,6 +492,52 @@ type Config struct {
 	AutoUpdate        AutoUpdateConfig   `yaml:"autoUpdate" json:"autoUpdate"`
 	AgentLogsConfig   AgentLogsConfig    `yaml:"agentLogs" json:"agentLogs"`
 	PrivateModeConfig PrivateModeConfig  `yaml:"privateMode" json:"privateMode"`
+	LocalAlerts       LocalAlertsConfig  `yaml:"localAlerts" json:"localAlerts"`
+	Chains            []ChainConfig      `yaml:"chains" json:"chains" validate:"dive"`
+	// AgentSecurity is the hardening profile of the agent containers.
+	AgentSecurity AgentSecurityConfig `yaml:"agentSecurity" json:"agentSecurity"`
+	// AgentEgress is the network policy of the agent containers.
+	AgentEgress AgentEgressConfig `yaml:"agentEgress" json:"agentEgress"`
+	// RestartPolicy applies to the agents and the node containers managed by the supervisor.
+	RestartPolicy RestartPolicyConfig `yaml:"restartPolicy" json:"restartPolicy"`
+}
+
+// ForChain returns the config for scanning one of the additional chains.
+func (cfg Config) ForChain(chainID int) (Config, error) {
+	if chainID == cfg.ChainID {
+		return cfg, nil
+	}
+	for _, chainCfg := range cfg.Chains {
+		if chainCfg.ChainID != chainID {
+			continue
+		}
+		cfg.PrimaryChainID = cfg.ChainID
+		cfg.ChainID = chainCfg.ChainID
+		cfg.Scan = chainCfg.Scan
+		cfg.Trace = chainCfg.Trace
+		cfg.JsonRpcProxy = chainCfg.JsonRpcProxy
+		cfg.LocalAlerts.Port = chainCfg.LocalAlertsPort
+		return cfg, nil
+	}
+	return Config{}, fmt.Errorf("chain %d is not configured", chainID)
+}
+
+// ScopedChainID returns the chain ID if the config is for one of the additional chains
+// and zero if it is for the primary chain.
+func (cfg Config) ScopedChainID() int {
+	if cfg.PrimaryChainID == 0 || cfg.PrimaryChainID == cfg.ChainID {
+		return 0
+	}
+	return cfg.ChainID
+}
+
+// ChainDir returns the directory to keep the chain-specific data like the checkpoints and the alerts.
+func (cfg Config) ChainDir() string {
+	chainID := cfg.ScopedChainID()
+	if chainID == 0 {
+		return cfg.FortaDir
+	}
+	return path.Join(cfg.FortaDir, DefaultChainsDirName, strconv.Itoa(chainID))
 }
 
 func (cfg *Config) ConfigFilePath() string {
@@ -167,6 +554,15 @@ func GetConfigForContainer() (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	if chainIDStr := os.Getenv(EnvChainID); len(chainIDStr) > 0 {
+		chainID, err := strconv.Atoi(chainIDStr)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid $%s: %v", EnvChainID, err)
+		}
+		if cfg, err = cfg.ForChain(chainID); err != nil {
+			return Config{}, err
+		}
+	}
 	applyContextDefaults(&cfg)
 	return cfg, nil
 }
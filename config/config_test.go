@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ForChain(t *testing.T) {
+	r := require.New(t)
+
+	cfg := Config{
+		ChainID:  1,
+		FortaDir: "/forta",
+		Chains: []ChainConfig{
+			{ChainID: 137, Scan: ScannerConfig{JsonRpc: JsonRpcConfig{Url: "https://polygon"}}},
+		},
+	}
+	r.Equal(0, cfg.ScopedChainID())
+	r.Equal("/forta", cfg.ChainDir())
+
+	chainCfg, err := cfg.ForChain(137)
+	r.NoError(err)
+	r.Equal(137, chainCfg.ChainID)
+	r.Equal(137, chainCfg.ScopedChainID())
+	r.Equal("https://polygon", chainCfg.Scan.JsonRpc.Url)
+	r.Equal("/forta/chains/137", chainCfg.ChainDir())
+
+	_, err = cfg.ForChain(56)
+	r.Error(err)
+}
+
+func TestAgentLimitsConfig_ApplyTo(t *testing.T) {
+	r := require.New(t)
+
+	limits := AgentLimitsConfig{
+		DefaultTimeoutSeconds: 30,
+		MaxTimeoutSeconds:     300,
+		DefaultMaxFindings:    10,
+		MaxFindings:           50,
+	}
+
+	agentCfg := AgentConfig{}
+	limits.ApplyTo(&agentCfg)
+	r.Equal(30, agentCfg.TimeoutSeconds)
+	r.Equal(10, agentCfg.MaxFindings)
+
+	agentCfg = AgentConfig{TimeoutSeconds: 600, MaxFindings: 20}
+	limits.ApplyTo(&agentCfg)
+	r.Equal(300, agentCfg.TimeoutSeconds)
+	r.Equal(20, agentCfg.MaxFindings)
+}
+
+func TestAgentEgressConfig(t *testing.T) {
+	r := require.New(t)
+
+	cfg := AgentEgressConfig{
+		Restrict:           true,
+		AllowedHosts:       []string{"*.example.com"},
+		AgentAllowedHosts:  map[string][]string{"0x1": {"api.other.io"}},
+		UnrestrictedAgents: []string{"0x2"},
+	}
+	r.True(cfg.NeedsProxy())
+	r.True(cfg.IsRestricted(AgentConfig{ID: "0x1"}))
+	r.False(cfg.IsRestricted(AgentConfig{ID: "0x2"}))
+
+	r.True(cfg.IsHostAllowed("0x1", "API.example.com"))
+	r.True(cfg.IsHostAllowed("0x1", "api.other.io"))
+	r.False(cfg.IsHostAllowed("0x3", "api.other.io"))
+	r.False(cfg.IsHostAllowed("0x1", "example.com"))
+}
+
+func TestConfirmationsConfig_MaxBlockAge(t *testing.T) {
+	r := require.New(t)
+
+	depth := 40
+	r.Equal(time.Minute*20, *ConfirmationsConfig{Depth: &depth}.MaxBlockAge(time.Minute * 10))
+	r.Nil(ConfirmationsConfig{Tag: BlockTagFinalized}.MaxBlockAge(time.Minute * 10))
+	r.Nil(ConfirmationsConfig{Depth: &depth}.MaxBlockAge(0))
+
+	r.NoError(ConfirmationsConfig{Tag: BlockTagSafe}.Validate())
+	r.Error(ConfirmationsConfig{Depth: &depth, Tag: BlockTagSafe}.Validate())
+}
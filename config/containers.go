@@ -20,6 +20,7 @@ var (
 	DockerIpfsContainerName           = fmt.Sprintf("%s-ipfs", ContainerNamePrefix)
 	DockerScannerContainerName        = fmt.Sprintf("%s-scanner", ContainerNamePrefix)
 	DockerJSONRPCProxyContainerName   = fmt.Sprintf("%s-json-rpc", ContainerNamePrefix)
+	DockerEgressProxyContainerName    = fmt.Sprintf("%s-egress-proxy", ContainerNamePrefix)
 
 	DockerNetworkName = DockerScannerContainerName
 
@@ -28,3 +29,21 @@ var (
 	DefaultContainerKeyDirPath          = path.Join(DefaultContainerFortaDirPath, DefaultKeysDirName)
 	DefaultContainerLocalAgentsFilePath = path.Join(DefaultContainerFortaDirPath, DefaultLocalAgentsFileName)
 )
+
+// ScannerContainerName returns the scanner container name for the chain. The zero
+// chain ID is for the primary chain.
+func ScannerContainerName(chainID int) string {
+	if chainID == 0 {
+		return DockerScannerContainerName
+	}
+	return fmt.Sprintf("%s-%d", DockerScannerContainerName, chainID)
+}
+
+// JSONRPCProxyContainerName returns the JSON-RPC proxy container name for the chain. The zero
+// chain ID is for the primary chain.
+func JSONRPCProxyContainerName(chainID int) string {
+	if chainID == 0 {
+		return DockerJSONRPCProxyContainerName
+	}
+	return fmt.Sprintf("%s-%d", DockerJSONRPCProxyContainerName, chainID)
+}
@@ -7,5 +7,10 @@ const (
 	DefaultNatsPort            = "4222"
 	DefaultContainerPort       = "8089"
 	DefaultHealthPort          = "8090"
+	DefaultScannerAPIPort      = "80"
+	DefaultCheckpointFileName  = "scanner-checkpoint.json"
+	DefaultChainsDirName       = "chains"
+	DefaultShadowReportsDir    = "shadow-reports"
+	DefaultEgressProxyPort     = "8080"
 	DefaultFortaNodeBinaryPath = "/forta-node" // the path for the common binary in the container image
 )
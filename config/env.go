@@ -4,11 +4,15 @@ const (
 	EnvHostFortaDir = "HOST_FORTA_DIR" // for retrieving forta dir path on the host os
 	EnvDevelopment  = "FORTA_DEVELOPMENT"
 	EnvReleaseInfo  = "FORTA_RELEASE_INFO"
+	EnvChainID      = "FORTA_CHAIN_ID" // for the containers which serve one of the additional chains
 
 	// Agent env vars
 	EnvJsonRpcHost   = "JSON_RPC_HOST"
 	EnvJsonRpcPort   = "JSON_RPC_PORT"
 	EnvAgentGrpcPort = "AGENT_GRPC_PORT"
+	EnvHTTPProxy     = "HTTP_PROXY"
+	EnvHTTPSProxy    = "HTTPS_PROXY"
+	EnvNoProxy       = "NO_PROXY"
 )
 
 // EnvDefaults contain default values for one env.
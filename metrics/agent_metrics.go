@@ -17,6 +17,8 @@ const (
 	MetricTxError          = "tx.error"
 	MetricTxSuccess        = "tx.success"
 	MetricTxDrop           = "tx.drop"
+	MetricTxSkip           = "tx.skip"
+	MetricTxPending        = "tx.pending"
 	MetricTxBlockAge       = "tx.block.age"
 	MetricTxEventAge       = "tx.event.age"
 	MetricBlockBlockAge    = "block.block.age"
@@ -26,12 +28,27 @@ const (
 	MetricBlockError       = "block.error"
 	MetricBlockSuccess     = "block.success"
 	MetricBlockDrop        = "block.drop"
+	MetricAlertDrop        = "alert.drop"
 	MetricStop             = "agent.stop"
+	MetricInitFailure      = "agent.init.failure"
 	MetricJSONRPCLatency   = "jsonrpc.latency"
 	MetricJSONRPCRequest   = "jsonrpc.request"
 	MetricJSONRPCSuccess   = "jsonrpc.success"
 	MetricJSONRPCThrottled = "jsonrpc.throttled"
 	MetricFindingsDropped  = "findings.dropped"
+	MetricFindingInvalid   = "finding.invalid"
+	MetricFindingRepaired  = "finding.repaired"
+	MetricEgressBlocked    = "egress.blocked"
+	MetricCircuitOpen      = "agent.circuit.open"
+	MetricCircuitHalfOpen  = "agent.circuit.half-open"
+	MetricCircuitClosed    = "agent.circuit.closed"
+	MetricContainerCPU     = "container.cpu"
+	MetricContainerMemory  = "container.memory"
+	MetricContainerNetRx   = "container.network.rx"
+	MetricContainerNetTx   = "container.network.tx"
+	MetricContainerIORead  = "container.blockio.read"
+	MetricContainerIOWrite = "container.blockio.write"
+	MetricContainerOOM     = "container.oom"
 )
 
 func SendAgentMetrics(client clients.MessageClient, ms []*protocol.AgentMetric) {
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-node/config"
+)
+
+const cpuPeriod = 100000 // CFS period in microseconds
+
+// GetContainerMetrics creates the resource usage metrics of an agent container. CPU and memory
+// are percentages of the agent limits. Network and block IO are the KiB transferred since
+// the previous sample and they are skipped if there is no previous sample.
+func GetContainerMetrics(agentID string, stats, prevStats *types.StatsJSON, limits *config.AgentResourceLimits) []*protocol.AgentMetric {
+	values := make(map[string]float64)
+
+	if cpuPercent, ok := cpuUsagePercent(stats); ok {
+		// relative to the quota if there is one, otherwise relative to one CPU
+		if limits != nil && limits.CPUQuota > 0 {
+			cpuPercent = cpuPercent * cpuPeriod / float64(limits.CPUQuota)
+		}
+		values[MetricContainerCPU] = cpuPercent
+	}
+
+	memoryLimit := stats.MemoryStats.Limit
+	if limits != nil && limits.Memory > 0 {
+		memoryLimit = uint64(limits.Memory)
+	}
+	if memoryLimit > 0 {
+		values[MetricContainerMemory] = float64(memoryUsage(stats)) / float64(memoryLimit) * 100
+	}
+
+	if prevStats != nil {
+		rx, tx := networkBytes(stats)
+		prevRx, prevTx := networkBytes(prevStats)
+		setDeltaKiB(values, MetricContainerNetRx, rx, prevRx)
+		setDeltaKiB(values, MetricContainerNetTx, tx, prevTx)
+
+		read, write := blockIOBytes(stats)
+		prevRead, prevWrite := blockIOBytes(prevStats)
+		setDeltaKiB(values, MetricContainerIORead, read, prevRead)
+		setDeltaKiB(values, MetricContainerIOWrite, write, prevWrite)
+	}
+
+	timestamp := stats.Read
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return createMetrics(agentID, timestamp.Format(time.RFC3339), values)
+}
+
+// cpuUsagePercent calculates the usage like the docker CLI does: 100% is one full CPU.
+func cpuUsagePercent(stats *types.StatsJSON) (float64, bool) {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta < 0 || systemDelta <= 0 {
+		return 0, false
+	}
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * onlineCPUs * 100, true
+}
+
+// memoryUsage excludes the page cache like the docker CLI does.
+func memoryUsage(stats *types.StatsJSON) uint64 {
+	usage := stats.MemoryStats.Usage
+	if cache := stats.MemoryStats.Stats["cache"]; cache < usage {
+		usage -= cache
+	}
+	return usage
+}
+
+func networkBytes(stats *types.StatsJSON) (rx, tx uint64) {
+	for _, network := range stats.Networks {
+		rx += network.RxBytes
+		tx += network.TxBytes
+	}
+	return
+}
+
+func blockIOBytes(stats *types.StatsJSON) (read, write uint64) {
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	return
+}
+
+// setDeltaKiB skips the counters which were reset by a container restart.
+func setDeltaKiB(values map[string]float64, name string, curr, prev uint64) {
+	if curr < prev {
+		return
+	}
+	values[name] = float64(curr-prev) / 1024
+}
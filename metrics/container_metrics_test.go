@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/forta-network/forta-node/config"
+	"github.com/stretchr/testify/require"
+)
+
+func testStats(totalUsage, systemUsage, rx, read uint64) *types.StatsJSON {
+	stats := &types.StatsJSON{
+		Networks: map[string]types.NetworkStats{
+			"eth0": {RxBytes: rx},
+		},
+	}
+	stats.CPUStats.CPUUsage.TotalUsage = totalUsage
+	stats.CPUStats.SystemUsage = systemUsage
+	stats.CPUStats.OnlineCPUs = 2
+	stats.MemoryStats.Usage = 300
+	stats.MemoryStats.Stats = map[string]uint64{"cache": 100}
+	stats.BlkioStats.IoServiceBytesRecursive = []types.BlkioStatEntry{
+		{Op: "Read", Value: read},
+	}
+	return stats
+}
+
+func TestGetContainerMetrics(t *testing.T) {
+	r := require.New(t)
+
+	limits := &config.AgentResourceLimits{
+		CPUQuota: 50000, // half a CPU
+		Memory:   1000,
+	}
+	prevStats := testStats(0, 0, 1024, 0)
+	stats := testStats(100, 1000, 3072, 4096)
+	stats.PreCPUStats = prevStats.CPUStats
+
+	values := make(map[string]float64)
+	for _, metric := range GetContainerMetrics("agent", stats, prevStats, limits) {
+		r.Equal("agent", metric.AgentId)
+		values[metric.Name] = metric.Value
+	}
+
+	// 20% of two CPUs is 40% of the half CPU quota
+	r.Equal(float64(40), values[MetricContainerCPU])
+	r.Equal(float64(20), values[MetricContainerMemory])
+	r.Equal(float64(2), values[MetricContainerNetRx])
+	r.Equal(float64(0), values[MetricContainerNetTx])
+	r.Equal(float64(4), values[MetricContainerIORead])
+	r.Equal(float64(0), values[MetricContainerIOWrite])
+
+	// the counters are not reported without a previous sample
+	values = make(map[string]float64)
+	for _, metric := range GetContainerMetrics("agent", stats, nil, limits) {
+		values[metric.Name] = metric.Value
+	}
+	r.Len(values, 2)
+}
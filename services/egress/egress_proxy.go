@@ -0,0 +1,270 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-core-go/utils"
+	"github.com/forta-network/forta-node/clients"
+	"github.com/forta-network/forta-node/clients/messaging"
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultDialTimeout = time.Second * 10
+
+// hop-by-hop headers are not forwarded
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// EgressProxy is the HTTP proxy that the restricted agents reach the allowed hosts through.
+// The requests to the other hosts are blocked and reported as agent metrics.
+type EgressProxy struct {
+	ctx        context.Context
+	cfg        config.AgentEgressConfig
+	chainIDs   []int
+	server     *http.Server
+	transport  http.RoundTripper
+	containers *clients.ContainerCache
+	msgClient  clients.MessageClient
+
+	// agentConfigs are the latest agents by the chain ID.
+	agentConfigs  map[int][]config.AgentConfig
+	agentConfigMu sync.RWMutex
+
+	allowed uint64
+	blocked uint64
+
+	lastBlocked health.TimeTracker
+}
+
+// NewEgressProxy creates a new egress proxy.
+func NewEgressProxy(ctx context.Context, cfg config.Config) (*EgressProxy, error) {
+	globalClient, err := clients.NewDockerClient("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the global docker client: %v", err)
+	}
+	msgClient := messaging.NewClient("egress-proxy", fmt.Sprintf("%s:%s", config.DockerNatsContainerName, config.DefaultNatsPort))
+
+	// the agents of all chains use the same egress proxy
+	chainIDs := []int{0}
+	for _, chainCfg := range cfg.Chains {
+		if chainCfg.ChainID != cfg.ChainID {
+			chainIDs = append(chainIDs, chainCfg.ChainID)
+		}
+	}
+
+	return &EgressProxy{
+		ctx:          ctx,
+		cfg:          cfg.AgentEgress,
+		chainIDs:     chainIDs,
+		transport:    http.DefaultTransport,
+		containers:   clients.NewContainerCache(ctx, globalClient),
+		msgClient:    msgClient,
+		agentConfigs: make(map[int][]config.AgentConfig),
+	}, nil
+}
+
+// Start starts the proxy server.
+func (p *EgressProxy) Start() error {
+	log.Infof("Starting %s", p.Name())
+
+	p.registerMessageHandlers()
+
+	if err := p.containers.Start(); err != nil {
+		return fmt.Errorf("failed to start the container cache: %v", err)
+	}
+
+	p.server = &http.Server{
+		Addr:    fmt.Sprintf(":%s", config.DefaultEgressProxyPort),
+		Handler: p,
+	}
+	utils.GoListenAndServe(p.server)
+	return nil
+}
+
+// ServeHTTP handles the tunnels and the plain HTTP requests.
+func (p *EgressProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	host := req.URL.Hostname()
+	if req.Method == http.MethodConnect {
+		host, _, _ = net.SplitHostPort(req.Host)
+	}
+
+	agentConfig, chainID, ok := p.findAgentFromRemoteAddr(req.RemoteAddr)
+	if !ok || !p.cfg.IsHostAllowed(agentConfig.ID, host) {
+		p.block(w, agentConfig, chainID, host)
+		return
+	}
+	atomic.AddUint64(&p.allowed, 1)
+
+	if req.Method == http.MethodConnect {
+		p.tunnel(w, req)
+		return
+	}
+	p.forward(w, req)
+}
+
+func (p *EgressProxy) block(w http.ResponseWriter, agentConfig *config.AgentConfig, chainID int, host string) {
+	atomic.AddUint64(&p.blocked, 1)
+	p.lastBlocked.Set()
+	http.Error(w, fmt.Sprintf("egress to %s is not allowed", host), http.StatusForbidden)
+
+	if agentConfig == nil {
+		log.WithField("host", host).Warn("blocked egress from unknown container")
+		return
+	}
+	log.WithFields(log.Fields{
+		"agent": agentConfig.ID,
+		"host":  host,
+	}).Warn("blocked agent egress")
+	p.msgClient.PublishProto(messaging.ChainSubject(messaging.SubjectMetricAgent, chainID), &protocol.AgentMetricList{
+		Metrics: []*protocol.AgentMetric{
+			metrics.CreateAgentMetric(agentConfig.ID, metrics.MetricEgressBlocked, 1),
+		},
+	})
+}
+
+// tunnel connects the agent to the host for the HTTPS requests.
+func (p *EgressProxy) tunnel(w http.ResponseWriter, req *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	hostConn, err := net.DialTimeout("tcp", req.Host, defaultDialTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	agentConn, _, err := hijacker.Hijack()
+	if err != nil {
+		hostConn.Close()
+		return
+	}
+	go pipe(hostConn, agentConn)
+	go pipe(agentConn, hostConn)
+}
+
+func pipe(dst io.WriteCloser, src io.ReadCloser) {
+	defer dst.Close()
+	defer src.Close()
+	io.Copy(dst, src)
+}
+
+// forward sends the plain HTTP request to the host.
+func (p *EgressProxy) forward(w http.ResponseWriter, req *http.Request) {
+	outReq := req.Clone(p.ctx)
+	outReq.RequestURI = ""
+	for _, h := range hopHeaders {
+		outReq.Header.Del(h)
+	}
+	resp, err := p.transport.RoundTrip(outReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for k, vv := range resp.Header {
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func (p *EgressProxy) findAgentFromRemoteAddr(hostPort string) (*config.AgentConfig, int, bool) {
+	ipAddr := strings.Split(hostPort, ":")[0]
+	container, ok := p.containers.FindByIPAddress(ipAddr)
+	if !ok || len(container.Names) == 0 {
+		log.WithField("agentIpAddr", ipAddr).Warn("could not find agent container from ip address")
+		return nil, 0, false
+	}
+	containerName := container.Names[0][1:]
+
+	p.agentConfigMu.RLock()
+	defer p.agentConfigMu.RUnlock()
+
+	for chainID, agentConfigs := range p.agentConfigs {
+		for _, agentConfig := range agentConfigs {
+			if agentConfig.ContainerName() == containerName {
+				return &agentConfig, chainID, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+func (p *EgressProxy) registerMessageHandlers() {
+	for _, chainID := range p.chainIDs {
+		chainID := chainID
+		p.msgClient.Subscribe(
+			messaging.ChainSubject(messaging.SubjectAgentsVersionsLatest, chainID),
+			messaging.AgentsHandler(func(payload messaging.AgentPayload) error {
+				p.agentConfigMu.Lock()
+				p.agentConfigs[chainID] = payload
+				p.agentConfigMu.Unlock()
+				return nil
+			}),
+		)
+		// the latest agents are published only on change so the current list is requested
+		// in case the proxy started after the agents or was restarted
+		p.msgClient.Publish(messaging.ChainSubject(messaging.SubjectAgentsVersionsGet, chainID), messaging.AgentPayload{})
+	}
+}
+
+// Stop stops the proxy server.
+func (p *EgressProxy) Stop() error {
+	log.Infof("Stopping %s", p.Name())
+	if p.server != nil {
+		return p.server.Close()
+	}
+	return nil
+}
+
+// Name returns the name of the service.
+func (p *EgressProxy) Name() string {
+	return "egress-proxy"
+}
+
+// Health implements health.Reporter interface.
+func (p *EgressProxy) Health() health.Reports {
+	return health.Reports{
+		p.lastBlocked.GetReport("event.blocked.time"),
+		&health.Report{
+			Name:    "requests.allowed",
+			Status:  health.StatusInfo,
+			Details: strconv.FormatUint(atomic.LoadUint64(&p.allowed), 10),
+		},
+		&health.Report{
+			Name:    "requests.blocked",
+			Status:  health.StatusInfo,
+			Details: strconv.FormatUint(atomic.LoadUint64(&p.blocked), 10),
+		},
+	}
+}
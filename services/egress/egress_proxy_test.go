@@ -0,0 +1,162 @@
+package egress
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/forta-network/forta-node/clients"
+	"github.com/forta-network/forta-node/clients/messaging"
+	mock_clients "github.com/forta-network/forta-node/clients/mocks"
+	"github.com/forta-network/forta-node/config"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAgentID  = "0x1"
+	testAgentIP  = "127.0.0.1"
+	testUpstream = "upstream response"
+)
+
+var testAgentConfig = config.AgentConfig{ID: testAgentID, Image: "agentImage"}
+
+func newTestProxy(t *testing.T, cfg config.AgentEgressConfig, agentIP string) (*EgressProxy, *mock_clients.MockMessageClient) {
+	ctrl := gomock.NewController(t)
+	dockerClient := mock_clients.NewMockDockerClient(ctrl)
+	msgClient := mock_clients.NewMockMessageClient(ctrl)
+
+	dockerClient.EXPECT().GetContainers(gomock.Any()).Return(clients.DockerContainerList{
+		{
+			Names: []string{"/" + testAgentConfig.ContainerName()},
+			NetworkSettings: &types.SummaryNetworkSettings{
+				Networks: map[string]*network.EndpointSettings{
+					"agentNetwork": {IPAddress: agentIP},
+				},
+			},
+		},
+	}, nil)
+	containers := clients.NewContainerCache(context.Background(), dockerClient)
+	require.NoError(t, containers.Resync())
+
+	return &EgressProxy{
+		ctx:          context.Background(),
+		cfg:          cfg,
+		chainIDs:     []int{0},
+		transport:    http.DefaultTransport,
+		containers:   containers,
+		msgClient:    msgClient,
+		agentConfigs: map[int][]config.AgentConfig{0: {testAgentConfig}},
+	}, msgClient
+}
+
+func newUpstream(tls bool) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testUpstream))
+	})
+	if tls {
+		return httptest.NewTLSServer(handler)
+	}
+	return httptest.NewServer(handler)
+}
+
+// get sends the request through the proxy with the client of the upstream server.
+func get(t *testing.T, proxy *EgressProxy, upstream *httptest.Server) (int, string) {
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+	proxyURL, _ := url.Parse(proxyServer.URL)
+
+	client := upstream.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client.Transport = transport
+	defer transport.CloseIdleConnections()
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		// the client fails the tunnel if the proxy does not respond with 200
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func TestEgressProxy_Allow(t *testing.T) {
+	r := require.New(t)
+
+	upstream := newUpstream(false)
+	defer upstream.Close()
+	proxy, _ := newTestProxy(t, config.AgentEgressConfig{AllowedHosts: []string{"127.0.0.*"}}, testAgentIP)
+
+	status, body := get(t, proxy, upstream)
+	r.Equal(http.StatusOK, status)
+	r.Equal(testUpstream, body)
+	r.Equal(uint64(1), proxy.allowed)
+}
+
+func TestEgressProxy_Block(t *testing.T) {
+	r := require.New(t)
+
+	upstream := newUpstream(false)
+	defer upstream.Close()
+	proxy, msgClient := newTestProxy(t, config.AgentEgressConfig{AllowedHosts: []string{"api.example.com"}}, testAgentIP)
+	msgClient.EXPECT().PublishProto(messaging.SubjectMetricAgent, gomock.Any())
+
+	status, _ := get(t, proxy, upstream)
+	r.Equal(http.StatusForbidden, status)
+	r.Equal(uint64(1), proxy.blocked)
+}
+
+func TestEgressProxy_Tunnel(t *testing.T) {
+	r := require.New(t)
+
+	upstream := newUpstream(true)
+	defer upstream.Close()
+	proxy, _ := newTestProxy(t, config.AgentEgressConfig{AgentAllowedHosts: map[string][]string{testAgentID: {"127.0.0.1"}}}, testAgentIP)
+
+	status, body := get(t, proxy, upstream)
+	r.Equal(http.StatusOK, status)
+	r.Equal(testUpstream, body)
+	r.Equal(uint64(1), proxy.allowed)
+}
+
+func TestEgressProxy_UnknownSource(t *testing.T) {
+	r := require.New(t)
+
+	upstream := newUpstream(false)
+	defer upstream.Close()
+	// the request does not come from the agent container
+	proxy, _ := newTestProxy(t, config.AgentEgressConfig{AllowedHosts: []string{"*"}}, "10.0.0.1")
+
+	status, _ := get(t, proxy, upstream)
+	r.Equal(http.StatusForbidden, status)
+	r.Equal(uint64(1), proxy.blocked)
+	r.Equal(uint64(0), proxy.allowed)
+}
+
+func TestEgressProxy_RequestsAgents(t *testing.T) {
+	r := require.New(t)
+
+	proxy, msgClient := newTestProxy(t, config.AgentEgressConfig{}, testAgentIP)
+	proxy.agentConfigs = make(map[int][]config.AgentConfig)
+
+	var handler messaging.AgentsHandler
+	msgClient.EXPECT().Subscribe(messaging.SubjectAgentsVersionsLatest, gomock.Any()).Do(func(subject string, h interface{}) {
+		handler = h.(messaging.AgentsHandler)
+	})
+	msgClient.EXPECT().Publish(messaging.SubjectAgentsVersionsGet, gomock.Any())
+	proxy.registerMessageHandlers()
+
+	// the agents are known after the response
+	r.NoError(handler(messaging.AgentPayload{testAgentConfig}))
+	agentConfig, chainID, ok := proxy.findAgentFromRemoteAddr(testAgentIP + ":1234")
+	r.True(ok)
+	r.Equal(0, chainID)
+	r.Equal(testAgentID, agentConfig.ID)
+}
@@ -10,7 +10,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/docker/docker/api/types"
 	"github.com/rs/cors"
 	log "github.com/sirupsen/logrus"
 
@@ -26,11 +25,11 @@ import (
 
 // JsonRpcProxy proxies requests from agents to json-rpc endpoint
 type JsonRpcProxy struct {
-	ctx          context.Context
-	cfg          config.JsonRpcConfig
-	server       *http.Server
-	dockerClient clients.DockerClient
-	msgClient    clients.MessageClient
+	ctx        context.Context
+	cfg        config.JsonRpcConfig
+	server     *http.Server
+	containers *clients.ContainerCache
+	msgClient  clients.MessageClient
 
 	agentConfigs  []config.AgentConfig
 	agentConfigMu sync.RWMutex
@@ -45,6 +44,10 @@ func (p *JsonRpcProxy) Start() error {
 
 	p.registerMessageHandlers()
 
+	if err := p.containers.Start(); err != nil {
+		return fmt.Errorf("failed to start the container cache: %v", err)
+	}
+
 	rpcUrl, err := url.Parse(p.cfg.Url)
 	if err != nil {
 		return err
@@ -98,26 +101,9 @@ func (p *JsonRpcProxy) metricHandler(h http.Handler) http.Handler {
 }
 
 func (p *JsonRpcProxy) findAgentFromRemoteAddr(hostPort string) (*config.AgentConfig, bool) {
-	containers, err := p.dockerClient.GetContainers(p.ctx)
-	if err != nil {
-		log.WithError(err).Error("failed to get the container list")
-		return nil, false
-	}
 	ipAddr := strings.Split(hostPort, ":")[0]
-
-	var agentContainer *types.Container
-	for _, container := range containers {
-		for _, network := range container.NetworkSettings.Networks {
-			if network.IPAddress == ipAddr {
-				agentContainer = &container
-				break
-			}
-		}
-		if agentContainer != nil {
-			break
-		}
-	}
-	if agentContainer == nil {
+	agentContainer, ok := p.containers.FindByIPAddress(ipAddr)
+	if !ok {
 		log.WithField("agentIpAddr", ipAddr).Warn("could not found agent container from ip address")
 		return nil, false
 	}
@@ -191,7 +177,7 @@ func NewJsonRpcProxy(ctx context.Context, cfg config.Config) (*JsonRpcProxy, err
 	if err != nil {
 		return nil, fmt.Errorf("failed to create the global docker client: %v", err)
 	}
-	msgClient := messaging.NewClient("json-rpc-proxy", fmt.Sprintf("%s:%s", config.DockerNatsContainerName, config.DefaultNatsPort))
+	msgClient := messaging.NewClient("json-rpc-proxy", fmt.Sprintf("%s:%s", config.DockerNatsContainerName, config.DefaultNatsPort)).ForChain(cfg.ScopedChainID())
 
 	rateLimiting := cfg.JsonRpcProxy.RateLimitConfig
 	if rateLimiting == nil {
@@ -199,10 +185,10 @@ func NewJsonRpcProxy(ctx context.Context, cfg config.Config) (*JsonRpcProxy, err
 	}
 
 	return &JsonRpcProxy{
-		ctx:          ctx,
-		cfg:          jCfg,
-		dockerClient: globalClient,
-		msgClient:    msgClient,
+		ctx:        ctx,
+		cfg:        jCfg,
+		containers: clients.NewContainerCache(ctx, globalClient),
+		msgClient:  msgClient,
 		rateLimiter: NewRateLimiter(
 			rateLimiting.Rate,
 			rateLimiting.Burst,
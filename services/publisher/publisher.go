@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"sync"
 	"time"
 
@@ -19,6 +22,7 @@ import (
 	"github.com/forta-network/forta-core-go/clients/webhook"
 	"github.com/forta-network/forta-core-go/clients/webhook/client/operations"
 	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/encoding"
 	"github.com/forta-network/forta-core-go/ipfs"
 	"github.com/forta-network/forta-core-go/protocol"
 	"github.com/forta-network/forta-core-go/protocol/transform"
@@ -39,8 +43,23 @@ const (
 	defaultInterval        = time.Second * 15
 	defaultBatchLimit      = 500
 	defaultBatchBufferSize = 100
+
+	defaultRetryMinDelay      = time.Second
+	defaultRetryMaxDelay      = time.Minute * 5
+	defaultOutboxLagThreshold = time.Minute * 5
+	defaultOutboxDirName      = ".outbox"
+
+	defaultAlertStoreFileName  = "alerts.db"
+	defaultAlertsPruneInterval = time.Hour
+
+	// orphaned block hashes are forgotten after this many blocks
+	defaultOrphanedBlocksWindow = 1000
 )
 
+// OrphanedBlockTimestamp is the block timestamp of the marker results which retract the alerts
+// of an orphaned block that were sent in the earlier batches. The marker results have no alerts.
+const OrphanedBlockTimestamp = "orphaned"
+
 // Publisher receives, collects and publishes alerts.
 type Publisher struct {
 	protocol.UnimplementedPublisherNodeServer
@@ -56,6 +75,9 @@ type Publisher struct {
 
 	batchRefStore    store.StringStore
 	lastReceiptStore store.StringStore
+	outbox           store.BatchOutbox
+	outboxNotifCh    chan struct{}
+	alertStore       store.AlertStore
 
 	server *grpc.Server
 
@@ -64,6 +86,8 @@ type Publisher struct {
 	skipPublish   bool
 	batchInterval time.Duration
 	batchLimit    int
+	fastPath      bool
+	fastPathSev   protocol.Finding_Severity
 	latestChainID uint64
 	notifCh       chan *protocol.NotifyRequest
 	batchCh       chan *protocol.AlertBatch
@@ -76,6 +100,12 @@ type Publisher struct {
 
 	latestBlockInput   uint64
 	latestBlockInputMu sync.RWMutex
+
+	orphanedBlocks   map[string]uint64
+	publishedBlocks  map[string]uint64
+	retractions      []*protocol.Block
+	orphanedBlocksMu sync.RWMutex
+	lastRetraction   health.MessageTracker
 }
 
 // TestAlertLogger logs the test alerts.
@@ -112,6 +142,9 @@ func (pub *Publisher) Notify(ctx context.Context, req *protocol.NotifyRequest) (
 }
 
 func (pub *Publisher) publishNextBatch(batch *protocol.AlertBatch) error {
+	pub.retractOrphanedAlerts(batch)
+	retractionCount := pub.addRetractions(batch)
+
 	// flush only if we are publishing so we can make the best use of aggregated metrics
 	if _, skip := pub.shouldSkipPublishing(batch); !skip {
 		batch.Metrics = pub.metricsAggregator.TryFlush()
@@ -158,6 +191,7 @@ func (pub *Publisher) publishNextBatch(batch *protocol.AlertBatch) error {
 		log.Info(reason)
 		pub.lastBatchSkip.Set()
 		pub.lastBatchSkipReason.Set(reason)
+		pub.storeAlerts(batch, "")
 		return nil
 	}
 
@@ -168,8 +202,140 @@ func (pub *Publisher) publishNextBatch(batch *protocol.AlertBatch) error {
 		return nil
 	}
 
+	var cid string
+	if !pub.cfg.Config.PrivateModeConfig.Enable {
+		cid, err = pub.ipfs.CalculateFileHash(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to store alert data to ipfs: %v", err)
+		}
+		if err := pub.batchRefStore.Put(cid); err != nil {
+			return fmt.Errorf("failed to write last batch ref: %v", err)
+		}
+	}
+
+	// the batch is sent from the outbox so it is not lost if sending fails
+	if err := pub.outbox.Put(&store.OutboxItem{
+		Ref:         cid,
+		SignedBatch: signedBatch,
+	}); err != nil {
+		return fmt.Errorf("failed to add batch to outbox: %v", err)
+	}
+	select {
+	case pub.outboxNotifCh <- struct{}{}:
+	default:
+	}
+
+	pub.recordPublished(batch, retractionCount)
+	pub.storeAlerts(batch, cid)
+
+	return nil
+}
+
+// storeAlerts records the batch alerts in the local alert store.
+func (pub *Publisher) storeAlerts(batch *protocol.AlertBatch, batchRef string) {
+	if pub.alertStore == nil {
+		return
+	}
+	var alerts []*store.StoredAlert
+	for _, blockResults := range batch.Results {
+		var blockHash string
+		if blockResults.Block != nil {
+			blockHash = blockResults.Block.BlockHash
+		}
+		for _, agentAlerts := range blockResults.Results {
+			for _, alert := range agentAlerts.Alerts {
+				alerts = append(alerts, store.NewStoredAlert(alert, blockHash, "", batchRef))
+			}
+		}
+		for _, txResults := range blockResults.Transactions {
+			var txHash string
+			if txResults.Transaction != nil && txResults.Transaction.Transaction != nil {
+				txHash = txResults.Transaction.Transaction.Hash
+			}
+			for _, agentAlerts := range txResults.Results {
+				for _, alert := range agentAlerts.Alerts {
+					alerts = append(alerts, store.NewStoredAlert(alert, blockHash, txHash, batchRef))
+				}
+			}
+		}
+	}
+	for _, agentAlerts := range batch.PrivateAlerts {
+		for _, alert := range agentAlerts.Alerts {
+			alerts = append(alerts, store.NewStoredAlert(alert, "", "", batchRef))
+		}
+	}
+	if len(alerts) == 0 {
+		return
+	}
+	if err := pub.alertStore.Put(alerts...); err != nil {
+		log.WithError(err).Error("failed to store alerts locally")
+	}
+}
+
+func (pub *Publisher) pruneAlerts() {
+	retention := time.Duration(pub.cfg.Config.LocalAlerts.RetentionHours) * time.Hour
+	ticker := time.NewTicker(defaultAlertsPruneInterval)
+	defer ticker.Stop()
+	for {
+		count, err := pub.alertStore.Prune(time.Now().Add(-retention))
+		if err != nil {
+			log.WithError(err).Error("failed to prune local alerts")
+		} else if count > 0 {
+			log.WithField("count", count).Info("pruned local alerts")
+		}
+		select {
+		case <-pub.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// AlertStore returns the local alert store. It is nil if the local alerts are disabled.
+func (pub *Publisher) AlertStore() store.AlertStore {
+	return pub.alertStore
+}
+
+// nonRetryableError is the error from a batch which can never be sent.
+type nonRetryableError struct {
+	error
+}
+
+func isRetryable(err error) bool {
+	_, ok := err.(*nonRetryableError)
+	return !ok
+}
+
+// webhookStatusError is the error from the webhook client for the unexpected response statuses.
+type webhookStatusError interface {
+	IsClientError() bool
+	IsCode(code int) bool
+}
+
+// isWebhookRejection tells if the webhook rejected the alerts with a client error so
+// sending the same alerts again cannot succeed.
+func isWebhookRejection(err error) bool {
+	switch err := err.(type) {
+	case *operations.SendAlertsBadRequest:
+		return true
+	case webhookStatusError:
+		return err.IsClientError() && !err.IsCode(http.StatusTooManyRequests)
+	default:
+		return false
+	}
+}
+
+func (pub *Publisher) sendBatch(item *store.OutboxItem) error {
+	if item.SignedBatch == nil {
+		return &nonRetryableError{errors.New("outbox item has no batch")}
+	}
+	var batch protocol.AlertBatch
+	if err := encoding.DecodeGzippedProto(item.SignedBatch.Encoded, &batch); err != nil {
+		return &nonRetryableError{fmt.Errorf("failed to decode the batch from outbox: %v", err)}
+	}
+
 	if pub.cfg.Config.PrivateModeConfig.Enable {
-		alertList := transform.ToWebhookAlertList(batch)
+		alertList := transform.ToWebhookAlertList(&batch)
 		_, err := pub.webhookClient.SendAlerts(&operations.SendAlertsParams{
 			Context:   pub.ctx,
 			AlertList: alertList,
@@ -177,17 +343,13 @@ func (pub *Publisher) publishNextBatch(batch *protocol.AlertBatch) error {
 		if err != nil {
 			log.WithError(err).Error("failed to send private alerts")
 		}
+		if isWebhookRejection(err) {
+			return &nonRetryableError{fmt.Errorf("webhook rejected the alerts: %v", err)}
+		}
 		return err
 	}
 
-	cid, err := pub.ipfs.CalculateFileHash(buf.Bytes())
-	if err != nil {
-		return fmt.Errorf("failed to store alert data to ipfs: %v", err)
-	}
-	if err := pub.batchRefStore.Put(cid); err != nil {
-		return fmt.Errorf("failed to write last batch ref: %v", err)
-	}
-
+	cid := item.Ref
 	logger := log.WithFields(
 		log.Fields{
 			"blockStart":  batch.BlockStart,
@@ -237,12 +399,16 @@ func (pub *Publisher) publishNextBatch(batch *protocol.AlertBatch) error {
 		AlertCount:         int64(batch.AlertCount),
 		MaxSeverity:        int64(batch.MaxSeverity),
 		Ref:                cid,
-		SignedBatch:        signedBatch,
+		SignedBatch:        item.SignedBatch,
 		SignedBatchSummary: signedBatchSummary,
 	}, scannerJwt)
 
 	if err != nil {
 		logger.WithError(err).Error("alert while sending batch")
+		// the batches which are rejected by the api should not be sent again
+		if apiErr, ok := err.(*alertapi.APIError); ok && !apiErr.Retryable() {
+			return &nonRetryableError{fmt.Errorf("alert api rejected the batch: %v", err)}
+		}
 		return fmt.Errorf("failed to send the alert tx: %v", err)
 	}
 
@@ -273,8 +439,68 @@ func (pub *Publisher) publishNextBatch(batch *protocol.AlertBatch) error {
 	return nil
 }
 
+// sendOutboxBatches sends the batches from the outbox in the order they were added. The next
+// batch is not sent before the current one succeeds so that the receipts are chained correctly.
+func (pub *Publisher) sendOutboxBatches() {
+	var retryDelay time.Duration
+	for {
+		item, ok, err := pub.outbox.Peek()
+		if err != nil {
+			log.WithError(err).Error("failed to read from the outbox")
+		}
+		if err == nil && !ok {
+			select {
+			case <-pub.ctx.Done():
+				return
+			case <-pub.outboxNotifCh:
+			}
+			continue
+		}
+		if err == nil {
+			err = pub.sendBatch(item)
+			pub.lastBatchPublish.Set()
+			pub.lastBatchPublishErr.Set(err)
+		}
+		if err == nil {
+			retryDelay = 0
+			if err := pub.outbox.Remove(item); err != nil {
+				log.WithError(err).Error("failed to remove the sent batch from the outbox")
+			}
+			continue
+		}
+		if item != nil && !isRetryable(err) {
+			retryDelay = 0
+			log.WithError(err).WithField("ref", item.Ref).Error("moving alert batch to dead-letter dir")
+			if err := pub.outbox.DeadLetter(item); err != nil {
+				log.WithError(err).Error("failed to move the batch to the dead-letter dir")
+			} else {
+				continue
+			}
+		}
+
+		retryDelay = nextRetryDelay(retryDelay)
+		log.WithError(err).WithField("retryIn", retryDelay.String()).Error("failed to publish alert batch")
+		select {
+		case <-pub.ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func nextRetryDelay(prev time.Duration) time.Duration {
+	if prev == 0 {
+		return defaultRetryMinDelay
+	}
+	next := prev * 2
+	if next > defaultRetryMaxDelay {
+		return defaultRetryMaxDelay
+	}
+	return next
+}
+
 func (pub *Publisher) shouldSkipPublishing(batch *protocol.AlertBatch) (string, bool) {
-	if batch.AlertCount > 0 {
+	if batch.AlertCount > 0 || hasRetractions(batch) {
 		return "", false
 	}
 	const defaultReason = "because there are no alerts"
@@ -291,6 +517,7 @@ func (pub *Publisher) shouldSkipPublishing(batch *protocol.AlertBatch) (string,
 func (pub *Publisher) registerMessageHandlers() {
 	pub.messageClient.Subscribe(messaging.SubjectMetricAgent, messaging.AgentMetricHandler(pub.metricsAggregator.AddAgentMetrics))
 	pub.messageClient.Subscribe(messaging.SubjectScannerBlock, messaging.ScannerHandler(pub.handleScannerBlock))
+	pub.messageClient.Subscribe(messaging.SubjectScannerReorg, messaging.ReorgHandler(pub.handleScannerReorg))
 }
 
 func (pub *Publisher) handleScannerBlock(payload messaging.ScannerPayload) error {
@@ -310,13 +537,176 @@ func (pub *Publisher) handleScannerBlock(payload messaging.ScannerPayload) error
 	return nil
 }
 
+// handleScannerReorg keeps the orphaned blocks to retract their alerts from the next batches.
+// The alerts that were already published are retracted with the marker results in the next
+// published batch and they are marked in the local alert store.
+func (pub *Publisher) handleScannerReorg(payload messaging.ReorgPayload) error {
+	pub.orphanedBlocksMu.Lock()
+	var latest uint64
+	for _, block := range payload.OrphanedBlocks {
+		pub.orphanedBlocks[block.Hash] = block.Number
+		if block.Number > latest {
+			latest = block.Number
+		}
+		if _, ok := pub.publishedBlocks[block.Hash]; ok {
+			pub.retractions = append(pub.retractions, &protocol.Block{
+				BlockHash:      block.Hash,
+				BlockNumber:    block.Number,
+				BlockTimestamp: OrphanedBlockTimestamp,
+			})
+			delete(pub.publishedBlocks, block.Hash)
+		}
+	}
+	for hash, number := range pub.orphanedBlocks {
+		if number+defaultOrphanedBlocksWindow < latest {
+			delete(pub.orphanedBlocks, hash)
+		}
+	}
+	pub.orphanedBlocksMu.Unlock()
+
+	log.WithField("orphanedBlocks", len(payload.OrphanedBlocks)).Warn("received chain reorg")
+	if pub.alertStore == nil {
+		return nil
+	}
+	var hashes []string
+	for _, block := range payload.OrphanedBlocks {
+		hashes = append(hashes, block.Hash)
+	}
+	count, err := pub.alertStore.MarkOrphaned(hashes...)
+	if err != nil {
+		log.WithError(err).Error("failed to mark orphaned alerts")
+		return nil
+	}
+	if count > 0 {
+		log.WithField("count", count).Warn("marked published alerts from orphaned blocks in the local store")
+	}
+	return nil
+}
+
+// retractOrphanedAlerts removes the results of the orphaned blocks from the batch. The private
+// alerts are not tied to a block hash so they are kept.
+func (pub *Publisher) retractOrphanedAlerts(batch *protocol.AlertBatch) {
+	pub.orphanedBlocksMu.RLock()
+	defer pub.orphanedBlocksMu.RUnlock()
+	if len(pub.orphanedBlocks) == 0 {
+		return
+	}
+
+	var (
+		results   []*protocol.BlockResults
+		retracted uint32
+	)
+	for _, blockResults := range batch.Results {
+		if blockResults.Block == nil {
+			results = append(results, blockResults)
+			continue
+		}
+		if _, ok := pub.orphanedBlocks[blockResults.Block.BlockHash]; !ok {
+			results = append(results, blockResults)
+			continue
+		}
+		retracted += countBlockAlerts(blockResults)
+	}
+	batch.Results = results
+	if retracted == 0 {
+		return
+	}
+	batch.AlertCount -= retracted
+	batch.MaxSeverity = maxBatchSeverity(batch)
+
+	log.WithFields(log.Fields{
+		"blockStart": batch.BlockStart,
+		"blockEnd":   batch.BlockEnd,
+		"retracted":  retracted,
+	}).Warn("retracted alerts from orphaned blocks")
+	pub.lastRetraction.Set(fmt.Sprintf("retracted %d alert(s) from orphaned blocks", retracted))
+}
+
+// addRetractions adds the marker results for the orphaned blocks which had alerts in the
+// published batches. It returns the number of the added markers.
+func (pub *Publisher) addRetractions(batch *protocol.AlertBatch) int {
+	pub.orphanedBlocksMu.RLock()
+	defer pub.orphanedBlocksMu.RUnlock()
+	for _, block := range pub.retractions {
+		batch.Results = append(batch.Results, &protocol.BlockResults{Block: block})
+	}
+	return len(pub.retractions)
+}
+
+// recordPublished forgets the retractions which are sent with the batch and keeps the blocks
+// which have alerts in the batch so that they can be retracted later.
+func (pub *Publisher) recordPublished(batch *protocol.AlertBatch, retractionCount int) {
+	pub.orphanedBlocksMu.Lock()
+	defer pub.orphanedBlocksMu.Unlock()
+	// the new retractions are always appended
+	pub.retractions = pub.retractions[retractionCount:]
+	for _, blockResults := range batch.Results {
+		if blockResults.Block == nil || blockResults.Block.BlockTimestamp == OrphanedBlockTimestamp {
+			continue
+		}
+		if countBlockAlerts(blockResults) > 0 {
+			pub.publishedBlocks[blockResults.Block.BlockHash] = blockResults.Block.BlockNumber
+		}
+	}
+	for hash, number := range pub.publishedBlocks {
+		if number+defaultOrphanedBlocksWindow < batch.BlockEnd {
+			delete(pub.publishedBlocks, hash)
+		}
+	}
+}
+
+func hasRetractions(batch *protocol.AlertBatch) bool {
+	for _, blockResults := range batch.Results {
+		if blockResults.Block != nil && blockResults.Block.BlockTimestamp == OrphanedBlockTimestamp {
+			return true
+		}
+	}
+	return false
+}
+
+func countBlockAlerts(blockResults *protocol.BlockResults) uint32 {
+	var count int
+	for _, agentAlerts := range blockResults.Results {
+		count += len(agentAlerts.Alerts)
+	}
+	for _, txResults := range blockResults.Transactions {
+		for _, agentAlerts := range txResults.Results {
+			count += len(agentAlerts.Alerts)
+		}
+	}
+	return uint32(count)
+}
+
+func maxBatchSeverity(batch *protocol.AlertBatch) protocol.Finding_Severity {
+	var maxSeverity protocol.Finding_Severity
+	checkAlerts := func(alerts []*protocol.SignedAlert) {
+		for _, alert := range alerts {
+			if alert.Alert.Finding.Severity > maxSeverity {
+				maxSeverity = alert.Alert.Finding.Severity
+			}
+		}
+	}
+	for _, blockResults := range batch.Results {
+		for _, agentAlerts := range blockResults.Results {
+			checkAlerts(agentAlerts.Alerts)
+		}
+		for _, txResults := range blockResults.Transactions {
+			for _, agentAlerts := range txResults.Results {
+				checkAlerts(agentAlerts.Alerts)
+			}
+		}
+	}
+	for _, agentAlerts := range batch.PrivateAlerts {
+		checkAlerts(agentAlerts.Alerts)
+	}
+	return maxSeverity
+}
+
 func (pub *Publisher) publishBatches() {
 	for batch := range pub.batchCh {
-		err := pub.publishNextBatch(batch)
-		pub.lastBatchPublish.Set()
-		pub.lastBatchPublishErr.Set(err)
-		if err != nil {
-			log.Errorf("failed to publish alert batch: %v", err)
+		if err := pub.publishNextBatch(batch); err != nil {
+			pub.lastBatchPublishErr.Set(err)
+			log.Errorf("failed to prepare alert batch: %v", err)
 		}
 		time.Sleep(time.Millisecond * 20)
 	}
@@ -440,8 +830,10 @@ func (bd *BatchData) AddBatchAgent(agent *protocol.AgentInfo, blockNumber uint64
 
 // GetBlockResults returns an existing or a new aggregation object for the block.
 func (bd *BatchData) GetBlockResults(blockHash string, blockNumber uint64, blockTimestamp string) *protocol.BlockResults {
+	// the blocks are told apart by the hash too because a reorg can bring another block with the same number.
+	// the pending txs from the mempool have no block hash so they are kept apart from the mined block.
 	for _, blockRes := range bd.Results {
-		if blockRes.Block.BlockNumber == blockNumber {
+		if blockRes.Block.BlockNumber == blockNumber && blockRes.Block.BlockHash == blockHash {
 			return blockRes
 		}
 	}
@@ -555,6 +947,15 @@ func (pub *Publisher) prepareLatestBatch() {
 
 			batch.AppendAlert(notif)
 
+			// publish the batch right away if the alert is severe enough
+			if hasAlert && pub.fastPath && alert.Alert.Finding.Severity >= pub.fastPathSev {
+				log.WithFields(log.Fields{
+					"alertId":  alert.Alert.Id,
+					"severity": alert.Alert.Finding.Severity.String(),
+				}).Info("publishing batch early for severe alert")
+				done = true
+			}
+
 		case <-timeoutCh:
 			done = true
 		}
@@ -570,6 +971,10 @@ func (pub *Publisher) prepareLatestBatch() {
 func (pub *Publisher) Start() error {
 	go pub.prepareBatches()
 	go pub.publishBatches()
+	go pub.sendOutboxBatches()
+	if pub.alertStore != nil {
+		go pub.pruneAlerts()
+	}
 	pub.registerMessageHandlers()
 	return nil
 }
@@ -579,6 +984,9 @@ func (pub *Publisher) Stop() error {
 	if pub.server != nil {
 		pub.server.Stop()
 	}
+	if pub.alertStore != nil {
+		return pub.alertStore.Close()
+	}
 	return nil
 }
 
@@ -588,7 +996,7 @@ func (pub *Publisher) Name() string {
 
 // Health implements the health.Reporter interface.
 func (pub *Publisher) Health() health.Reports {
-	return health.Reports{
+	reports := health.Reports{
 		pub.lastBatchPublish.GetReport("event.batch-publish.time"),
 		pub.lastBatchPublishErr.GetReport("event.batch-publish.error"),
 		&health.Report{
@@ -598,11 +1006,38 @@ func (pub *Publisher) Health() health.Reports {
 		},
 		pub.lastBatchSkipReason.GetReport("event.batch-skip.reason"),
 		pub.lastMetricsFlush.GetReport("event.metrics-flush.time"),
+		pub.lastRetraction.GetReport("event.retraction"),
+	}
+	return append(reports, pub.outboxReports()...)
+}
+
+func (pub *Publisher) outboxReports() health.Reports {
+	count, oldest := pub.outbox.Stats()
+	var oldestAge string
+	oldestStatus := health.StatusInfo
+	if count > 0 {
+		age := time.Since(oldest)
+		oldestAge = age.Truncate(time.Second).String()
+		if age > defaultOutboxLagThreshold {
+			oldestStatus = health.StatusLagging
+		}
+	}
+	return health.Reports{
+		&health.Report{
+			Name:    "outbox.pending",
+			Status:  health.StatusInfo,
+			Details: strconv.Itoa(count),
+		},
+		&health.Report{
+			Name:    "outbox.oldest-age",
+			Status:  oldestStatus,
+			Details: oldestAge,
+		},
 	}
 }
 
 func NewPublisher(ctx context.Context, cfg config.Config) (*Publisher, error) {
-	mc := messaging.NewClient("metrics", fmt.Sprintf("%s:%s", config.DockerNatsContainerName, config.DefaultNatsPort))
+	mc := messaging.NewClient("metrics", fmt.Sprintf("%s:%s", config.DockerNatsContainerName, config.DefaultNatsPort)).ForChain(cfg.ScopedChainID())
 
 	key, err := security.LoadKey(config.DefaultContainerKeyDirPath)
 	if err != nil {
@@ -643,11 +1078,34 @@ func initPublisher(ctx context.Context, mc *messaging.Client, alertClient client
 		batchLimit = *cfg.PublisherConfig.Batch.MaxAlerts
 	}
 
+	var fastPathSev protocol.Finding_Severity
+	fastPath := len(cfg.PublisherConfig.Batch.FastPathSeverity) > 0
+	if fastPath {
+		sev, ok := protocol.Finding_Severity_value[cfg.PublisherConfig.Batch.FastPathSeverity]
+		if !ok {
+			return nil, fmt.Errorf("invalid fast path severity: %s", cfg.PublisherConfig.Batch.FastPathSeverity)
+		}
+		fastPathSev = protocol.Finding_Severity(sev)
+	}
+
 	var testAlertLogger TestAlertLogger
 	if !cfg.PublisherConfig.TestAlerts.Disable {
 		testAlertLogger = testalerts.NewLogger(cfg.PublisherConfig.TestAlerts.WebhookURL)
 	}
 
+	outbox, err := store.NewFileBatchOutbox(path.Join(cfg.Config.ChainDir(), defaultOutboxDirName))
+	if err != nil {
+		return nil, err
+	}
+
+	var alertStore store.AlertStore
+	if !cfg.Config.LocalAlerts.Disable {
+		alertStore, err = store.NewBoltAlertStore(path.Join(cfg.Config.ChainDir(), defaultAlertStoreFileName))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var webhookClient webhook.AlertWebhookClient
 	if cfg.Config.PrivateModeConfig.Enable {
 		dest := cfg.Config.PrivateModeConfig.WebhookURL
@@ -666,13 +1124,20 @@ func initPublisher(ctx context.Context, mc *messaging.Client, alertClient client
 		messageClient:     mc,
 		alertClient:       alertClient,
 		webhookClient:     webhookClient,
-		batchRefStore:     store.NewFileStringStore(path.Join(cfg.Config.FortaDir, ".last-batch")),
-		lastReceiptStore:  store.NewFileStringStore(path.Join(cfg.Config.FortaDir, ".last-receipt")),
+		batchRefStore:     store.NewFileStringStore(path.Join(cfg.Config.ChainDir(), ".last-batch")),
+		lastReceiptStore:  store.NewFileStringStore(path.Join(cfg.Config.ChainDir(), ".last-receipt")),
+		outbox:            outbox,
+		outboxNotifCh:     make(chan struct{}, 1),
+		alertStore:        alertStore,
+		orphanedBlocks:    make(map[string]uint64),
+		publishedBlocks:   make(map[string]uint64),
 
 		skipEmpty:     cfg.PublisherConfig.Batch.SkipEmpty,
 		skipPublish:   cfg.PublisherConfig.SkipPublish,
 		batchInterval: batchInterval,
 		batchLimit:    batchLimit,
+		fastPath:      fastPath,
+		fastPathSev:   fastPathSev,
 		notifCh:       make(chan *protocol.NotifyRequest, defaultBatchLimit),
 		batchCh:       make(chan *protocol.AlertBatch, defaultBatchBufferSize),
 	}, nil
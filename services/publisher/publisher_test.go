@@ -1,9 +1,24 @@
 package publisher
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/forta-network/forta-core-go/clients/webhook"
+	"github.com/forta-network/forta-core-go/encoding"
+	"github.com/forta-network/forta-core-go/ipfs"
 	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-node/clients/messaging"
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/store"
 	"github.com/stretchr/testify/assert"
-	"testing"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBatchData_AppendPrivateAlert_PerFinding(t *testing.T) {
@@ -77,3 +92,284 @@ func TestBatchData_AppendPrivateAlert_Block(t *testing.T) {
 	assert.Len(t, bd.PrivateAlerts[0].Alerts, 1)
 	assert.EqualValues(t, alert, bd.PrivateAlerts[0].Alerts[0])
 }
+
+func TestPrepareLatestBatch_FastPath(t *testing.T) {
+	pub := &Publisher{
+		batchInterval: time.Hour,
+		batchLimit:    10,
+		fastPath:      true,
+		fastPathSev:   protocol.Finding_HIGH,
+		notifCh:       make(chan *protocol.NotifyRequest, 1),
+		batchCh:       make(chan *protocol.AlertBatch, 1),
+	}
+
+	agent := &protocol.AgentInfo{Id: "agentId", Manifest: "agentInfo"}
+	pub.notifCh <- &protocol.NotifyRequest{
+		SignedAlert: &protocol.SignedAlert{
+			Alert: &protocol.Alert{
+				Id:      "alertId",
+				Agent:   agent,
+				Finding: &protocol.Finding{Severity: protocol.Finding_CRITICAL},
+			},
+		},
+		EvalTxRequest: &protocol.EvaluateTxRequest{
+			Event: &protocol.TransactionEvent{
+				Block:   &protocol.TransactionEvent_EthBlock{BlockNumber: "0x1"},
+				Receipt: &protocol.TransactionEvent_EthReceipt{TransactionHash: "0x2"},
+			},
+		},
+		EvalTxResponse: &protocol.EvaluateTxResponse{},
+		AgentInfo:      agent,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pub.prepareLatestBatch()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("batch was not closed early")
+	}
+	batch := <-pub.batchCh
+	assert.Equal(t, uint32(1), batch.AlertCount)
+	assert.Equal(t, protocol.Finding_CRITICAL, batch.MaxSeverity)
+}
+
+func TestRetractOrphanedAlerts(t *testing.T) {
+	pub := &Publisher{orphanedBlocks: make(map[string]uint64)}
+	assert.NoError(t, pub.handleScannerReorg(messaging.ReorgPayload{
+		OrphanedBlocks: []messaging.OrphanedBlock{{Number: 1, Hash: "0xorphaned"}},
+	}))
+
+	newAlert := func(severity protocol.Finding_Severity) *protocol.SignedAlert {
+		return &protocol.SignedAlert{Alert: &protocol.Alert{Finding: &protocol.Finding{Severity: severity}}}
+	}
+	batch := &protocol.AlertBatch{
+		AlertCount:  2,
+		MaxSeverity: protocol.Finding_CRITICAL,
+		Results: []*protocol.BlockResults{
+			{
+				Block: &protocol.Block{BlockHash: "0xorphaned", BlockNumber: 1},
+				Results: []*protocol.AgentAlerts{
+					{Alerts: []*protocol.SignedAlert{newAlert(protocol.Finding_CRITICAL)}},
+				},
+			},
+			{
+				Block: &protocol.Block{BlockHash: "0xcanonical", BlockNumber: 1},
+				Results: []*protocol.AgentAlerts{
+					{Alerts: []*protocol.SignedAlert{newAlert(protocol.Finding_LOW)}},
+				},
+			},
+		},
+	}
+
+	pub.retractOrphanedAlerts(batch)
+	assert.Len(t, batch.Results, 1)
+	assert.Equal(t, "0xcanonical", batch.Results[0].Block.BlockHash)
+	assert.Equal(t, uint32(1), batch.AlertCount)
+	assert.Equal(t, protocol.Finding_LOW, batch.MaxSeverity)
+}
+
+func TestSendOutboxBatches_NonRetryable(t *testing.T) {
+	outbox, err := store.NewFileBatchOutbox(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Put(&store.OutboxItem{
+		Ref:         "ref",
+		SignedBatch: &protocol.SignedPayload{Encoded: "not a batch"},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pub := &Publisher{
+		ctx:           ctx,
+		outbox:        outbox,
+		outboxNotifCh: make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		pub.sendOutboxBatches()
+		close(done)
+	}()
+
+	// the batch which cannot be decoded should not block the outbox
+	assert.Eventually(t, func() bool {
+		count, _ := outbox.Stats()
+		return count == 0
+	}, time.Second*5, time.Millisecond*10)
+	cancel()
+	<-done
+}
+
+func TestBatchData_GetBlockResults_ByHash(t *testing.T) {
+	bd := BatchData{}
+	agent := &protocol.AgentInfo{Id: "agentId", Manifest: "agentInfo"}
+	appendBlockAlert := func(blockHash string) {
+		bd.AppendAlert(&protocol.NotifyRequest{
+			SignedAlert: &protocol.SignedAlert{
+				Alert: &protocol.Alert{Id: "alertId", Agent: agent, Finding: &protocol.Finding{}},
+			},
+			EvalBlockRequest: &protocol.EvaluateBlockRequest{
+				Event: &protocol.BlockEvent{
+					BlockHash:   blockHash,
+					BlockNumber: "0x1",
+					Block:       &protocol.BlockEvent_EthBlock{Timestamp: "0x1"},
+				},
+			},
+			EvalBlockResponse: &protocol.EvaluateBlockResponse{},
+			AgentInfo:         agent,
+		})
+	}
+
+	// the canonical block does not merge into the orphaned block with the same number
+	appendBlockAlert("0xorphaned")
+	appendBlockAlert("0xcanonical")
+	appendBlockAlert("0xcanonical")
+	assert.Len(t, bd.Results, 2)
+	assert.Equal(t, "0xorphaned", bd.Results[0].Block.BlockHash)
+	assert.Len(t, bd.Results[0].Results[0].Alerts, 1)
+	assert.Equal(t, "0xcanonical", bd.Results[1].Block.BlockHash)
+	assert.Len(t, bd.Results[1].Results[0].Alerts, 2)
+}
+
+func TestBatchData_GetBlockResults_Pending(t *testing.T) {
+	bd := BatchData{}
+	agent := &protocol.AgentInfo{Id: "agentId", Manifest: "agentInfo"}
+	appendTxAlert := func(blockHash, txHash string) {
+		bd.AppendAlert(&protocol.NotifyRequest{
+			SignedAlert: &protocol.SignedAlert{
+				Alert: &protocol.Alert{Id: "alertId", Agent: agent, Finding: &protocol.Finding{}},
+			},
+			EvalTxRequest: &protocol.EvaluateTxRequest{
+				Event: &protocol.TransactionEvent{
+					Block:       &protocol.TransactionEvent_EthBlock{BlockHash: blockHash, BlockNumber: "0x2", BlockTimestamp: "0x1"},
+					Transaction: &protocol.TransactionEvent_EthTransaction{Hash: txHash},
+					Receipt:     &protocol.TransactionEvent_EthReceipt{TransactionHash: txHash},
+				},
+			},
+			EvalTxResponse: &protocol.EvaluateTxResponse{},
+			AgentInfo:      agent,
+		})
+	}
+
+	// the pending tx alerts stay apart from the alerts of the mined block with the same number
+	appendTxAlert("0xmined", "0x1")
+	appendTxAlert("", "0x2")
+	assert.Len(t, bd.Results, 2)
+	assert.Equal(t, "0xmined", bd.Results[0].Block.BlockHash)
+	assert.Equal(t, "", bd.Results[1].Block.BlockHash)
+	assert.Equal(t, "0x2", bd.Results[1].Transactions[0].Transaction.Transaction.Hash)
+}
+
+type testIPFSClient struct {
+	ipfs.Client
+}
+
+func (c *testIPFSClient) CalculateFileHash(payload []byte) (string, error) {
+	return "cid", nil
+}
+
+func TestPublishNextBatch_RetractsPublishedAlerts(t *testing.T) {
+	r := require.New(t)
+
+	privateKey, err := crypto.GenerateKey()
+	r.NoError(err)
+	outbox, err := store.NewFileBatchOutbox(t.TempDir())
+	r.NoError(err)
+	pub := &Publisher{
+		ctx:               context.Background(),
+		cfg:               PublisherConfig{Key: &keystore.Key{PrivateKey: privateKey}},
+		ipfs:              &testIPFSClient{},
+		metricsAggregator: NewMetricsAggregator(),
+		batchRefStore:     store.NewFileStringStore(path.Join(t.TempDir(), ".last-batch")),
+		outbox:            outbox,
+		outboxNotifCh:     make(chan struct{}, 1),
+		orphanedBlocks:    make(map[string]uint64),
+		publishedBlocks:   make(map[string]uint64),
+		skipEmpty:         true,
+	}
+	nextBatch := func() *protocol.AlertBatch {
+		item, ok, err := outbox.Peek()
+		r.NoError(err)
+		r.True(ok)
+		r.NoError(outbox.Remove(item))
+		var batch protocol.AlertBatch
+		r.NoError(encoding.DecodeGzippedProto(item.SignedBatch.Encoded, &batch))
+		return &batch
+	}
+
+	r.NoError(pub.publishNextBatch(&protocol.AlertBatch{
+		BlockStart: 1,
+		BlockEnd:   1,
+		AlertCount: 1,
+		Results: []*protocol.BlockResults{
+			{
+				Block: &protocol.Block{BlockHash: "0xorphaned", BlockNumber: 1, BlockTimestamp: "0x1"},
+				Results: []*protocol.AgentAlerts{
+					{Alerts: []*protocol.SignedAlert{{Alert: &protocol.Alert{Finding: &protocol.Finding{}}}}},
+				},
+			},
+		},
+	}))
+	r.Len(nextBatch().Results, 1)
+
+	r.NoError(pub.handleScannerReorg(messaging.ReorgPayload{
+		OrphanedBlocks: []messaging.OrphanedBlock{{Number: 1, Hash: "0xorphaned"}},
+	}))
+
+	// the next batch is not skipped when empty and it retracts the published block
+	r.NoError(pub.publishNextBatch(&protocol.AlertBatch{BlockStart: 2, BlockEnd: 2}))
+	batch := nextBatch()
+	r.Len(batch.Results, 1)
+	r.Equal("0xorphaned", batch.Results[0].Block.BlockHash)
+	r.Equal(uint64(1), batch.Results[0].Block.BlockNumber)
+	r.Equal(OrphanedBlockTimestamp, batch.Results[0].Block.BlockTimestamp)
+	r.Empty(batch.Results[0].Results)
+
+	// the retraction is sent only once
+	r.NoError(pub.publishNextBatch(&protocol.AlertBatch{BlockStart: 3, BlockEnd: 3}))
+	_, ok, err := outbox.Peek()
+	r.NoError(err)
+	r.False(ok)
+}
+
+func TestSendBatch_WebhookRejection(t *testing.T) {
+	r := require.New(t)
+
+	var status int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	webhookClient, err := webhook.NewAlertWebhookClient(server.URL)
+	r.NoError(err)
+
+	pub := &Publisher{
+		ctx:           context.Background(),
+		cfg:           PublisherConfig{Config: config.Config{PrivateModeConfig: config.PrivateModeConfig{Enable: true}}},
+		webhookClient: webhookClient,
+	}
+	encoded, err := encoding.EncodeGzippedProto(&protocol.AlertBatch{})
+	r.NoError(err)
+	item := &store.OutboxItem{SignedBatch: &protocol.SignedPayload{Encoded: encoded}}
+
+	// the client errors are not retried except the rate limit
+	for _, testCase := range []struct {
+		status    int
+		retryable bool
+	}{
+		{status: http.StatusBadRequest, retryable: false},
+		{status: http.StatusForbidden, retryable: false},
+		{status: http.StatusTooManyRequests, retryable: true},
+		{status: http.StatusInternalServerError, retryable: true},
+		{status: http.StatusBadGateway, retryable: true},
+	} {
+		status = testCase.status
+		err := pub.sendBatch(item)
+		r.Error(err)
+		r.Equal(testCase.retryable, isRetryable(err), "status %d", testCase.status)
+	}
+}
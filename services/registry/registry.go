@@ -3,6 +3,7 @@ package registry
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/forta-network/forta-node/store"
@@ -30,6 +31,7 @@ type RegistryService struct {
 	registryStore store.RegistryStore
 
 	agentsConfigs []*config.AgentConfig
+	agentsMu      sync.RWMutex
 	done          chan struct{}
 	version       string
 	sem           *semaphore.Weighted
@@ -89,6 +91,8 @@ func (rs *RegistryService) Start() error {
 }
 
 func (rs *RegistryService) start() error {
+	rs.msgClient.Subscribe(messaging.SubjectAgentsVersionsGet, messaging.AgentsHandler(rs.handleAgentVersionsGet))
+
 	go func() {
 		ticker := time.NewTicker(time.Duration(rs.cfg.Registry.CheckIntervalSeconds) * time.Second)
 		for {
@@ -116,7 +120,13 @@ func (rs *RegistryService) publishLatestAgents() error {
 		if changed {
 			rs.lastChangeDetected.Set()
 			log.WithField("count", len(agts)).Infof("publishing list of agents")
+			// the agents of the additional chains run in separate containers
+			for _, agt := range agts {
+				agt.ChainID = rs.cfg.ScopedChainID()
+			}
+			rs.agentsMu.Lock()
 			rs.agentsConfigs = agts
+			rs.agentsMu.Unlock()
 			rs.msgClient.Publish(messaging.SubjectAgentsVersionsLatest, agts)
 		} else {
 			log.Info("registry: no agent changes detected")
@@ -125,6 +135,18 @@ func (rs *RegistryService) publishLatestAgents() error {
 	return nil
 }
 
+// handleAgentVersionsGet publishes the latest agents again for the services which started
+// after the last change, as the list is otherwise published only when it changes.
+func (rs *RegistryService) handleAgentVersionsGet(payload messaging.AgentPayload) error {
+	rs.agentsMu.RLock()
+	defer rs.agentsMu.RUnlock()
+	if rs.agentsConfigs == nil {
+		return nil
+	}
+	rs.msgClient.Publish(messaging.SubjectAgentsVersionsLatest, rs.agentsConfigs)
+	return nil
+}
+
 // Stop stops the registry service.
 func (rs *RegistryService) Stop() error {
 	return nil
@@ -109,3 +109,20 @@ func (s *Suite) TestDoNotPublishChanges() {
 	s.registryStore.EXPECT().GetAgentsIfChanged(s.service.scannerAddress.Hex()).Return(nil, false, nil)
 	s.NoError(s.service.publishLatestAgents())
 }
+
+func (s *Suite) TestPublishAgentsOnGet() {
+	// nothing to publish before the first list
+	s.NoError(s.service.handleAgentVersionsGet(nil))
+
+	configs := (agentConfigs)([]*config.AgentConfig{
+		{
+			ID:    testAgentIDStr,
+			Image: fmt.Sprintf("%s/%s", testContainerRegistry, testImageRef),
+		},
+	})
+	s.registryStore.EXPECT().GetAgentsIfChanged(s.service.scannerAddress.Hex()).Return(configs, true, nil)
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsVersionsLatest, configs).Times(2)
+
+	s.NoError(s.service.publishLatestAgents())
+	s.NoError(s.service.handleAgentVersionsGet(nil))
+}
@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/forta-network/forta-node/clients"
+	"github.com/forta-network/forta-node/clients/agentgrpc"
+	"github.com/forta-network/forta-node/clients/messaging"
+	"github.com/forta-network/forta-node/config"
+)
+
+// agentRunner does the supervisor's job for the replay: it runs the agent containers
+// with the gRPC port published on localhost so they can be dialed from the host.
+type agentRunner struct {
+	ctx          context.Context
+	dockerClient clients.DockerClient
+	msgClient    clients.MessageClient
+	jsonRpcPort  int
+
+	containers map[string]*clients.DockerContainer
+	addrs      map[string]string
+	mu         sync.Mutex
+}
+
+func newAgentRunner(ctx context.Context, dockerClient clients.DockerClient, msgClient clients.MessageClient, jsonRpcPort int) *agentRunner {
+	runner := &agentRunner{
+		ctx:          ctx,
+		dockerClient: dockerClient,
+		msgClient:    msgClient,
+		jsonRpcPort:  jsonRpcPort,
+		containers:   make(map[string]*clients.DockerContainer),
+		addrs:        make(map[string]string),
+	}
+	msgClient.Subscribe(messaging.SubjectAgentsActionRun, messaging.AgentsHandler(runner.handleAgentRun))
+	return runner
+}
+
+func (runner *agentRunner) handleAgentRun(payload messaging.AgentPayload) error {
+	for _, agent := range payload {
+		if err := runner.startAgent(agent); err != nil {
+			log.WithError(err).WithField("agent", agent.ID).Error("failed to start agent")
+			continue
+		}
+		runner.msgClient.Publish(messaging.SubjectAgentsStatusRunning, messaging.AgentPayload{agent})
+	}
+	return nil
+}
+
+func (runner *agentRunner) startAgent(agent config.AgentConfig) error {
+	if err := runner.dockerClient.EnsureLocalImage(runner.ctx, fmt.Sprintf("agent %s", agent.ID), agent.Image); err != nil {
+		return err
+	}
+
+	env := map[string]string{
+		config.EnvAgentGrpcPort: agent.GrpcPort(),
+	}
+	if runner.jsonRpcPort > 0 {
+		env[config.EnvJsonRpcHost] = "host.docker.internal"
+		env[config.EnvJsonRpcPort] = strconv.Itoa(runner.jsonRpcPort)
+	}
+	agentContainer, err := runner.dockerClient.StartContainer(runner.ctx, clients.DockerContainerConfig{
+		Name:  agent.ContainerName(),
+		Image: agent.Image,
+		Env:   env,
+		Ports: map[string]string{
+			"127.0.0.1:": agent.GrpcPort(), // random host port
+		},
+		DialHost: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	runner.mu.Lock()
+	runner.containers[agent.ContainerName()] = agentContainer
+	runner.mu.Unlock()
+
+	container, err := runner.dockerClient.GetContainerByID(runner.ctx, agentContainer.ID)
+	if err != nil {
+		return err
+	}
+	grpcPort, _ := strconv.Atoi(agent.GrpcPort())
+	for _, port := range container.Ports {
+		if int(port.PrivatePort) == grpcPort && port.PublicPort > 0 {
+			runner.mu.Lock()
+			runner.addrs[agent.ContainerName()] = fmt.Sprintf("127.0.0.1:%d", port.PublicPort)
+			runner.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("published port not found for agent container '%s'", agent.ContainerName())
+}
+
+// Dial dials the agents through the published ports.
+func (runner *agentRunner) Dial(agent config.AgentConfig) (clients.AgentClient, error) {
+	runner.mu.Lock()
+	addr, ok := runner.addrs[agent.ContainerName()]
+	runner.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("agent container '%s' is not running", agent.ContainerName())
+	}
+	client := agentgrpc.NewClient()
+	if err := client.DialAddr(agent, addr); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// RemoveAll removes all of the agent containers that were started.
+func (runner *agentRunner) RemoveAll(ctx context.Context) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	for name, agentContainer := range runner.containers {
+		if err := runner.dockerClient.RemoveContainer(ctx, agentContainer.ID); err != nil {
+			log.WithError(err).WithField("container", name).Error("failed to remove agent container")
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package replay
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/goccy/go-json"
+
+	"github.com/forta-network/forta-node/clients"
+)
+
+// FindingRecord is a line in the findings output file.
+type FindingRecord struct {
+	AgentID     string          `json:"agentId"`
+	AgentImage  string          `json:"agentImage"`
+	ChainID     string          `json:"chainId"`
+	BlockNumber string          `json:"blockNumber"`
+	TxHash      string          `json:"txHash,omitempty"`
+	Alert       *protocol.Alert `json:"alert"`
+}
+
+// findingWriter implements the alert sender interface and writes the findings
+// to a JSONL file instead of sending them to the publisher.
+type findingWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+	count   int
+
+	lastActivity time.Time
+	mu           sync.Mutex
+}
+
+func newFindingWriter(filePath string) (*findingWriter, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the output file: %v", err)
+	}
+	return &findingWriter{
+		file:         file,
+		encoder:      json.NewEncoder(file),
+		lastActivity: time.Now(),
+	}, nil
+}
+
+// SignAlertAndNotify implements clients.AlertSender and writes the alert without signing.
+func (fw *findingWriter) SignAlertAndNotify(rt *clients.AgentRoundTrip, alert *protocol.Alert, chainID, blockNumber string, ts *domain.TrackingTimestamps) error {
+	record := &FindingRecord{
+		AgentID:     rt.AgentConfig.ID,
+		AgentImage:  rt.AgentConfig.Image,
+		ChainID:     chainID,
+		BlockNumber: blockNumber,
+		Alert:       alert,
+	}
+	if rt.EvalTxRequest != nil && rt.EvalTxRequest.Event.Transaction != nil {
+		record.TxHash = rt.EvalTxRequest.Event.Transaction.Hash
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.lastActivity = time.Now()
+	if err := fw.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write finding: %v", err)
+	}
+	fw.count++
+	return nil
+}
+
+// NotifyWithoutAlert implements clients.AlertSender.
+func (fw *findingWriter) NotifyWithoutAlert(rt *clients.AgentRoundTrip, ts *domain.TrackingTimestamps) error {
+	fw.mu.Lock()
+	fw.lastActivity = time.Now()
+	fw.mu.Unlock()
+	return nil
+}
+
+// IdleFor returns how long it has been since the last agent response.
+func (fw *findingWriter) IdleFor() time.Duration {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return time.Since(fw.lastActivity)
+}
+
+// Count returns the number of the findings written.
+func (fw *findingWriter) Count() int {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.count
+}
+
+func (fw *findingWriter) Close() error {
+	return fw.file.Close()
+}
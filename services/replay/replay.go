@@ -0,0 +1,262 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/forta-network/forta-core-go/ethereum"
+	"github.com/forta-network/forta-core-go/feeds"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/forta-network/forta-node/clients"
+	"github.com/forta-network/forta-node/clients/messaging"
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/services/scanner"
+	"github.com/forta-network/forta-node/services/scanner/agentpool"
+	"github.com/forta-network/forta-node/services/scanner/agentpool/poolagent"
+	"github.com/forta-network/forta-node/services/scanner/fixtures"
+)
+
+const (
+	defaultAttachTimeout = time.Minute * 5
+	// agent requests time out eventually so there are no more responses after this
+	defaultIdleTimeout = poolagent.AgentTimeout + time.Second*5
+	// the host gateway of the containers
+	defaultDockerBridgeName = "docker0"
+)
+
+// Config contains the replay parameters.
+type Config struct {
+	ChainID     int
+	From        uint64
+	To          uint64
+	RateMs      int64
+	Agents      []config.AgentConfig
+	OutputPath  string
+	JsonRpc     config.JsonRpcConfig
+	Trace       config.TraceConfig
+	IdleTimeout time.Duration
+	// EventsPath is a recorded fixtures file to replay instead of getting the blocks from the chain.
+	EventsPath string
+}
+
+// Result summarizes the replay.
+type Result struct {
+	FindingCount int
+}
+
+// Run feeds the block range through the scanner pipeline and writes the findings
+// to the output file. It returns when all of the agents stop responding after the last block.
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if cfg.From > cfg.To {
+		return nil, errors.New("the range start must not be after the range end")
+	}
+	if len(cfg.Agents) == 0 {
+		return nil, errors.New("no agents to replay with")
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+		// wait for the slowest agent
+		for _, agent := range cfg.Agents {
+			if timeout := time.Duration(agent.TimeoutSeconds)*time.Second + time.Second*5; timeout > cfg.IdleTimeout {
+				cfg.IdleTimeout = timeout
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	findings, err := newFindingWriter(cfg.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer findings.Close()
+
+	// agents can still run without an api when replaying recorded events
+	var proxyPort int
+	if len(cfg.JsonRpc.Url) > 0 {
+		proxyServer, port, err := startJsonRpcProxy(cfg.JsonRpc)
+		if err != nil {
+			return nil, err
+		}
+		defer proxyServer.Close()
+		proxyPort = port
+	}
+
+	dockerClient, err := clients.NewDockerClient("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the docker client: %v", err)
+	}
+	msgClient := messaging.NewLocalClient("replay")
+	runner := newAgentRunner(ctx, dockerClient, msgClient, proxyPort)
+	defer runner.RemoveAll(context.Background())
+
+	ethClient, err := ethereum.NewStreamEthClient(ctx, "chain", cfg.JsonRpc.Url)
+	if err != nil {
+		return nil, err
+	}
+	traceClient, err := ethereum.NewStreamEthClient(ctx, "trace", cfg.Trace.JsonRpc.Url)
+	if err != nil {
+		return nil, err
+	}
+	var blockFeed feeds.BlockFeed
+	if len(cfg.EventsPath) > 0 {
+		blockFeed, err = fixtures.NewReplayFeed(ctx, cfg.EventsPath, fixtures.ReplayFeedConfig{})
+	} else {
+		blockFeed, err = feeds.NewBlockFeed(ctx, ethClient, traceClient, feeds.BlockFeedConfig{
+			ChainID: big.NewInt(int64(cfg.ChainID)),
+			Tracing: cfg.Trace.Enabled,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	txStream, err := scanner.NewTxStreamService(ctx, ethClient, blockFeed, scanner.TxStreamServiceConfig{
+		JsonRpcConfig:      cfg.JsonRpc,
+		TraceJsonRpcConfig: cfg.Trace.JsonRpc,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the tx stream service: %v", err)
+	}
+
+	agentPool := agentpool.NewAgentPool(ctx, config.ScannerConfig{}, msgClient)
+	agentPool.SetDialer(runner.Dial)
+
+	txAnalyzer, err := scanner.NewTxAnalyzerService(ctx, scanner.TxAnalyzerServiceConfig{
+		TxChannel:   txStream.ReadOnlyTxStream(),
+		AlertSender: findings,
+		AgentPool:   agentPool,
+		MsgClient:   msgClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	blockAnalyzer, err := scanner.NewBlockAnalyzerService(ctx, scanner.BlockAnalyzerServiceConfig{
+		BlockChannel: txStream.ReadOnlyBlockStream(),
+		AlertSender:  findings,
+		AgentPool:    agentPool,
+		MsgClient:    msgClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attachedCh := make(chan config.AgentConfig, len(cfg.Agents))
+	msgClient.Subscribe(messaging.SubjectAgentsStatusAttached, messaging.AgentsHandler(func(payload messaging.AgentPayload) error {
+		for _, agent := range payload {
+			attachedCh <- agent
+		}
+		return nil
+	}))
+
+	for _, svc := range []interface{ Start() error }{txStream, txAnalyzer, blockAnalyzer} {
+		if err := svc.Start(); err != nil {
+			return nil, err
+		}
+	}
+
+	// same as the registry: the pool runs the missing agents
+	msgClient.Publish(messaging.SubjectAgentsVersionsLatest, messaging.AgentPayload(cfg.Agents))
+	if err := waitForAgents(ctx, attachedCh, len(cfg.Agents)); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"from": cfg.From,
+		"to":   cfg.To,
+	}).Info("replaying blocks")
+	blockFeed.StartRange(int64(cfg.From), int64(cfg.To), cfg.RateMs)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-txStream.Done():
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for findings.IdleFor() < cfg.IdleTimeout {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return &Result{FindingCount: findings.Count()}, nil
+}
+
+func waitForAgents(ctx context.Context, attachedCh <-chan config.AgentConfig, count int) error {
+	timeout := time.After(defaultAttachTimeout)
+	for i := 0; i < count; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("only %d of %d agents attached in %s", i, count, defaultAttachTimeout)
+		case agent := <-attachedCh:
+			log.WithField("agent", agent.ID).Info("agent attached")
+		}
+	}
+	return nil
+}
+
+// startJsonRpcProxy serves the JSON-RPC API to the agent containers through the host.
+func startJsonRpcProxy(jsonRpcCfg config.JsonRpcConfig) (*http.Server, int, error) {
+	rpcUrl, err := url.Parse(jsonRpcCfg.Url)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid json-rpc url: %v", err)
+	}
+	rp := httputil.NewSingleHostReverseProxy(rpcUrl)
+	d := rp.Director
+	rp.Director = func(r *http.Request) {
+		d(r)
+		r.Host = rpcUrl.Host
+		r.URL = rpcUrl
+		for h, v := range jsonRpcCfg.Headers {
+			r.Header.Set(h, v)
+		}
+	}
+
+	// the containers reach this through the docker host gateway and the proxy should not be
+	// reachable from the other hosts as it adds the json-rpc credentials
+	listener, err := net.Listen("tcp", net.JoinHostPort(dockerHostGatewayIP(), "0"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to listen for the json-rpc proxy: %v", err)
+	}
+	server := &http.Server{Handler: rp}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("json-rpc proxy failed")
+		}
+	}()
+	return server, listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// dockerHostGatewayIP returns the address of the default docker bridge. Docker Desktop
+// forwards the host gateway to the loopback address so that is used if there is no bridge.
+func dockerHostGatewayIP() string {
+	const loopback = "127.0.0.1"
+	iface, err := net.InterfaceByName(defaultDockerBridgeName)
+	if err != nil {
+		return loopback
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return loopback
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+	return loopback
+}
@@ -20,15 +20,23 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+const defaultThrottleCheckInterval = time.Millisecond * 100
+
 // AgentPool maintains the pool of agents that the scanner should
 // interact with.
 type AgentPool struct {
 	ctx          context.Context
+	cfg          config.ScannerConfig
 	agents       []*poolagent.Agent
 	txResults    chan *scanner.TxResult
 	blockResults chan *scanner.BlockResult
 	msgClient    clients.MessageClient
 	dialer       func(config.AgentConfig) (clients.AgentClient, error)
+	throttling   bool
+	// shadowRuns are the new agent versions running next to the old versions, by the agent ID.
+	shadowRuns map[string]*shadowRun
+	// failedAgents are the container names of the agents which the supervisor gave up restarting.
+	failedAgents map[string]bool
 	mu           sync.RWMutex
 }
 
@@ -36,6 +44,7 @@ type AgentPool struct {
 func NewAgentPool(ctx context.Context, cfg config.ScannerConfig, msgClient clients.MessageClient) *AgentPool {
 	agentPool := &AgentPool{
 		ctx:          ctx,
+		cfg:          cfg,
 		txResults:    make(chan *scanner.TxResult),
 		blockResults: make(chan *scanner.BlockResult),
 		msgClient:    msgClient,
@@ -53,6 +62,13 @@ func NewAgentPool(ctx context.Context, cfg config.ScannerConfig, msgClient clien
 	return agentPool
 }
 
+// SetDialer overrides how the pool connects to the agents.
+func (ap *AgentPool) SetDialer(dialer func(config.AgentConfig) (clients.AgentClient, error)) {
+	ap.mu.Lock()
+	ap.dialer = dialer
+	ap.mu.Unlock()
+}
+
 // Health implements health.Reporter interface.
 func (ap *AgentPool) Health() health.Reports {
 	ap.mu.RLock()
@@ -69,6 +85,10 @@ func (ap *AgentPool) Health() health.Reports {
 	if agentCount == 0 {
 		status = health.StatusFailing
 	}
+	throttlingStatus := health.StatusInfo
+	if ap.throttling {
+		throttlingStatus = health.StatusLagging
+	}
 	return health.Reports{
 		&health.Report{
 			Name:    "agents.total",
@@ -80,9 +100,79 @@ func (ap *AgentPool) Health() health.Reports {
 			Status:  health.StatusInfo,
 			Details: strconv.Itoa(fullCount),
 		},
+		&health.Report{
+			Name:    "agents.throttling",
+			Status:  throttlingStatus,
+			Details: strconv.FormatBool(ap.throttling),
+		},
+		&health.Report{
+			Name:    "agents.shadow",
+			Status:  health.StatusInfo,
+			Details: strconv.Itoa(len(ap.shadowRuns)),
+		},
+		&health.Report{
+			Name:    "agents.failed",
+			Status:  health.StatusInfo,
+			Details: strconv.Itoa(len(ap.failedAgents)),
+		},
+	}
+}
+
+// WaitIfThrottled blocks while too many agents are lagging, if the throttle policy is enabled.
+// It gives up waiting after a while so that the stream never halts because of the agents.
+func (ap *AgentPool) WaitIfThrottled(ctx context.Context) {
+	if ap.cfg.Backpressure.Policy != config.BackpressurePolicyThrottle || !ap.shouldThrottle() {
+		return
+	}
+
+	ap.setThrottling(true)
+	defer ap.setThrottling(false)
+	log.Warn("too many agents are lagging - throttling the block feed")
+
+	ticker := time.NewTicker(defaultThrottleCheckInterval)
+	defer ticker.Stop()
+	timeout := time.After(time.Duration(ap.cfg.Backpressure.MaxWaitSeconds) * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timeout:
+			log.Warn("agents are still lagging - stopped throttling the block feed")
+			return
+		case <-ticker.C:
+		}
+		if !ap.shouldThrottle() {
+			log.Info("agents caught up - resuming the block feed")
+			return
+		}
 	}
 }
 
+func (ap *AgentPool) setThrottling(throttling bool) {
+	ap.mu.Lock()
+	ap.throttling = throttling
+	ap.mu.Unlock()
+}
+
+// shouldThrottle tells if the ratio of the lagging agents is over the limit.
+func (ap *AgentPool) shouldThrottle() bool {
+	ap.mu.RLock()
+	agents := ap.agents
+	ap.mu.RUnlock()
+
+	var readyCount, fullCount int
+	for _, agent := range agents {
+		if !agent.IsReady() || agent.IsClosed() {
+			continue
+		}
+		readyCount++
+		if agent.TxBufferIsFull() {
+			fullCount++
+		}
+	}
+	return fullCount > 0 && float64(fullCount)/float64(readyCount) >= ap.cfg.Backpressure.LaggingRatio
+}
+
 // Name implements health.Reporter interface.
 func (ap *AgentPool) Name() string {
 	return "agent-pool"
@@ -107,6 +197,16 @@ func (ap *AgentPool) discardAgent(discarded *poolagent.Agent) {
 // SendEvaluateTxRequest sends the request to all of the active agents which
 // should be processing the block.
 func (ap *AgentPool) SendEvaluateTxRequest(req *protocol.EvaluateTxRequest) {
+	ap.sendEvaluateTxRequest(req, false)
+}
+
+// SendEvaluatePendingTxRequest sends the pending tx request to the active agents which
+// opted in to the pending transactions.
+func (ap *AgentPool) SendEvaluatePendingTxRequest(req *protocol.EvaluateTxRequest) {
+	ap.sendEvaluateTxRequest(req, true)
+}
+
+func (ap *AgentPool) sendEvaluateTxRequest(req *protocol.EvaluateTxRequest, pending bool) {
 	startTime := time.Now()
 	lg := log.WithFields(log.Fields{
 		"tx":        req.Event.Transaction.Hash,
@@ -118,16 +218,36 @@ func (ap *AgentPool) SendEvaluateTxRequest(req *protocol.EvaluateTxRequest) {
 	agents := ap.agents
 	ap.mu.RUnlock()
 
+	var (
+		metricsList  []*protocol.AgentMetric
+		targetAgents []*poolagent.Agent
+	)
+	for _, agent := range agents {
+		if !agent.IsReady() || !agent.ShouldProcessBlock(req.Event.Block.BlockNumber) {
+			continue
+		}
+		// only the agents which opt in receive the pending txs
+		if pending && !agent.Config().PendingTxs {
+			continue
+		}
+		if !agent.ShouldProcessTx(req.Event) {
+			metricsList = append(metricsList, metrics.CreateAgentMetric(agent.Config().ID, metrics.MetricTxSkip, 1))
+			continue
+		}
+		targetAgents = append(targetAgents, agent)
+	}
+	// do not encode if no agents want the tx
+	if len(targetAgents) == 0 {
+		metrics.SendAgentMetrics(ap.msgClient, metricsList)
+		return
+	}
+
 	encoded, err := agentgrpc.EncodeMessage(req)
 	if err != nil {
 		lg.WithError(err).Error("failed to encode message")
 		return
 	}
-	var metricsList []*protocol.AgentMetric
-	for _, agent := range agents {
-		if !agent.IsReady() || !agent.ShouldProcessBlock(req.Event.Block.BlockNumber) {
-			continue
-		}
+	for _, agent := range targetAgents {
 		lg.WithFields(log.Fields{
 			"agent":    agent.Config().ID,
 			"duration": time.Since(startTime),
@@ -141,6 +261,7 @@ func (ap *AgentPool) SendEvaluateTxRequest(req *protocol.EvaluateTxRequest) {
 		case agent.TxRequestCh() <- &poolagent.TxRequest{
 			Original: req,
 			Encoded:  encoded,
+			Pending:  pending,
 		}:
 		default: // do not try to send if the buffer is full
 			lg.WithField("agent", agent.Config().ID).Debug("agent tx request buffer is full - skipping")
@@ -211,6 +332,10 @@ func (ap *AgentPool) SendEvaluateBlockRequest(req *protocol.EvaluateBlockRequest
 		}).Debug("sent tx request to evalBlockCh")
 	}
 
+	ap.mu.Lock()
+	ap.advanceShadowRunsUnsafe()
+	ap.mu.Unlock()
+
 	blockNumber, _ := hexutil.DecodeUint64(req.Event.BlockNumber)
 	ap.msgClient.Publish(messaging.SubjectScannerBlock, &messaging.ScannerPayload{
 		LatestBlockInput: blockNumber,
@@ -222,6 +347,53 @@ func (ap *AgentPool) SendEvaluateBlockRequest(req *protocol.EvaluateBlockRequest
 	}).Debug("Finished SendEvaluateBlockRequest")
 }
 
+// SendEvaluateAlertRequest sends the alert to the active agents which subscribe to it.
+func (ap *AgentPool) SendEvaluateAlertRequest(evt *scanner.AlertEvent) {
+	alert := evt.Alert()
+	lg := log.WithFields(log.Fields{
+		"alert":     alert.Id,
+		"component": "pool",
+	})
+	lg.Debug("SendEvaluateAlertRequest")
+
+	ap.mu.RLock()
+	agents := ap.agents
+	ap.mu.RUnlock()
+
+	var targetAgents []*poolagent.Agent
+	for _, agent := range agents {
+		if agent.IsReady() && agent.ShouldProcessAlert(evt) {
+			targetAgents = append(targetAgents, agent)
+		}
+	}
+	// do not encode if no agents subscribe to the alert
+	if len(targetAgents) == 0 {
+		return
+	}
+
+	encoded, err := agentgrpc.EncodeMessage(evt.Notification)
+	if err != nil {
+		lg.WithError(err).Error("failed to encode message")
+		return
+	}
+
+	var metricsList []*protocol.AgentMetric
+	for _, agent := range targetAgents {
+		select {
+		case <-agent.Closed():
+			ap.discardAgent(agent)
+		case agent.AlertRequestCh() <- &poolagent.AlertRequest{
+			Original: evt,
+			Encoded:  encoded,
+		}:
+		default: // do not try to send if the buffer is full
+			lg.WithField("agent", agent.Config().ID).Warn("agent alert request buffer is full - skipping")
+			metricsList = append(metricsList, metrics.CreateAgentMetric(agent.Config().ID, metrics.MetricAlertDrop, 1))
+		}
+	}
+	metrics.SendAgentMetrics(ap.msgClient, metricsList)
+}
+
 func (ap *AgentPool) logAgentChanBuffersLoop() {
 	ticker := time.NewTicker(time.Second * 30)
 	for range ticker.C {
@@ -258,14 +430,25 @@ func (ap *AgentPool) handleAgentVersionsUpdate(payload messaging.AgentPayload) e
 	// and send a "run" message.
 	var agentsToRun []config.AgentConfig
 	for _, agentCfg := range latestVersions {
+		// the failed agents are not started again until there is a new version
+		if ap.failedAgents[agentCfg.ContainerName()] {
+			continue
+		}
 		var found bool
 		for _, agent := range ap.agents {
 			found = found || (agent.Config().ContainerName() == agentCfg.ContainerName())
 		}
 		if !found {
-			newAgents = append(newAgents, poolagent.New(ap.ctx, agentCfg, ap.msgClient, ap.txResults, ap.blockResults))
+			if agentCfg.Concurrency == 0 {
+				agentCfg.Concurrency = ap.cfg.AgentConcurrency
+			}
+			agentCfg.BlockOrdering = agentCfg.BlockOrdering || ap.cfg.AgentBlockOrdering
+			ap.cfg.AgentLimits.ApplyTo(&agentCfg)
+			newAgent := poolagent.New(ap.ctx, agentCfg, ap.msgClient, ap.txResults, ap.blockResults)
+			newAgents = append(newAgents, newAgent)
 			agentsToRun = append(agentsToRun, agentCfg)
 			log.WithField("agent", agentCfg.ID).Info("will trigger start")
+			ap.startShadowRunUnsafe(newAgent)
 		}
 	}
 
@@ -281,6 +464,12 @@ func (ap *AgentPool) handleAgentVersionsUpdate(payload messaging.AgentPayload) e
 				break
 			}
 		}
+		// keep the old version running until the shadow run is finished
+		if run, ok := ap.shadowRuns[agent.Config().ID]; ok && run.primary == agent {
+			for _, latestCfg := range latestVersions {
+				found = found || (run.shadow.Config().ContainerName() == latestCfg.ContainerName())
+			}
+		}
 		if !found {
 			agent.Close()
 			agentsToStop = append(agentsToStop, agent.Config())
@@ -291,6 +480,15 @@ func (ap *AgentPool) handleAgentVersionsUpdate(payload messaging.AgentPayload) e
 	}
 
 	ap.agents = newAgents
+	for containerName := range ap.failedAgents {
+		var found bool
+		for _, agentCfg := range latestVersions {
+			found = found || agentCfg.ContainerName() == containerName
+		}
+		if !found {
+			delete(ap.failedAgents, containerName)
+		}
+	}
 	if len(agentsToRun) > 0 {
 		ap.msgClient.Publish(messaging.SubjectAgentsActionRun, agentsToRun)
 	}
@@ -300,38 +498,118 @@ func (ap *AgentPool) handleAgentVersionsUpdate(payload messaging.AgentPayload) e
 	return nil
 }
 
+// startShadowRunUnsafe makes the new agent a shadow of the old version of the agent, if there is one.
+func (ap *AgentPool) startShadowRunUnsafe(newAgent *poolagent.Agent) {
+	if !ap.cfg.Shadow.Enable {
+		return
+	}
+	var primary *poolagent.Agent
+	for _, agent := range ap.agents {
+		if agent.Config().ID == newAgent.Config().ID && !agent.IsShadow() && !agent.IsClosed() {
+			primary = agent
+			break
+		}
+	}
+	if primary == nil {
+		return
+	}
+	// the local agents have no digest in the container name so two versions cannot run side by side
+	if primary.Config().IsLocal || newAgent.Config().IsLocal {
+		log.WithField("agent", primary.Config().ID).Info("not running the new version of a local agent as a shadow")
+		return
+	}
+	// a newer version replaces the previous shadow
+	if prevRun, ok := ap.shadowRuns[primary.Config().ID]; ok {
+		prevRun.finish()
+	}
+	if ap.shadowRuns == nil {
+		ap.shadowRuns = make(map[string]*shadowRun)
+	}
+	ap.shadowRuns[primary.Config().ID] = newShadowRun(primary, newAgent)
+	log.WithFields(log.Fields{
+		"agent":    primary.Config().ID,
+		"oldImage": primary.Config().Image,
+		"newImage": newAgent.Config().Image,
+		"blocks":   ap.cfg.Shadow.Blocks,
+	}).Info("running the new version as a shadow")
+}
+
+// stopAgentsUnsafe closes the agents, removes them from the pool and asks for their containers to be stopped.
+func (ap *AgentPool) stopAgentsUnsafe(agentsToStop ...*poolagent.Agent) {
+	var (
+		newAgents []*poolagent.Agent
+		stopCfgs  []config.AgentConfig
+	)
+	for _, agent := range ap.agents {
+		var stop bool
+		for _, agentToStop := range agentsToStop {
+			stop = stop || agent == agentToStop
+		}
+		if !stop {
+			newAgents = append(newAgents, agent)
+			continue
+		}
+		agent.Close()
+		stopCfgs = append(stopCfgs, agent.Config())
+		log.WithField("agent", agent.Config().ID).WithField("image", agent.Config().Image).Info("will trigger stop")
+	}
+	ap.agents = newAgents
+	if len(stopCfgs) > 0 {
+		ap.msgClient.Publish(messaging.SubjectAgentsActionStop, stopCfgs)
+	}
+}
+
 func (ap *AgentPool) handleStatusRunning(payload messaging.AgentPayload) error {
 	log.Debug("handleStatusRunning")
 	// If an agent was added before and just started to run, we should mark as ready.
-	var agentsToStop []config.AgentConfig
-	var agentsReady []config.AgentConfig
-
+	var agents []*poolagent.Agent
+	ap.mu.RLock()
 	for _, agentCfg := range payload {
 		for _, agent := range ap.agents {
 			if agent.Config().ContainerName() == agentCfg.ContainerName() {
-				c, err := ap.dialer(agent.Config())
-				if err != nil {
-					log.WithField("agent", agent.Config().ID).WithError(err).Error("handleStatusRunning: error while dialing")
-					agentsToStop = append(agentsToStop, agent.Config())
-					continue
-				}
-				agent.SetClient(c)
-				agent.SetReady()
-				agent.StartProcessing()
-				log.WithField("agent", agent.Config().ID).WithField("image", agent.Config().Image).Info("attached")
-				agentsReady = append(agentsReady, agent.Config())
+				agents = append(agents, agent)
 			}
 		}
 	}
-	if len(agentsReady) > 0 {
-		ap.msgClient.Publish(messaging.SubjectAgentsStatusAttached, agentsReady)
-	}
-	if len(agentsToStop) > 0 {
-		ap.msgClient.Publish(messaging.SubjectAgentsActionStop, agentsToStop)
+	ap.mu.RUnlock()
+
+	// the agents are attached concurrently so that a slow agent does not hold back the others
+	for _, agent := range agents {
+		go ap.attachAgent(agent)
 	}
 	return nil
 }
 
+// attachAgent connects to and initializes the agent. The agents which fail are removed from the pool.
+func (ap *AgentPool) attachAgent(agent *poolagent.Agent) {
+	logger := log.WithField("agent", agent.Config().ID)
+	c, err := ap.dialer(agent.Config())
+	if err != nil {
+		logger.WithError(err).Error("handleStatusRunning: error while dialing")
+		ap.removeFailedAgent(agent)
+		return
+	}
+	agent.SetClient(c)
+	if err := agent.Initialize(); err != nil {
+		logger.WithError(err).Error("handleStatusRunning: error while initializing")
+		metrics.SendAgentMetrics(ap.msgClient, []*protocol.AgentMetric{
+			metrics.CreateAgentMetric(agent.Config().ID, metrics.MetricInitFailure, 1),
+		})
+		ap.removeFailedAgent(agent)
+		return
+	}
+	agent.SetReady()
+	agent.StartProcessing()
+	logger.WithField("image", agent.Config().Image).Info("attached")
+	ap.msgClient.Publish(messaging.SubjectAgentsStatusAttached, []config.AgentConfig{agent.Config()})
+}
+
+func (ap *AgentPool) removeFailedAgent(agent *poolagent.Agent) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.stopAgentsUnsafe(agent)
+}
+
 func (ap *AgentPool) handleStatusStopped(payload messaging.AgentPayload) error {
 	ap.mu.Lock()
 	defer ap.mu.Unlock()
@@ -357,8 +635,37 @@ func (ap *AgentPool) handleStatusStopped(payload messaging.AgentPayload) error {
 	return nil
 }
 
+func (ap *AgentPool) handleStatusFailed(payload messaging.AgentPayload) error {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	log.Debug("handleStatusFailed")
+	if ap.failedAgents == nil {
+		ap.failedAgents = make(map[string]bool)
+	}
+	var newAgents []*poolagent.Agent
+	for _, agent := range ap.agents {
+		var failed bool
+		for _, agentCfg := range payload {
+			failed = failed || agent.Config().ContainerName() == agentCfg.ContainerName()
+		}
+		if !failed {
+			newAgents = append(newAgents, agent)
+			continue
+		}
+		agent.Close()
+		log.WithField("agent", agent.Config().ID).WithField("image", agent.Config().Image).Warn("agent failed - detached")
+	}
+	ap.agents = newAgents
+	for _, agentCfg := range payload {
+		ap.failedAgents[agentCfg.ContainerName()] = true
+	}
+	return nil
+}
+
 func (ap *AgentPool) registerMessageHandlers() {
 	ap.msgClient.Subscribe(messaging.SubjectAgentsVersionsLatest, messaging.AgentsHandler(ap.handleAgentVersionsUpdate))
 	ap.msgClient.Subscribe(messaging.SubjectAgentsStatusRunning, messaging.AgentsHandler(ap.handleStatusRunning))
 	ap.msgClient.Subscribe(messaging.SubjectAgentsStatusStopped, messaging.AgentsHandler(ap.handleStatusStopped))
+	ap.msgClient.Subscribe(messaging.SubjectAgentsStatusFailed, messaging.AgentsHandler(ap.handleStatusFailed))
 }
@@ -2,8 +2,11 @@ package agentpool
 
 import (
 	"context"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/forta-network/forta-core-go/clients/health"
 	"github.com/forta-network/forta-core-go/protocol"
 	"github.com/forta-network/forta-node/clients"
 	"github.com/forta-network/forta-node/clients/agentgrpc"
@@ -11,7 +14,10 @@ import (
 	mock_clients "github.com/forta-network/forta-node/clients/mocks"
 	"github.com/forta-network/forta-node/config"
 	"github.com/forta-network/forta-node/services/scanner"
+	"github.com/forta-network/forta-node/services/scanner/agentpool/poolagent"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -55,6 +61,10 @@ func (s *Suite) SetupTest() {
 			return s.agentClient, nil
 		},
 	}
+	// the agents which do not implement the initialization are not stopped
+	s.agentClient.EXPECT().Invoke(
+		gomock.Any(), agentgrpc.MethodInitialize, gomock.Any(), gomock.Any(),
+	).Return(status.Error(codes.Unimplemented, "unimplemented")).AnyTimes()
 }
 
 // TestStartProcessStop tests the starting, processing and stopping flow for an agent.
@@ -80,7 +90,7 @@ func (s *Suite) TestStartProcessStop() {
 	// When the agent pool receives a message saying that the agent started to run
 	s.r.NoError(s.ap.handleStatusRunning(agentPayload))
 	// Then the agent must be marked ready
-	s.r.True(s.ap.agents[0].IsReady())
+	s.waitReady()
 
 	// Given that the agent is running
 	// When an evaluate requests are received
@@ -128,3 +138,259 @@ func (s *Suite) TestStartProcessStop() {
 	s.agentClient.EXPECT().Close()
 	s.r.NoError(s.ap.handleAgentVersionsUpdate(emptyPayload))
 }
+
+// TestInitializeSuccess tests that the agents are marked ready after they initialize successfully.
+func (s *Suite) TestInitializeSuccess() {
+	agentPayload := messaging.AgentPayload{
+		config.AgentConfig{
+			ID: testAgentID,
+		},
+	}
+
+	// Given that the agent is known to the pool but it is not ready yet
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsStatusAttached, gomock.Any())
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionRun, gomock.Any())
+	s.r.NoError(s.ap.handleAgentVersionsUpdate(agentPayload))
+
+	// When the agent pool receives a message saying that the agent started to run
+	// And the agent initializes successfully
+	s.expectInitialize(protocol.ResponseStatus_SUCCESS)
+	s.r.NoError(s.ap.handleStatusRunning(agentPayload))
+	// Then the agent must be marked ready
+	s.waitReady()
+	s.r.Equal(protocol.ResponseStatus_SUCCESS, s.ap.agents[0].InitializeResponse().Status)
+}
+
+// waitReady waits for the first agent to be ready as the agents are attached asynchronously.
+func (s *Suite) waitReady() {
+	s.r.Eventually(func() bool {
+		return s.ap.agents[0].IsReady()
+	}, time.Second*5, time.Millisecond*10)
+}
+
+// TestInitializeFailure tests that the agents which fail to initialize are not used.
+func (s *Suite) TestInitializeFailure() {
+	agentPayload := messaging.AgentPayload{
+		config.AgentConfig{
+			ID: testAgentID,
+		},
+	}
+
+	// Given that the agent is known to the pool but it is not ready yet
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionRun, gomock.Any())
+	s.r.NoError(s.ap.handleAgentVersionsUpdate(agentPayload))
+
+	// When the agent pool receives a message saying that the agent started to run
+	// And the agent fails to initialize
+	s.expectInitialize(protocol.ResponseStatus_ERROR)
+	// Then the failure should be reported and a "stop" action should be published
+	s.msgClient.EXPECT().PublishProto(messaging.SubjectMetricAgent, gomock.Any())
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionStop, gomock.Any())
+	s.agentClient.EXPECT().Close()
+	agent := s.ap.agents[0]
+	s.r.NoError(s.ap.handleStatusRunning(agentPayload))
+	// And the agent must be removed from the pool
+	s.r.Eventually(func() bool {
+		s.ap.mu.RLock()
+		defer s.ap.mu.RUnlock()
+		return len(s.ap.agents) == 0
+	}, time.Second*5, time.Millisecond*10)
+	s.r.False(agent.IsReady())
+	s.r.True(agent.IsClosed())
+}
+
+// TestTxFilter tests that the transactions which do not involve the addresses from the agent
+// initialization are not sent to the agent.
+func (s *Suite) TestTxFilter() {
+	agentPayload := messaging.AgentPayload{
+		config.AgentConfig{
+			ID: testAgentID,
+		},
+	}
+
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsStatusAttached, gomock.Any())
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionRun, gomock.Any())
+	s.r.NoError(s.ap.handleAgentVersionsUpdate(agentPayload))
+
+	// Given that the agent asks for the transactions of an address during the initialization
+	s.expectInitialize(protocol.ResponseStatus_SUCCESS, "0x1")
+	s.r.NoError(s.ap.handleStatusRunning(agentPayload))
+	s.waitReady()
+	s.r.Equal([]string{"0x1"}, s.ap.agents[0].InitializeResponse().Addresses)
+
+	// When a transaction which does not involve the address is received
+	// Then it should be skipped
+	s.msgClient.EXPECT().PublishProto(messaging.SubjectMetricAgent, gomock.Any())
+	s.ap.SendEvaluateTxRequest(&protocol.EvaluateTxRequest{
+		Event: &protocol.TransactionEvent{
+			Block:       &protocol.TransactionEvent_EthBlock{BlockNumber: "123123"},
+			Transaction: &protocol.TransactionEvent_EthTransaction{Hash: "0x0"},
+			Addresses:   map[string]bool{"0x2": true},
+		},
+	})
+
+	// When a transaction which involves the address is received
+	// Then the agent should process it
+	txReq := &protocol.EvaluateTxRequest{
+		Event: &protocol.TransactionEvent{
+			Block:       &protocol.TransactionEvent_EthBlock{BlockNumber: "123123"},
+			Transaction: &protocol.TransactionEvent_EthTransaction{Hash: "0x1"},
+			Addresses:   map[string]bool{"0x1": true},
+		},
+	}
+	s.agentClient.EXPECT().Invoke(
+		gomock.Any(), agentgrpc.MethodEvaluateTx,
+		gomock.AssignableToTypeOf(&grpc.PreparedMsg{}), gomock.AssignableToTypeOf(&protocol.EvaluateTxResponse{}),
+	).Return(nil)
+	s.ap.SendEvaluateTxRequest(txReq)
+	txResult := <-s.ap.TxResults()
+	s.r.Equal(txReq, txResult.Request)
+}
+
+func (s *Suite) expectInitialize(respStatus protocol.ResponseStatus, addresses ...string) {
+	// use a new client to override the default initialization response
+	s.agentClient = mock_clients.NewMockAgentClient(gomock.NewController(s.T()))
+	s.agentClient.EXPECT().Invoke(
+		gomock.Any(), agentgrpc.MethodInitialize,
+		gomock.AssignableToTypeOf(&protocol.InitializeRequest{}), gomock.AssignableToTypeOf(&protocol.InitializeResponse{}),
+	).DoAndReturn(func(ctx context.Context, method agentgrpc.Method, in, out interface{}, opts ...grpc.CallOption) error {
+		resp := out.(*protocol.InitializeResponse)
+		resp.Status = respStatus
+		resp.Addresses = addresses
+		return nil
+	})
+}
+
+// TestThrottling tests that the pool holds back the stream while the agents are lagging.
+func (s *Suite) TestThrottling() {
+	s.ap.cfg.Backpressure = config.BackpressureConfig{
+		Policy:         config.BackpressurePolicyThrottle,
+		LaggingRatio:   0.5,
+		MaxWaitSeconds: 10,
+	}
+	laggingAgent := poolagent.New(s.ap.ctx, config.AgentConfig{ID: "lagging"}, s.msgClient, s.ap.txResults, s.ap.blockResults)
+	laggingAgent.SetReady()
+	okAgent := poolagent.New(s.ap.ctx, config.AgentConfig{ID: "ok"}, s.msgClient, s.ap.txResults, s.ap.blockResults)
+	okAgent.SetReady()
+	s.ap.agents = []*poolagent.Agent{laggingAgent, okAgent}
+
+	// Given that half of the agents have full buffers
+	for i := 0; i < poolagent.DefaultBufferSize; i++ {
+		laggingAgent.TxRequestCh() <- &poolagent.TxRequest{}
+	}
+	s.r.True(s.ap.shouldThrottle())
+
+	// When the stream waits
+	done := make(chan struct{})
+	go func() {
+		s.ap.WaitIfThrottled(context.Background())
+		close(done)
+	}()
+	time.Sleep(defaultThrottleCheckInterval * 2)
+	// Then it should be held back
+	s.r.Equal("true", s.healthReport("agents.throttling").Details)
+
+	// When the lagging agent goes away
+	laggingAgent.Close()
+	// Then the pool should stop throttling
+	<-done
+	s.r.False(s.ap.shouldThrottle())
+	s.r.Equal("false", s.healthReport("agents.throttling").Details)
+}
+
+func (s *Suite) healthReport(name string) *health.Report {
+	for _, report := range s.ap.Health() {
+		if report.Name == name {
+			return report
+		}
+	}
+	s.r.FailNow("health report not found", name)
+	return nil
+}
+
+// TestShadowRun tests that the new version of an agent runs as a shadow before replacing the old version.
+func (s *Suite) TestShadowRun() {
+	reportsDir := s.T().TempDir()
+	s.ap.cfg.Shadow = config.ShadowConfig{
+		Enable:     true,
+		Blocks:     1,
+		ReportsDir: reportsDir,
+	}
+	oldCfg := config.AgentConfig{ID: testAgentID, Image: "bafybeibvkqkf7i3c5ouehviwjb2dzbukgqied3cg36axl7gzm23r6ielnu@sha256:1111111111111111111111111111111111111111111111111111111111111111"}
+	newCfg := config.AgentConfig{ID: testAgentID, Image: "bafybeibvkqkf7i3c5ouehviwjb2dzbukgqied3cg36axl7gzm23r6ielnu@sha256:2222222222222222222222222222222222222222222222222222222222222222"}
+	oldAgent := poolagent.New(s.ap.ctx, oldCfg, s.msgClient, s.ap.txResults, s.ap.blockResults)
+	oldAgent.SetReady()
+	s.ap.agents = []*poolagent.Agent{oldAgent}
+
+	// Given that an agent is running
+	// When a new version of the agent is received
+	// Then the new version should be started as a shadow and the old version should keep running
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionRun, gomock.Any())
+	s.r.NoError(s.ap.handleAgentVersionsUpdate(messaging.AgentPayload{newCfg}))
+	s.r.Len(s.ap.agents, 2)
+	newAgent := s.ap.agents[0]
+	s.r.Equal(newCfg.Image, newAgent.Config().Image)
+	s.r.True(newAgent.IsShadow())
+	s.r.False(oldAgent.IsClosed())
+
+	// When the shadow has run for enough blocks
+	// Then the old version should be stopped and the new version should take over
+	newAgent.SetReady()
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionStop, []config.AgentConfig{oldCfg})
+	s.ap.advanceShadowRunsUnsafe()
+	s.r.Equal([]*poolagent.Agent{newAgent}, s.ap.agents)
+	s.r.False(newAgent.IsShadow())
+	s.r.True(oldAgent.IsClosed())
+
+	// And the diff report should be written
+	reports, err := os.ReadDir(reportsDir)
+	s.r.NoError(err)
+	s.r.Len(reports, 1)
+}
+
+// TestShadowRunLocal tests that the local agents are switched to the new version without a shadow run.
+func (s *Suite) TestShadowRunLocal() {
+	s.ap.cfg.Shadow = config.ShadowConfig{Enable: true, Blocks: 1}
+	oldCfg := config.AgentConfig{ID: testAgentID, Image: "forta-agent-local", IsLocal: true}
+	newCfg := config.AgentConfig{ID: testAgentID, Image: "bafybeibvkqkf7i3c5ouehviwjb2dzbukgqied3cg36axl7gzm23r6ielnu@sha256:2222222222222222222222222222222222222222222222222222222222222222"}
+	oldAgent := poolagent.New(s.ap.ctx, oldCfg, s.msgClient, s.ap.txResults, s.ap.blockResults)
+	oldAgent.SetReady()
+	s.ap.agents = []*poolagent.Agent{oldAgent}
+
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionRun, gomock.Any())
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionStop, []config.AgentConfig{oldCfg})
+	s.r.NoError(s.ap.handleAgentVersionsUpdate(messaging.AgentPayload{newCfg}))
+	s.r.Len(s.ap.agents, 1)
+	s.r.False(s.ap.agents[0].IsShadow())
+	s.r.True(oldAgent.IsClosed())
+	s.r.Len(s.ap.shadowRuns, 0)
+}
+
+// TestAgentFailed tests that the failed agents are not started again.
+func (s *Suite) TestAgentFailed() {
+	agentConfig := config.AgentConfig{
+		ID: testAgentID,
+	}
+	agentPayload := messaging.AgentPayload{
+		agentConfig,
+	}
+
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsActionRun, gomock.Any())
+	s.r.NoError(s.ap.handleAgentVersionsUpdate(agentPayload))
+	s.r.Len(s.ap.agents, 1)
+	agent := s.ap.agents[0]
+
+	// When the supervisor gives up restarting the agent
+	s.r.NoError(s.ap.handleStatusFailed(agentPayload))
+	// Then the agent is removed from the pool
+	s.r.Len(s.ap.agents, 0)
+	s.r.True(agent.IsClosed())
+
+	// And it is not started again with the same version
+	s.r.NoError(s.ap.handleAgentVersionsUpdate(agentPayload))
+	s.r.Len(s.ap.agents, 0)
+
+	// Until it is removed from the latest versions
+	s.r.NoError(s.ap.handleAgentVersionsUpdate(messaging.AgentPayload{}))
+	s.r.Len(s.ap.failedAgents, 0)
+}
@@ -2,10 +2,16 @@ package poolagent
 
 import (
 	"context"
-	"github.com/forta-network/forta-core-go/domain"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/forta-network/forta-core-go/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/forta-network/forta-node/metrics"
 	"google.golang.org/grpc"
@@ -23,8 +29,10 @@ import (
 // Constants
 const (
 	DefaultBufferSize = 2000
-	AgentTimeout      = 30 * time.Second
-	MaxFindings       = 10
+	InitializeTimeout = 30 * time.Second
+	// AgentTimeout and MaxFindings are used if the agent config does not set them.
+	AgentTimeout = 30 * time.Second
+	MaxFindings  = 10
 )
 
 // Agent receives blocks and transactions, and produces results.
@@ -36,21 +44,40 @@ type Agent struct {
 	txResults     chan<- *scanner.TxResult
 	blockRequests chan *BlockRequest // never closed - deallocated when agent is discarded
 	blockResults  chan<- *scanner.BlockResult
+	alertRequests chan *AlertRequest // never closed - deallocated when agent is discarded
 
-	errCounter *errorCounter
-	msgClient  clients.MessageClient
+	breaker   *circuitBreaker
+	msgClient clients.MessageClient
 
 	client    clients.AgentClient
+	initResp  *protocol.InitializeResponse
+	txFilter  *txFilter
 	ready     chan struct{}
 	readyOnce sync.Once
 	closed    chan struct{}
 	closeOnce sync.Once
+	stopOnce  sync.Once
+
+	shadow   bool
+	observer func(*Observation)
+	mu       sync.RWMutex
+}
+
+// Observation is the outcome of a request to the agent.
+type Observation struct {
+	// EventKey is the block number or the tx hash.
+	EventKey string
+	Latency  time.Duration
+	Err      error
+	Findings []*protocol.Finding
 }
 
 // TxRequest contains the original request data and the encoded message.
 type TxRequest struct {
 	Original *protocol.EvaluateTxRequest
 	Encoded  *grpc.PreparedMsg
+	// Pending is set if the tx is from the mempool.
+	Pending bool
 }
 
 // BlockRequest contains the original request data and the encoded message.
@@ -59,27 +86,92 @@ type BlockRequest struct {
 	Encoded  *grpc.PreparedMsg
 }
 
+// AlertRequest contains the alert event and the encoded message.
+type AlertRequest struct {
+	Original *scanner.AlertEvent
+	Encoded  *grpc.PreparedMsg
+}
+
 // New creates a new agent.
 func New(ctx context.Context, agentCfg config.AgentConfig, msgClient clients.MessageClient, txResults chan<- *scanner.TxResult, blockResults chan<- *scanner.BlockResult) *Agent {
-	return &Agent{
+	agent := &Agent{
 		ctx:           ctx,
 		config:        agentCfg,
 		txRequests:    make(chan *TxRequest, DefaultBufferSize),
 		txResults:     txResults,
 		blockRequests: make(chan *BlockRequest, DefaultBufferSize),
 		blockResults:  blockResults,
-		errCounter:    NewErrorCounter(3, isCriticalErr),
+		alertRequests: make(chan *AlertRequest, DefaultBufferSize),
 		msgClient:     msgClient,
 		ready:         make(chan struct{}),
 		closed:        make(chan struct{}),
+		txFilter:      newTxFilter(agentCfg.Filters),
 	}
+	agent.breaker = newCircuitBreaker(DefaultMinQuarantine, DefaultMaxQuarantine, agent.onCircuitChange)
+	return agent
 }
 
-func isCriticalErr(err error) bool {
-	return false
-	// errStr := err.Error()
-	// return strings.Contains(errStr, codes.DeadlineExceeded.String()) ||
-	// 	strings.Contains(errStr, codes.Unavailable.String())
+func (agent *Agent) onCircuitChange(state CircuitState, quarantine time.Duration) {
+	lg := log.WithFields(log.Fields{
+		"agent": agent.config.ID,
+		"state": state.String(),
+	})
+	var metric *protocol.AgentMetric
+	switch state {
+	case CircuitOpen:
+		lg.WithField("quarantine", quarantine).Warn("too many errors - quarantining agent")
+		metric = metrics.CreateAgentMetric(agent.config.ID, metrics.MetricCircuitOpen, quarantine.Seconds())
+	case CircuitHalfOpen:
+		lg.Info("probing agent after quarantine")
+		metric = metrics.CreateAgentMetric(agent.config.ID, metrics.MetricCircuitHalfOpen, 1)
+	case CircuitClosed:
+		lg.Info("agent recovered from quarantine")
+		metric = metrics.CreateAgentMetric(agent.config.ID, metrics.MetricCircuitClosed, 1)
+	}
+	metrics.SendAgentMetrics(agent.msgClient, []*protocol.AgentMetric{metric})
+}
+
+// SetShadow makes the results of the agent observed only, instead of being published.
+func (agent *Agent) SetShadow(shadow bool) {
+	agent.mu.Lock()
+	agent.shadow = shadow
+	agent.mu.Unlock()
+}
+
+// IsShadow tells if the agent is a shadow.
+func (agent *Agent) IsShadow() bool {
+	agent.mu.RLock()
+	defer agent.mu.RUnlock()
+	return agent.shadow
+}
+
+// SetObserver sets the function to receive the outcome of each block and tx request.
+func (agent *Agent) SetObserver(observer func(*Observation)) {
+	agent.mu.Lock()
+	agent.observer = observer
+	agent.mu.Unlock()
+}
+
+// observe passes the outcome to the observer and tells if the results should be dropped
+// because the agent is a shadow.
+func (agent *Agent) observe(eventKey string, latency time.Duration, err error, findings []*protocol.Finding) bool {
+	agent.mu.RLock()
+	observer, shadow := agent.observer, agent.shadow
+	agent.mu.RUnlock()
+	if observer != nil {
+		observer(&Observation{
+			EventKey: eventKey,
+			Latency:  latency,
+			Err:      err,
+			Findings: findings,
+		})
+	}
+	return shadow
+}
+
+// CircuitState returns the state of the agent circuit breaker.
+func (agent *Agent) CircuitState() CircuitState {
+	return agent.breaker.State()
 }
 
 // LogStatus logs the status of the agent.
@@ -90,6 +182,7 @@ func (agent *Agent) LogStatus() {
 		"txBuffer":    len(agent.txRequests),
 		"ready":       agent.IsReady(),
 		"closed":      agent.IsClosed(),
+		"circuit":     agent.CircuitState().String(),
 	}).Debug("agent status")
 }
 
@@ -113,6 +206,11 @@ func (agent *Agent) BlockRequestCh() chan<- *BlockRequest {
 	return agent.blockRequests
 }
 
+// AlertRequestCh returns the alert request channel safely.
+func (agent *Agent) AlertRequestCh() chan<- *AlertRequest {
+	return agent.alertRequests
+}
+
 // Close implements io.Closer.
 func (agent *Agent) Close() error {
 	agent.closeOnce.Do(func() {
@@ -165,11 +263,53 @@ func (agent *Agent) SetClient(agentClient clients.AgentClient) {
 	agent.client = agentClient
 }
 
+// Initialize calls the agent's Initialize method and keeps the settings from the response.
+// It returns an error if the agent does not respond with a success status.
+func (agent *Agent) Initialize() error {
+	ctx, cancel := context.WithTimeout(agent.ctx, InitializeTimeout)
+	defer cancel()
+	resp := new(protocol.InitializeResponse)
+	err := agent.client.Invoke(ctx, agentgrpc.MethodInitialize, &protocol.InitializeRequest{
+		AgentId:   agent.config.ID,
+		ProxyHost: config.JSONRPCProxyContainerName(agent.config.ChainID),
+	}, resp)
+	// older agents may not implement the method so they need to be given a pass
+	if status.Code(err) == codes.Unimplemented {
+		log.WithField("agent", agent.config.ID).Warn("agent does not implement initialize")
+		resp.Status = protocol.ResponseStatus_SUCCESS
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to initialize agent: %v", err)
+	}
+	if resp.Status != protocol.ResponseStatus_SUCCESS {
+		var errMsgs []string
+		for _, respErr := range resp.Errors {
+			errMsgs = append(errMsgs, respErr.Message)
+		}
+		return fmt.Errorf("agent initialization returned status %s: %s", resp.Status.String(), strings.Join(errMsgs, ", "))
+	}
+	agent.initResp = resp
+	// the agent can ask for the transactions which involve the addresses
+	if len(resp.Addresses) > 0 {
+		agent.txFilter = newTxFilter(agent.config.Filters, &config.TxFilters{Addresses: resp.Addresses})
+	}
+	return nil
+}
+
+// InitializeResponse returns the response from the agent initialization.
+func (agent *Agent) InitializeResponse() *protocol.InitializeResponse {
+	return agent.initResp
+}
+
 // StartProcessing launches the goroutines to concurrently process incoming requests
 // from request channels.
 func (agent *Agent) StartProcessing() {
 	go agent.processTransactions()
 	go agent.processBlocks()
+	if len(agent.config.AlertSubscriptions) > 0 {
+		go agent.processAlerts()
+	}
 }
 
 func (agent *Agent) processTransactions() {
@@ -178,27 +318,156 @@ func (agent *Agent) processTransactions() {
 		"component": "agent",
 		"evaluate":  "transaction",
 	})
+	concurrency := agent.config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	workers := make(chan struct{}, concurrency)
+	var (
+		inFlight  sync.WaitGroup
+		lastBlock string
+	)
 	for request := range agent.txRequests {
+		if agent.IsClosed() {
+			return
+		}
+		// wait for the tx requests from the previous block to finish
+		// the pending txs are not a part of any block yet
+		if !request.Pending {
+			blockNumber := request.Original.Event.Block.BlockNumber
+			if agent.config.BlockOrdering && blockNumber != lastBlock {
+				inFlight.Wait()
+			}
+			lastBlock = blockNumber
+		}
+
+		workers <- struct{}{}
+		inFlight.Add(1)
+		go func(request *TxRequest) {
+			defer func() {
+				<-workers
+				inFlight.Done()
+			}()
+			agent.processTxRequest(lg, request)
+		}(request)
+	}
+}
+
+func (agent *Agent) processTxRequest(lg *log.Entry, request *TxRequest) {
+	startTime := time.Now()
+	probe, ok := agent.breaker.Allow()
+	if !ok {
+		metrics.SendAgentMetrics(agent.msgClient, []*protocol.AgentMetric{
+			metrics.CreateAgentMetric(agent.config.ID, metrics.MetricTxDrop, 1),
+		})
+		return
+	}
+	ctx, cancel := context.WithTimeout(agent.ctx, agent.timeout())
+	lg.WithField("duration", time.Since(startTime)).Debugf("sending request")
+	resp := new(protocol.EvaluateTxResponse)
+
+	requestTime := time.Now().UTC()
+	err := agent.client.Invoke(ctx, agentgrpc.MethodEvaluateTx, request.Encoded, resp)
+	responseTime := time.Now().UTC()
+	cancel()
+	giveUp := agent.breaker.Report(probe, err)
+	// the shadow results are only observed
+	if agent.observe(request.Original.Event.Transaction.Hash, responseTime.Sub(requestTime), err, resp.Findings) && err == nil {
+		return
+	}
+	if err == nil {
+		resp.Findings = agent.truncateFindings(resp.Findings)
+		var duration time.Duration
+		resp.Timestamp, resp.LatencyMs, duration = calculateResponseTime(&startTime)
+		lg.WithField("duration", duration).Debugf("request successful")
+
+		if resp.Metadata == nil {
+			resp.Metadata = make(map[string]string)
+		}
+		resp.Metadata["imageHash"] = agent.config.ImageHash()
+
+		ts := domain.TrackingTimestampsFromMessage(request.Original.Event.Timestamps)
+		ts.BotRequest = requestTime
+		ts.BotResponse = responseTime
+
+		agent.txResults <- &scanner.TxResult{
+			AgentConfig: agent.config,
+			Request:     request.Original,
+			Response:    resp,
+			Timestamps:  ts,
+			Pending:     request.Pending,
+		}
+		lg.WithField("duration", time.Since(startTime)).Debugf("sent results")
+		return
+	}
+	lg.WithField("duration", time.Since(startTime)).WithError(err).Error("error invoking agent")
+	if giveUp {
+		lg.WithField("duration", time.Since(startTime)).Error("agent did not recover after quarantine - shutting down agent")
+		agent.stop()
+	}
+}
+
+// timeout returns the timeout of the requests to the agent.
+func (agent *Agent) timeout() time.Duration {
+	if agent.config.TimeoutSeconds > 0 {
+		return time.Duration(agent.config.TimeoutSeconds) * time.Second
+	}
+	return AgentTimeout
+}
+
+// truncateFindings keeps the findings with the highest severity if there are too many.
+func (agent *Agent) truncateFindings(findings []*protocol.Finding) []*protocol.Finding {
+	maxFindings := agent.config.MaxFindings
+	if maxFindings <= 0 {
+		maxFindings = MaxFindings
+	}
+	if len(findings) <= maxFindings {
+		return findings
+	}
+	dropped := len(findings) - maxFindings
+	droppedMetric := metrics.CreateAgentMetric(agent.config.ID, metrics.MetricFindingsDropped, float64(dropped))
+	agent.msgClient.PublishProto(messaging.SubjectMetricAgent, droppedMetric)
+	// the findings with the same severity stay in the same order
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity > findings[j].Severity
+	})
+	return findings[:maxFindings]
+}
+
+func (agent *Agent) processBlocks() {
+	lg := log.WithFields(log.Fields{
+		"agent":     agent.config.ID,
+		"component": "agent",
+		"evaluate":  "block",
+	})
+	for request := range agent.blockRequests {
 		startTime := time.Now()
 		if agent.IsClosed() {
 			return
 		}
-		ctx, cancel := context.WithTimeout(agent.ctx, AgentTimeout)
-		lg.WithField("duration", time.Since(startTime)).Debugf("sending request")
-		resp := new(protocol.EvaluateTxResponse)
 
+		probe, ok := agent.breaker.Allow()
+		if !ok {
+			metrics.SendAgentMetrics(agent.msgClient, []*protocol.AgentMetric{
+				metrics.CreateAgentMetric(agent.config.ID, metrics.MetricBlockDrop, 1),
+			})
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(agent.ctx, agent.timeout())
+		lg.WithField("duration", time.Since(startTime)).Debugf("sending request")
+		resp := new(protocol.EvaluateBlockResponse)
 		requestTime := time.Now().UTC()
-		err := agent.client.Invoke(ctx, agentgrpc.MethodEvaluateTx, request.Encoded, resp)
+		err := agent.client.Invoke(ctx, agentgrpc.MethodEvaluateBlock, request.Encoded, resp)
 		responseTime := time.Now().UTC()
 		cancel()
+		giveUp := agent.breaker.Report(probe, err)
+		// the shadow results are only observed
+		if agent.observe(request.Original.Event.BlockNumber, responseTime.Sub(requestTime), err, resp.Findings) && err == nil {
+			continue
+		}
 		if err == nil {
-			// truncate findings
-			if len(resp.Findings) > MaxFindings {
-				dropped := len(resp.Findings) - MaxFindings
-				droppedMetric := metrics.CreateAgentMetric(agent.config.ID, metrics.MetricFindingsDropped, float64(dropped))
-				agent.msgClient.PublishProto(messaging.SubjectMetricAgent, droppedMetric)
-				resp.Findings = resp.Findings[:MaxFindings]
-			}
+			resp.Findings = agent.truncateFindings(resp.Findings)
 			var duration time.Duration
 			resp.Timestamp, resp.LatencyMs, duration = calculateResponseTime(&startTime)
 			lg.WithField("duration", duration).Debugf("request successful")
@@ -212,7 +481,7 @@ func (agent *Agent) processTransactions() {
 			ts.BotRequest = requestTime
 			ts.BotResponse = responseTime
 
-			agent.txResults <- &scanner.TxResult{
+			agent.blockResults <- &scanner.BlockResult{
 				AgentConfig: agent.config,
 				Request:     request.Original,
 				Response:    resp,
@@ -222,50 +491,49 @@ func (agent *Agent) processTransactions() {
 			continue
 		}
 		lg.WithField("duration", time.Since(startTime)).WithError(err).Error("error invoking agent")
-		if agent.errCounter.TooManyErrs(err) {
-			lg.WithField("duration", time.Since(startTime)).Error("too many errors - shutting down agent")
-			agent.Close()
-			agent.msgClient.Publish(messaging.SubjectAgentsActionStop, messaging.AgentPayload{agent.config})
-			agent.msgClient.PublishProto(messaging.SubjectMetricAgent, &protocol.AgentMetricList{
-				Metrics: []*protocol.AgentMetric{{
-					AgentId:   agent.config.ID,
-					Timestamp: time.Now().Format(time.RFC3339),
-					Name:      metrics.MetricStop,
-					Value:     1,
-				}},
-			})
+		if giveUp {
+			lg.WithField("duration", time.Since(startTime)).Error("agent did not recover after quarantine - shutting down agent")
+			agent.stop()
 			return
 		}
 	}
 }
 
-func (agent *Agent) processBlocks() {
+func (agent *Agent) processAlerts() {
 	lg := log.WithFields(log.Fields{
 		"agent":     agent.config.ID,
 		"component": "agent",
-		"evaluate":  "block",
+		"evaluate":  "alert",
 	})
-	for request := range agent.blockRequests {
+	for request := range agent.alertRequests {
 		startTime := time.Now()
 		if agent.IsClosed() {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(agent.ctx, AgentTimeout)
+		probe, ok := agent.breaker.Allow()
+		if !ok {
+			metrics.SendAgentMetrics(agent.msgClient, []*protocol.AgentMetric{
+				metrics.CreateAgentMetric(agent.config.ID, metrics.MetricAlertDrop, 1),
+			})
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(agent.ctx, agent.timeout())
 		lg.WithField("duration", time.Since(startTime)).Debugf("sending request")
-		resp := new(protocol.EvaluateBlockResponse)
+		// the alert response has the same fields as the tx and block responses
+		resp := new(protocol.EvaluateTxResponse)
 		requestTime := time.Now().UTC()
-		err := agent.client.Invoke(ctx, agentgrpc.MethodEvaluateBlock, request.Encoded, resp)
+		err := agent.client.Invoke(ctx, agentgrpc.MethodEvaluateAlert, request.Encoded, resp)
 		responseTime := time.Now().UTC()
 		cancel()
+		giveUp := agent.breaker.Report(probe, err)
+		// the shadow results are only observed
+		if agent.observe(request.Original.Alert().Id, responseTime.Sub(requestTime), err, resp.Findings) && err == nil {
+			continue
+		}
 		if err == nil {
-			// truncate findings
-			if len(resp.Findings) > MaxFindings {
-				dropped := len(resp.Findings) - MaxFindings
-				droppedMetric := metrics.CreateAgentMetric(agent.config.ID, metrics.MetricFindingsDropped, float64(dropped))
-				agent.msgClient.PublishProto(messaging.SubjectMetricAgent, droppedMetric)
-				resp.Findings = resp.Findings[:MaxFindings]
-			}
+			resp.Findings = agent.truncateFindings(resp.Findings)
 			var duration time.Duration
 			resp.Timestamp, resp.LatencyMs, duration = calculateResponseTime(&startTime)
 			lg.WithField("duration", duration).Debugf("request successful")
@@ -275,35 +543,88 @@ func (agent *Agent) processBlocks() {
 			}
 			resp.Metadata["imageHash"] = agent.config.ImageHash()
 
-			ts := domain.TrackingTimestampsFromMessage(request.Original.Event.Timestamps)
+			notif := request.Original.Notification
+			ts := domain.TrackingTimestampsFromMessage(notif.Timestamps)
 			ts.BotRequest = requestTime
 			ts.BotResponse = responseTime
 
-			agent.blockResults <- &scanner.BlockResult{
-				AgentConfig: agent.config,
-				Request:     request.Original,
-				Response:    resp,
-				Timestamps:  ts,
+			// the findings are about the block or the tx of the source alert
+			if notif.EvalTxRequest != nil {
+				agent.txResults <- &scanner.TxResult{
+					AgentConfig: agent.config,
+					Request:     notif.EvalTxRequest,
+					Response:    resp,
+					Timestamps:  ts,
+					SourceEvent: request.Original,
+				}
+			} else {
+				agent.blockResults <- &scanner.BlockResult{
+					AgentConfig: agent.config,
+					Request:     notif.EvalBlockRequest,
+					Response: &protocol.EvaluateBlockResponse{
+						Status:    resp.Status,
+						Errors:    resp.Errors,
+						Findings:  resp.Findings,
+						Metadata:  resp.Metadata,
+						Timestamp: resp.Timestamp,
+						LatencyMs: resp.LatencyMs,
+						Private:   resp.Private,
+					},
+					Timestamps:  ts,
+					SourceEvent: request.Original,
+				}
 			}
 			lg.WithField("duration", time.Since(startTime)).Debugf("sent results")
 			continue
 		}
 		lg.WithField("duration", time.Since(startTime)).WithError(err).Error("error invoking agent")
-		if agent.errCounter.TooManyErrs(err) {
-			lg.WithField("duration", time.Since(startTime)).Error("too many errors - shutting down agent")
-			agent.Close()
-			agent.msgClient.Publish(messaging.SubjectAgentsActionStop, messaging.AgentPayload{agent.config})
+		if giveUp {
+			lg.WithField("duration", time.Since(startTime)).Error("agent did not recover after quarantine - shutting down agent")
+			agent.stop()
 			return
 		}
 	}
 }
 
+// stop closes the agent and asks for the agent container to be stopped.
+func (agent *Agent) stop() {
+	agent.stopOnce.Do(func() {
+		agent.Close()
+		agent.msgClient.Publish(messaging.SubjectAgentsActionStop, messaging.AgentPayload{agent.config})
+		metrics.SendAgentMetrics(agent.msgClient, []*protocol.AgentMetric{
+			metrics.CreateAgentMetric(agent.config.ID, metrics.MetricStop, 1),
+		})
+	})
+}
+
 func calculateResponseTime(startTime *time.Time) (timestamp string, latencyMs uint32, duration time.Duration) {
 	now := time.Now().UTC()
 	duration = now.Sub(*startTime)
 	return now.Format(time.RFC3339), uint32(duration.Milliseconds()), duration
 }
 
+// ShouldProcessTx tells if the transaction matches the agent filters.
+func (agent *Agent) ShouldProcessTx(evt *protocol.TransactionEvent) bool {
+	return agent.txFilter.Matches(evt)
+}
+
+// ShouldProcessAlert tells if the agent subscribes to the alert. The agents never receive
+// the alerts which are based on their own alerts.
+func (agent *Agent) ShouldProcessAlert(evt *scanner.AlertEvent) bool {
+	for _, agentID := range evt.AgentChain {
+		if strings.EqualFold(agentID, agent.config.ID) {
+			return false
+		}
+	}
+	alert := evt.Alert()
+	for _, sub := range agent.config.AlertSubscriptions {
+		if sub.Matches(alert.Agent.Id, alert.Finding.AlertId) {
+			return true
+		}
+	}
+	return false
+}
+
 // ShouldProcessBlock tells if the agent should process block.
 func (agent *Agent) ShouldProcessBlock(blockNumberHex string) bool {
 	blockNumber, _ := hexutil.DecodeUint64(blockNumberHex)
@@ -0,0 +1,125 @@
+package poolagent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-node/clients/agentgrpc"
+	mock_clients "github.com/forta-network/forta-node/clients/mocks"
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/services/scanner"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func testTxRequest(blockNumber string) *TxRequest {
+	return &TxRequest{
+		Original: &protocol.EvaluateTxRequest{
+			Event: &protocol.TransactionEvent{
+				Block:       &protocol.TransactionEvent_EthBlock{BlockNumber: blockNumber},
+				Transaction: &protocol.TransactionEvent_EthTransaction{},
+			},
+		},
+	}
+}
+
+func TestConcurrentTxRequests(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	agentClient := mock_clients.NewMockAgentClient(ctrl)
+	txResults := make(chan *scanner.TxResult)
+	agent := New(context.Background(), config.AgentConfig{
+		ID:            "test-agent",
+		Concurrency:   2,
+		BlockOrdering: true,
+	}, mock_clients.NewMockMessageClient(ctrl), txResults, nil)
+	agent.SetClient(agentClient)
+
+	invoked := make(chan struct{}, 3)
+	release := make(chan struct{})
+	agentClient.EXPECT().Invoke(gomock.Any(), agentgrpc.MethodEvaluateTx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, method agentgrpc.Method, in, out interface{}, opts ...grpc.CallOption) error {
+			invoked <- struct{}{}
+			<-release
+			return nil
+		}).Times(3)
+	agent.StartProcessing()
+
+	agent.TxRequestCh() <- testTxRequest("0x1")
+	agent.TxRequestCh() <- testTxRequest("0x1")
+	agent.TxRequestCh() <- testTxRequest("0x2")
+
+	// the requests from the same block are in flight at the same time
+	<-invoked
+	<-invoked
+	// and the next block waits for them
+	select {
+	case <-invoked:
+		r.FailNow("next block should not be processed before the previous block")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		result := <-txResults
+		r.NotNil(result.Response)
+	}
+	<-invoked
+}
+
+func testAlertEvent(agentID, alertID string, agentChain ...string) *scanner.AlertEvent {
+	return &scanner.AlertEvent{
+		Notification: &protocol.NotifyRequest{
+			SignedAlert: &protocol.SignedAlert{
+				Alert: &protocol.Alert{
+					Agent:   &protocol.AgentInfo{Id: agentID},
+					Finding: &protocol.Finding{AlertId: alertID},
+				},
+			},
+		},
+		AgentChain: append(agentChain, agentID),
+	}
+}
+
+func TestShouldProcessAlert(t *testing.T) {
+	r := require.New(t)
+
+	agent := New(context.Background(), config.AgentConfig{
+		ID: "0xcombiner",
+		AlertSubscriptions: []config.AlertSubscription{
+			{AgentID: "0xsource", AlertID: "SUSPICIOUS-*"},
+		},
+	}, nil, nil, nil)
+
+	r.True(agent.ShouldProcessAlert(testAlertEvent("0xSOURCE", "SUSPICIOUS-1")))
+	r.False(agent.ShouldProcessAlert(testAlertEvent("0xsource", "INFO-1")))
+	r.False(agent.ShouldProcessAlert(testAlertEvent("0xother", "SUSPICIOUS-1")))
+	// the alerts based on the alerts of the agent itself are not sent back
+	r.False(agent.ShouldProcessAlert(testAlertEvent("0xsource", "SUSPICIOUS-1", "0xcombiner")))
+}
+
+func TestTruncateFindings(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	msgClient := mock_clients.NewMockMessageClient(ctrl)
+	agent := New(context.Background(), config.AgentConfig{
+		ID:          "test-agent",
+		MaxFindings: 2,
+	}, msgClient, nil, nil)
+
+	msgClient.EXPECT().PublishProto(gomock.Any(), gomock.Any())
+	findings := agent.truncateFindings([]*protocol.Finding{
+		{AlertId: "1", Severity: protocol.Finding_LOW},
+		{AlertId: "2", Severity: protocol.Finding_CRITICAL},
+		{AlertId: "3", Severity: protocol.Finding_INFO},
+		{AlertId: "4", Severity: protocol.Finding_CRITICAL},
+	})
+	r.Len(findings, 2)
+	r.Equal("2", findings[0].AlertId)
+	r.Equal("4", findings[1].AlertId)
+}
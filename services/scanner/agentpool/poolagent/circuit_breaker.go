@@ -0,0 +1,185 @@
+package poolagent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Circuit breaker defaults
+const (
+	DefaultMaxCriticalErrs  = 3
+	DefaultMinQuarantine    = 30 * time.Second
+	DefaultMaxQuarantine    = 10 * time.Minute
+	DefaultMaxProbeFailures = 6
+	quarantineBackoffFactor = 2
+)
+
+// CircuitState is the state of an agent circuit breaker.
+type CircuitState int
+
+// Circuit breaker states
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen quarantines the agent and drops all requests until the backoff ends.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through after the quarantine.
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// isCriticalErr tells if the error means that the agent is hung, down or overloaded.
+func isCriticalErr(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// circuitBreaker quarantines an agent after consecutive critical errors. The quarantine
+// gets longer each time the probe after the quarantine fails.
+type circuitBreaker struct {
+	errCounter       *errorCounter
+	minQuarantine    time.Duration
+	maxQuarantine    time.Duration
+	maxProbeFailures uint
+	onChange         func(state CircuitState, quarantine time.Duration)
+	now              func() time.Time
+
+	state         CircuitState
+	quarantine    time.Duration
+	openUntil     time.Time
+	probeFailures uint
+	probe         uint64
+	mu            sync.Mutex
+}
+
+// circuitChange is a state change which is notified after the lock is released.
+type circuitChange struct {
+	state      CircuitState
+	quarantine time.Duration
+}
+
+func newCircuitBreaker(minQuarantine, maxQuarantine time.Duration, onChange func(state CircuitState, quarantine time.Duration)) *circuitBreaker {
+	return &circuitBreaker{
+		errCounter:       NewErrorCounter(DefaultMaxCriticalErrs, isCriticalErr),
+		minQuarantine:    minQuarantine,
+		maxQuarantine:    maxQuarantine,
+		maxProbeFailures: DefaultMaxProbeFailures,
+		onChange:         onChange,
+		now:              time.Now,
+	}
+}
+
+// State returns the current state.
+func (cb *circuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// Allow tells if a request should be sent to the agent. Only one request is allowed
+// after the quarantine and the rest are not allowed until the result of that request is reported.
+// The returned probe should be reported with the result so that the results of the requests
+// which were allowed before the quarantine are not mistaken for the probe result.
+func (cb *circuitBreaker) Allow() (probe uint64, ok bool) {
+	cb.mu.Lock()
+	probe, ok, change := cb.allowUnsafe()
+	cb.mu.Unlock()
+	cb.notify(change)
+	return probe, ok
+}
+
+func (cb *circuitBreaker) allowUnsafe() (uint64, bool, *circuitChange) {
+	switch cb.state {
+	case CircuitClosed:
+		return 0, true, nil
+	case CircuitOpen:
+		if cb.now().Before(cb.openUntil) {
+			return 0, false, nil
+		}
+		cb.probe++
+		return cb.probe, true, cb.setState(CircuitHalfOpen)
+	default: // half-open: the probe is in flight
+		return 0, false, nil
+	}
+}
+
+// Report reports the result of an allowed request with the probe returned from Allow. It returns
+// true if the agent kept failing the probes and should be given up on.
+func (cb *circuitBreaker) Report(probe uint64, err error) (giveUp bool) {
+	cb.mu.Lock()
+	giveUp, change := cb.reportUnsafe(probe, err)
+	cb.mu.Unlock()
+	cb.notify(change)
+	return giveUp
+}
+
+func (cb *circuitBreaker) reportUnsafe(probe uint64, err error) (bool, *circuitChange) {
+	switch cb.state {
+	case CircuitClosed:
+		if cb.errCounter.TooManyErrs(err) {
+			cb.quarantine = cb.minQuarantine
+			return false, cb.open()
+		}
+	case CircuitHalfOpen:
+		// only the probe result can close or open the circuit again
+		if probe == 0 || probe != cb.probe {
+			return false, nil
+		}
+		if err == nil || !isCriticalErr(err) {
+			cb.errCounter.TooManyErrs(nil) // reset
+			cb.quarantine = 0
+			cb.probeFailures = 0
+			return false, cb.setState(CircuitClosed)
+		}
+		cb.probeFailures++
+		if cb.probeFailures >= cb.maxProbeFailures {
+			return true, nil
+		}
+		cb.quarantine *= quarantineBackoffFactor
+		if cb.quarantine > cb.maxQuarantine {
+			cb.quarantine = cb.maxQuarantine
+		}
+		return false, cb.open()
+	}
+	return false, nil
+}
+
+func (cb *circuitBreaker) open() *circuitChange {
+	cb.openUntil = cb.now().Add(cb.quarantine)
+	return cb.setState(CircuitOpen)
+}
+
+func (cb *circuitBreaker) setState(state CircuitState) *circuitChange {
+	cb.state = state
+	return &circuitChange{state: state, quarantine: cb.quarantine}
+}
+
+func (cb *circuitBreaker) notify(change *circuitChange) {
+	if change != nil && cb.onChange != nil {
+		cb.onChange(change.state, change.quarantine)
+	}
+}
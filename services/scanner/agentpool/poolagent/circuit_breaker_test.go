@@ -0,0 +1,92 @@
+package poolagent
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsCriticalErr(t *testing.T) {
+	r := require.New(t)
+
+	r.True(isCriticalErr(status.Error(codes.DeadlineExceeded, "timeout")))
+	r.True(isCriticalErr(status.Error(codes.Unavailable, "down")))
+	r.True(isCriticalErr(status.Error(codes.ResourceExhausted, "overloaded")))
+	r.False(isCriticalErr(status.Error(codes.Internal, "agent bug")))
+	r.False(isCriticalErr(status.Error(codes.Canceled, "canceled")))
+	r.False(isCriticalErr(errors.New("other")))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	r := require.New(t)
+
+	var (
+		cb     *circuitBreaker
+		states []CircuitState
+	)
+	cb = newCircuitBreaker(time.Millisecond*10, time.Millisecond*20, func(state CircuitState, quarantine time.Duration) {
+		// the state changes are notified without holding the lock
+		r.Equal(state, cb.State())
+		states = append(states, state)
+	})
+	cb.maxProbeFailures = 3
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+	criticalErr := status.Error(codes.Unavailable, "down")
+	allow := func() uint64 {
+		probe, ok := cb.Allow()
+		r.True(ok)
+		return probe
+	}
+
+	// trips after consecutive critical errors
+	lateProbe := allow()
+	for i := 0; i < DefaultMaxCriticalErrs; i++ {
+		r.False(cb.Report(allow(), criticalErr))
+	}
+	r.Equal(CircuitOpen, cb.State())
+	_, ok := cb.Allow()
+	r.False(ok)
+
+	// lets only one probe through after the quarantine
+	now = now.Add(time.Millisecond * 15)
+	probe := allow()
+	r.Equal(CircuitHalfOpen, cb.State())
+	_, ok = cb.Allow()
+	r.False(ok)
+
+	// the request which was allowed before the quarantine does not close the circuit
+	r.False(cb.Report(lateProbe, nil))
+	r.Equal(CircuitHalfOpen, cb.State())
+
+	// the failed probe doubles the quarantine
+	r.False(cb.Report(probe, criticalErr))
+	r.Equal(time.Millisecond*20, cb.quarantine)
+	now = now.Add(time.Millisecond * 15)
+	_, ok = cb.Allow()
+	r.False(ok)
+
+	// recovers after a successful probe
+	now = now.Add(time.Millisecond * 10)
+	probe = allow()
+	r.False(cb.Report(probe-1, nil))
+	r.Equal(CircuitHalfOpen, cb.State())
+	r.False(cb.Report(probe, nil))
+	r.Equal(CircuitClosed, cb.State())
+	r.Equal([]CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}, states)
+
+	// gives up after too many failed probes
+	for i := 0; i < DefaultMaxCriticalErrs; i++ {
+		cb.Report(allow(), criticalErr)
+	}
+	var giveUp bool
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Millisecond * 25)
+		giveUp = cb.Report(allow(), criticalErr)
+	}
+	r.True(giveUp)
+}
@@ -0,0 +1,78 @@
+package poolagent
+
+import (
+	"strings"
+
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-node/config"
+)
+
+const selectorLength = len("0x12345678")
+
+// txFilter matches the transactions with the agent filters.
+type txFilter struct {
+	addresses map[string]bool
+	topics    map[string]bool
+	selectors map[string]bool
+}
+
+// newTxFilter merges the given filters. It returns nil if there are no filters.
+func newTxFilter(filtersList ...*config.TxFilters) *txFilter {
+	filter := &txFilter{
+		addresses: make(map[string]bool),
+		topics:    make(map[string]bool),
+		selectors: make(map[string]bool),
+	}
+	var hasFilters bool
+	for _, filters := range filtersList {
+		if filters.IsEmpty() {
+			continue
+		}
+		hasFilters = true
+		addToSet(filter.addresses, filters.Addresses)
+		addToSet(filter.topics, filters.Topics)
+		addToSet(filter.selectors, filters.Selectors)
+	}
+	if !hasFilters {
+		return nil
+	}
+	return filter
+}
+
+func addToSet(set map[string]bool, values []string) {
+	for _, value := range values {
+		set[strings.ToLower(value)] = true
+	}
+}
+
+// Matches tells if the transaction matches any of the filters.
+func (filter *txFilter) Matches(evt *protocol.TransactionEvent) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.addresses) > 0 {
+		for address := range evt.Addresses {
+			if filter.addresses[strings.ToLower(address)] {
+				return true
+			}
+		}
+		if evt.Transaction != nil && (filter.addresses[strings.ToLower(evt.Transaction.To)] || filter.addresses[strings.ToLower(evt.Transaction.From)]) {
+			return true
+		}
+	}
+	if len(filter.topics) > 0 {
+		for _, log := range evt.Logs {
+			for _, topic := range log.Topics {
+				if filter.topics[strings.ToLower(topic)] {
+					return true
+				}
+			}
+		}
+	}
+	if len(filter.selectors) > 0 && evt.Transaction != nil && len(evt.Transaction.Input) >= selectorLength {
+		if filter.selectors[strings.ToLower(evt.Transaction.Input[:selectorLength])] {
+			return true
+		}
+	}
+	return false
+}
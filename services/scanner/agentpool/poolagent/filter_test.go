@@ -0,0 +1,41 @@
+package poolagent
+
+import (
+	"testing"
+
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-node/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxFilter(t *testing.T) {
+	r := require.New(t)
+
+	evt := &protocol.TransactionEvent{
+		Transaction: &protocol.TransactionEvent_EthTransaction{
+			From:  "0xaaaa",
+			To:    "0xbbbb",
+			Input: "0xa9059cbb0000",
+		},
+		Addresses: map[string]bool{"0xcccc": true},
+		Logs: []*protocol.TransactionEvent_Log{
+			{Topics: []string{"0xddf252ad"}},
+		},
+	}
+
+	r.Nil(newTxFilter(nil, &config.TxFilters{}))
+	r.True(newTxFilter(nil).Matches(evt))
+
+	r.True(newTxFilter(&config.TxFilters{Addresses: []string{"0xBBBB"}}).Matches(evt))
+	r.True(newTxFilter(&config.TxFilters{Addresses: []string{"0xCCCC"}}).Matches(evt))
+	r.True(newTxFilter(&config.TxFilters{Topics: []string{"0xDDF252AD"}}).Matches(evt))
+	r.True(newTxFilter(&config.TxFilters{Selectors: []string{"0xa9059cbb"}}).Matches(evt))
+	r.False(newTxFilter(&config.TxFilters{
+		Addresses: []string{"0xeeee"},
+		Topics:    []string{"0xffff"},
+		Selectors: []string{"0x12345678"},
+	}).Matches(evt))
+
+	// merged filters match any
+	r.True(newTxFilter(&config.TxFilters{Addresses: []string{"0xeeee"}}, &config.TxFilters{Addresses: []string{"0xaaaa"}}).Matches(evt))
+}
@@ -0,0 +1,179 @@
+package agentpool
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-node/services/scanner/agentpool/poolagent"
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+)
+
+// ShadowStats contains the outcome of the requests to one of the agent versions.
+type ShadowStats struct {
+	Image        string  `json:"image"`
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	Findings     int     `json:"findings"`
+	AvgLatencyMs float64 `json:"avgLatencyMs"`
+
+	totalLatency time.Duration
+	findings     map[string]int
+}
+
+func (stats *ShadowStats) add(obs *poolagent.Observation) {
+	stats.Requests++
+	stats.totalLatency += obs.Latency
+	stats.AvgLatencyMs = float64(stats.totalLatency.Milliseconds()) / float64(stats.Requests)
+	if obs.Err != nil {
+		stats.Errors++
+		return
+	}
+	stats.Findings += len(obs.Findings)
+	for _, finding := range obs.Findings {
+		stats.findings[findingKey(obs.EventKey, finding)]++
+	}
+}
+
+func findingKey(eventKey string, finding *protocol.Finding) string {
+	return fmt.Sprintf("%s|%s|%s", eventKey, finding.AlertId, finding.Severity.String())
+}
+
+// ShadowReport compares the old version of an agent to the new version which ran as a shadow.
+type ShadowReport struct {
+	AgentID    string      `json:"agentId"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt time.Time   `json:"finishedAt"`
+	Blocks     int         `json:"blocks"`
+	Primary    ShadowStats `json:"primary"`
+	Shadow     ShadowStats `json:"shadow"`
+	// The findings are matched by the event, the alert ID and the severity.
+	MatchingFindings    int `json:"matchingFindings"`
+	PrimaryOnlyFindings int `json:"primaryOnlyFindings"`
+	ShadowOnlyFindings  int `json:"shadowOnlyFindings"`
+}
+
+// shadowRun runs the new version of an agent next to the old version.
+type shadowRun struct {
+	primary *poolagent.Agent
+	shadow  *poolagent.Agent
+	report  ShadowReport
+	mu      sync.Mutex
+}
+
+func newShadowRun(primary, shadow *poolagent.Agent) *shadowRun {
+	run := &shadowRun{
+		primary: primary,
+		shadow:  shadow,
+		report: ShadowReport{
+			AgentID:   primary.Config().ID,
+			StartedAt: time.Now().UTC(),
+			Primary:   ShadowStats{Image: primary.Config().Image, findings: make(map[string]int)},
+			Shadow:    ShadowStats{Image: shadow.Config().Image, findings: make(map[string]int)},
+		},
+	}
+	shadow.SetShadow(true)
+	primary.SetObserver(run.observe(&run.report.Primary))
+	shadow.SetObserver(run.observe(&run.report.Shadow))
+	return run
+}
+
+func (run *shadowRun) observe(stats *ShadowStats) func(*poolagent.Observation) {
+	return func(obs *poolagent.Observation) {
+		run.mu.Lock()
+		stats.add(obs)
+		run.mu.Unlock()
+	}
+}
+
+// blockDone counts the blocks after the shadow is ready.
+func (run *shadowRun) blockDone() int {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.shadow.IsReady() {
+		run.report.Blocks++
+	}
+	return run.report.Blocks
+}
+
+// finish stops observing the agents and compares the findings.
+func (run *shadowRun) finish() *ShadowReport {
+	run.primary.SetObserver(nil)
+	run.shadow.SetObserver(nil)
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	report := run.report
+	report.FinishedAt = time.Now().UTC()
+	for key, count := range report.Primary.findings {
+		shadowCount := report.Shadow.findings[key]
+		if shadowCount >= count {
+			report.MatchingFindings += count
+		} else {
+			report.MatchingFindings += shadowCount
+			report.PrimaryOnlyFindings += count - shadowCount
+		}
+	}
+	for key, count := range report.Shadow.findings {
+		if primaryCount := report.Primary.findings[key]; count > primaryCount {
+			report.ShadowOnlyFindings += count - primaryCount
+		}
+	}
+	return &report
+}
+
+// writeShadowReport writes the report to the reports dir.
+func writeShadowReport(dir string, report *ShadowReport) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create shadow reports dir: %v", err)
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fileName := fmt.Sprintf("%s-%d.json", report.AgentID, report.FinishedAt.Unix())
+	return os.WriteFile(path.Join(dir, fileName), b, 0644)
+}
+
+// advanceShadowRunsUnsafe counts the block for the shadow runs and switches
+// to the new versions after enough blocks.
+func (ap *AgentPool) advanceShadowRunsUnsafe() {
+	for agentID, run := range ap.shadowRuns {
+		// the new version could not start or stopped working
+		if run.shadow.IsClosed() {
+			log.WithField("agent", agentID).Warn("shadow agent is closed - cancelled the switch to the new version")
+			run.finish()
+			delete(ap.shadowRuns, agentID)
+			continue
+		}
+		if run.blockDone() < ap.cfg.Shadow.Blocks && !run.primary.IsClosed() {
+			continue
+		}
+
+		report := run.finish()
+		delete(ap.shadowRuns, agentID)
+		log.WithFields(log.Fields{
+			"agent":               agentID,
+			"blocks":              report.Blocks,
+			"primaryErrors":       report.Primary.Errors,
+			"shadowErrors":        report.Shadow.Errors,
+			"primaryLatencyMs":    report.Primary.AvgLatencyMs,
+			"shadowLatencyMs":     report.Shadow.AvgLatencyMs,
+			"matchingFindings":    report.MatchingFindings,
+			"primaryOnlyFindings": report.PrimaryOnlyFindings,
+			"shadowOnlyFindings":  report.ShadowOnlyFindings,
+		}).Info("shadow run finished - switching to the new version")
+		if len(ap.cfg.Shadow.ReportsDir) > 0 {
+			if err := writeShadowReport(ap.cfg.Shadow.ReportsDir, report); err != nil {
+				log.WithError(err).Error("failed to write shadow report")
+			}
+		}
+
+		run.shadow.SetShadow(false)
+		ap.stopAgentsUnsafe(run.primary)
+	}
+}
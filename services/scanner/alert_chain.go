@@ -0,0 +1,39 @@
+package scanner
+
+import (
+	"strings"
+
+	"github.com/forta-network/forta-core-go/protocol"
+)
+
+// TagSourceAlertIDs is the alert tag and the finding metadata key which lists the IDs of the alerts
+// that a combined alert is based on.
+const TagSourceAlertIDs = "sourceAlertIds"
+
+// newAlertEvent creates the event to send an alert to the subscribed agents.
+func newAlertEvent(notif *protocol.NotifyRequest, agentID string, source *AlertEvent) *AlertEvent {
+	var agentChain []string
+	if source != nil {
+		agentChain = append(agentChain, source.AgentChain...)
+	}
+	return &AlertEvent{
+		Notification: notif,
+		AgentChain:   append(agentChain, agentID),
+	}
+}
+
+// addSourceAlertIDs makes the combined alert reference the alert that it is based on
+// and the other alerts that the agent listed in the finding metadata.
+func addSourceAlertIDs(tags map[string]string, f *protocol.Finding, source *AlertEvent) {
+	if source == nil {
+		return
+	}
+	sourceIDs := []string{source.Alert().Id}
+	for _, alertID := range strings.Split(f.Metadata[TagSourceAlertIDs], ",") {
+		alertID = strings.TrimSpace(alertID)
+		if len(alertID) > 0 && alertID != sourceIDs[0] {
+			sourceIDs = append(sourceIDs, alertID)
+		}
+	}
+	tags[TagSourceAlertIDs] = strings.Join(sourceIDs, ",")
+}
@@ -4,22 +4,29 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/forta-network/forta-core-go/feeds"
+	"github.com/forta-network/forta-core-go/protocol"
 	"github.com/forta-network/forta-core-go/utils"
 	"github.com/goccy/go-json"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/store"
 )
 
 // API allows triggering things on scanner
 type API struct {
-	ctx     context.Context
-	started bool
-	feed    feeds.BlockFeed
-	server  *http.Server
+	ctx        context.Context
+	started    bool
+	feed       feeds.BlockFeed
+	alertStore store.AlertStore
+	server     *http.Server
 }
 
 type Message struct {
@@ -74,9 +81,89 @@ func (a *API) startBlocks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func parseTimeParam(query map[string][]string, name string) (time.Time, error) {
+	values := query[name]
+	if len(values) == 0 || len(values[0]) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, values[0])
+}
+
+func parseUintParam(query map[string][]string, name string) (uint64, error) {
+	values := query[name]
+	if len(values) == 0 || len(values[0]) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseUint(values[0], 10, 64)
+}
+
+func (a *API) queryAlerts(w http.ResponseWriter, r *http.Request) {
+	if a.alertStore == nil {
+		writeError(w, 404, "local alerts are disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	q := &store.AlertQuery{
+		AgentID: query.Get("agentId"),
+		ID:      query.Get("id"),
+		AlertID: query.Get("alertId"),
+	}
+
+	var err error
+	if q.From, err = parseTimeParam(query, "from"); err != nil {
+		writeError(w, 400, "?from must be an RFC3339 timestamp")
+		return
+	}
+	if q.To, err = parseTimeParam(query, "to"); err != nil {
+		writeError(w, 400, "?to must be an RFC3339 timestamp")
+		return
+	}
+	if q.BlockStart, err = parseUintParam(query, "blockStart"); err != nil {
+		writeError(w, 400, "?blockStart must be integer")
+		return
+	}
+	if q.BlockEnd, err = parseUintParam(query, "blockEnd"); err != nil {
+		writeError(w, 400, "?blockEnd must be integer")
+		return
+	}
+	limit, err := parseUintParam(query, "limit")
+	if err != nil {
+		writeError(w, 400, "?limit must be integer")
+		return
+	}
+	q.Limit = int(limit)
+
+	if severity := query.Get("severity"); len(severity) > 0 {
+		severity = strings.ToUpper(severity)
+		if _, ok := protocol.Finding_Severity_value[severity]; !ok {
+			writeError(w, 400, "?severity is not valid")
+			return
+		}
+		q.Severity = severity
+	}
+
+	alerts, err := a.alertStore.Query(q)
+	if err != nil {
+		log.WithError(err).Error("failed to query local alerts")
+		writeError(w, 500, "failed to query alerts")
+		return
+	}
+	if alerts == nil {
+		alerts = []*store.StoredAlert{}
+	}
+	b, _ := json.Marshal(alerts)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	if _, err := w.Write(b); err != nil {
+		log.WithError(err).Error("error writing alerts")
+	}
+}
+
 func (t *API) Start() error {
 	router := mux.NewRouter().StrictSlash(true)
 	router.HandleFunc("/start", t.startBlocks)
+	router.HandleFunc("/alerts", t.queryAlerts).Methods(http.MethodGet)
 
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
@@ -84,7 +171,7 @@ func (t *API) Start() error {
 	})
 
 	t.server = &http.Server{
-		Addr:    ":80",
+		Addr:    ":" + config.DefaultScannerAPIPort,
 		Handler: c.Handler(router),
 	}
 	utils.GoListenAndServe(t.server)
@@ -103,9 +190,10 @@ func (t *API) Name() string {
 	return "ScannerAPI"
 }
 
-func NewScannerAPI(ctx context.Context, feed feeds.BlockFeed) *API {
+func NewScannerAPI(ctx context.Context, feed feeds.BlockFeed, alertStore store.AlertStore) *API {
 	return &API{
-		ctx:  ctx,
-		feed: feed,
+		ctx:        ctx,
+		feed:       feed,
+		alertStore: alertStore,
 	}
 }
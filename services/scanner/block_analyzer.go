@@ -35,6 +35,8 @@ type BlockAnalyzerServiceConfig struct {
 	AlertSender  clients.AlertSender
 	AgentPool    AgentPool
 	MsgClient    clients.MessageClient
+	// FindingValidator is set to check the findings before they become alerts.
+	FindingValidator *FindingValidator
 }
 
 // WARNING, this must be deterministic (any maps must be converted to sorted lists)
@@ -51,6 +53,10 @@ func (t *BlockAnalyzerService) calculateAlertID(result *BlockResult, f *protocol
 		result.AgentConfig.Image,
 		result.AgentConfig.ID,
 		strings.Join(f.Addresses, "")}, "")
+	// the same combined finding can be based on different alerts
+	if result.SourceEvent != nil {
+		idStr += result.SourceEvent.Alert().Id
+	}
 	return crypto.Keccak256Hash([]byte(idStr)).Hex()
 }
 
@@ -81,6 +87,7 @@ func (t *BlockAnalyzerService) findingToAlert(result *BlockResult, ts time.Time,
 		tags["blockHash"] = result.Request.Event.BlockHash
 		tags["blockNumber"] = blockNumber.String()
 	}
+	addSourceAlertIDs(tags, f, result.SourceEvent)
 	return &protocol.Alert{
 		Id:         alertID,
 		Finding:    f,
@@ -108,6 +115,12 @@ func (t *BlockAnalyzerService) Start() error {
 			}
 			log.Debugf(resStr)
 
+			if t.cfg.FindingValidator != nil {
+				findings, findingMetrics := t.cfg.FindingValidator.ValidateFindings(result.AgentConfig, result.Response.Findings)
+				result.Response.Findings = findings
+				metrics.SendAgentMetrics(t.cfg.MsgClient, findingMetrics)
+			}
+
 			rt := &clients.AgentRoundTrip{
 				AgentConfig:       result.AgentConfig,
 				EvalBlockRequest:  result.Request,
@@ -133,6 +146,19 @@ func (t *BlockAnalyzerService) Start() error {
 				); err != nil {
 					log.WithError(err).Panic("failed sign alert and notify")
 				}
+				// let the subscribed agents evaluate the public alerts
+				if alert.Type != protocol.AlertType_PRIVATE {
+					t.cfg.AgentPool.SendEvaluateAlertRequest(newAlertEvent(&protocol.NotifyRequest{
+						SignedAlert: &protocol.SignedAlert{
+							Alert:       alert,
+							ChainId:     result.Request.Event.Network.ChainId,
+							BlockNumber: result.Request.Event.BlockNumber,
+						},
+						EvalBlockRequest: result.Request,
+						AgentInfo:        alert.Agent,
+						Timestamps:       result.Timestamps.ToMessage(),
+					}, result.AgentConfig.ID, result.SourceEvent))
+				}
 			}
 			t.publishMetrics(result)
 
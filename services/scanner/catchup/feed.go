@@ -0,0 +1,117 @@
+package catchup
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/feeds"
+	log "github.com/sirupsen/logrus"
+)
+
+// Range is the block range to catch up with.
+type Range struct {
+	From   uint64
+	To     uint64
+	RateMs int64
+}
+
+// Feed first plays the missed blocks from the catch-up feed and then follows
+// the chain head with the live feed.
+type Feed struct {
+	catchUp feeds.BlockFeed
+	live    feeds.BlockFeed
+	rng     Range
+
+	subscriptions int
+	caughtUp      int
+	inLive        bool
+	mu            sync.Mutex
+}
+
+// NewFeed creates a new catch-up feed. The live feed should start right after the range.
+func NewFeed(catchUp, live feeds.BlockFeed, rng Range) *Feed {
+	return &Feed{
+		catchUp: catchUp,
+		live:    live,
+		rng:     rng,
+	}
+}
+
+// IsStarted implements feeds.BlockFeed.
+func (f *Feed) IsStarted() bool {
+	return f.catchUp.IsStarted() || f.live.IsStarted()
+}
+
+// Start starts catching up.
+func (f *Feed) Start() {
+	log.WithFields(log.Fields{
+		"from": f.rng.From,
+		"to":   f.rng.To,
+	}).Info("catching up with the missed blocks")
+	f.catchUp.StartRange(int64(f.rng.From), int64(f.rng.To), f.rng.RateMs)
+}
+
+// StartRange skips catching up and plays the range from the live feed.
+func (f *Feed) StartRange(start int64, end int64, rate int64) {
+	f.live.StartRange(start, end, rate)
+}
+
+// Subscribe implements feeds.BlockFeed. The handler is moved to the live feed after catching up.
+func (f *Feed) Subscribe(handler func(evt *domain.BlockEvent) error) <-chan error {
+	f.mu.Lock()
+	f.subscriptions++
+	f.mu.Unlock()
+
+	errCh := make(chan error)
+	catchUpErrCh := f.catchUp.Subscribe(handler)
+	go func() {
+		err := <-catchUpErrCh
+		if err != feeds.ErrEndBlockReached {
+			errCh <- err
+			return
+		}
+		liveErrCh := f.live.Subscribe(handler)
+		f.handlerCaughtUp()
+		errCh <- <-liveErrCh
+	}()
+	return errCh
+}
+
+// handlerCaughtUp starts the live feed after all handlers subscribe to it.
+func (f *Feed) handlerCaughtUp() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.caughtUp++
+	if f.caughtUp < f.subscriptions {
+		return
+	}
+	log.WithField("to", f.rng.To).Info("caught up with the missed blocks - following the chain")
+	f.inLive = true
+	f.live.Start()
+}
+
+// Name returns the name of this implementation.
+func (f *Feed) Name() string {
+	return "block-feed"
+}
+
+// Health implements the health.Reporter interface.
+func (f *Feed) Health() health.Reports {
+	f.mu.Lock()
+	inLive := f.inLive
+	f.mu.Unlock()
+
+	catchUpReport := &health.Report{
+		Name:    "catch-up",
+		Status:  health.StatusInfo,
+		Details: fmt.Sprintf("catching up with blocks %d-%d", f.rng.From, f.rng.To),
+	}
+	reports := f.catchUp.Health()
+	if inLive {
+		catchUpReport.Details = fmt.Sprintf("caught up with blocks %d-%d", f.rng.From, f.rng.To)
+		reports = f.live.Health()
+	}
+	return append(reports, catchUpReport)
+}
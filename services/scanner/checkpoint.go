@@ -0,0 +1,160 @@
+package scanner
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/utils"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/forta-network/forta-node/store"
+)
+
+// blocks which fall this far behind the latest block are not waited for anymore,
+// e.g. the tx feed drops the transactions from the blocks that are too old
+const checkpointMaxPendingBlocks = 10
+
+type pendingBlock struct {
+	hash      string
+	started   bool
+	remaining int
+}
+
+func (block *pendingBlock) isDone() bool {
+	return block.started && block.remaining <= 0
+}
+
+// BlockCheckpointer keeps track of the transactions that are dispatched from each block
+// and writes the last block that was fully dispatched to the checkpoint file.
+type BlockCheckpointer struct {
+	filePath string
+	pending  map[uint64]*pendingBlock
+	latest   uint64
+	last     *store.Checkpoint
+
+	lastCheckpoint health.MessageTracker
+	lastErr        health.ErrorTracker
+	mu             sync.Mutex
+}
+
+// NewBlockCheckpointer creates a new checkpointer.
+func NewBlockCheckpointer(filePath string) *BlockCheckpointer {
+	return &BlockCheckpointer{
+		filePath: filePath,
+		pending:  make(map[uint64]*pendingBlock),
+	}
+}
+
+// BlockStarted marks the block as dispatched.
+func (bc *BlockCheckpointer) BlockStarted(evt *domain.BlockEvent) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	block, ok := bc.getPending(evt)
+	if !ok {
+		return
+	}
+	block.started = true
+	bc.advance()
+}
+
+// TxDispatched counts the transaction as dispatched. The transactions can be dispatched
+// before the block because the tx feed streams them concurrently.
+func (bc *BlockCheckpointer) TxDispatched(evt *domain.TransactionEvent) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	block, ok := bc.getPending(evt.BlockEvt)
+	if !ok {
+		return
+	}
+	block.remaining--
+	bc.advance()
+}
+
+func (bc *BlockCheckpointer) getPending(evt *domain.BlockEvent) (*pendingBlock, bool) {
+	if evt == nil || evt.Block == nil {
+		return nil, false
+	}
+	blockNum, err := utils.HexToBigInt(evt.Block.Number)
+	if err != nil {
+		return nil, false
+	}
+	number := blockNum.Uint64()
+	if bc.last != nil && number <= bc.last.BlockNumber {
+		return nil, false
+	}
+	if number > bc.latest {
+		bc.latest = number
+	}
+	block, ok := bc.pending[number]
+	if !ok {
+		block = &pendingBlock{
+			hash:      evt.Block.Hash,
+			remaining: len(evt.Block.Transactions),
+		}
+		bc.pending[number] = block
+	}
+	return block, true
+}
+
+// advance checkpoints the highest block which was fully dispatched after all of the previous blocks.
+func (bc *BlockCheckpointer) advance() {
+	var (
+		oldestPending uint64
+		hasPending    bool
+	)
+	for number, block := range bc.pending {
+		if !block.isDone() && number+checkpointMaxPendingBlocks < bc.latest {
+			log.WithField("block", number).Warn("not waiting for the transactions from block anymore")
+			delete(bc.pending, number)
+			continue
+		}
+		if !block.isDone() && (!hasPending || number < oldestPending) {
+			oldestPending = number
+			hasPending = true
+		}
+	}
+
+	var (
+		checkpoint   *store.Checkpoint
+		doneBlockNum uint64
+	)
+	for number, block := range bc.pending {
+		if !block.isDone() || (hasPending && number > oldestPending) {
+			continue
+		}
+		if number >= doneBlockNum {
+			doneBlockNum = number
+			checkpoint = &store.Checkpoint{BlockNumber: number, BlockHash: block.hash}
+		}
+		delete(bc.pending, number)
+	}
+	if checkpoint == nil || (bc.last != nil && checkpoint.BlockNumber <= bc.last.BlockNumber) {
+		return
+	}
+
+	checkpoint.UpdatedAt = time.Now().UTC()
+	err := store.WriteCheckpoint(bc.filePath, checkpoint)
+	bc.lastErr.Set(err)
+	if err != nil {
+		log.WithError(err).Error("failed to write checkpoint")
+		return
+	}
+	bc.last = checkpoint
+	bc.lastCheckpoint.Set(strconv.FormatUint(checkpoint.BlockNumber, 10))
+}
+
+// Name returns the name of this implementation.
+func (bc *BlockCheckpointer) Name() string {
+	return "checkpoint"
+}
+
+// Health implements the health.Reporter interface.
+func (bc *BlockCheckpointer) Health() health.Reports {
+	return health.Reports{
+		bc.lastCheckpoint.GetReport("block"),
+		bc.lastErr.GetReport("error"),
+	}
+}
@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"path"
+	"testing"
+
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forta-network/forta-node/store"
+)
+
+func testCheckpointBlock(number, hash string, txCount int) *domain.BlockEvent {
+	return &domain.BlockEvent{
+		Block: &domain.Block{
+			Number:       number,
+			Hash:         hash,
+			Transactions: make([]domain.Transaction, txCount),
+		},
+	}
+}
+
+func TestBlockCheckpointer(t *testing.T) {
+	r := require.New(t)
+
+	filePath := path.Join(t.TempDir(), "checkpoint.json")
+	bc := NewBlockCheckpointer(filePath)
+
+	block1 := testCheckpointBlock("0x1", "0xa", 1)
+	block2 := testCheckpointBlock("0x2", "0xb", 0)
+
+	// the tx from block 1 is not dispatched yet so block 2 cannot be checkpointed
+	bc.BlockStarted(block1)
+	bc.BlockStarted(block2)
+	checkpoint, err := store.ReadCheckpoint(filePath)
+	r.NoError(err)
+	r.Nil(checkpoint)
+
+	bc.TxDispatched(&domain.TransactionEvent{BlockEvt: block1})
+	checkpoint, err = store.ReadCheckpoint(filePath)
+	r.NoError(err)
+	r.NotNil(checkpoint)
+	r.Equal(uint64(2), checkpoint.BlockNumber)
+	r.Equal("0xb", checkpoint.BlockHash)
+}
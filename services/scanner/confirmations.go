@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/ethereum"
+	"github.com/forta-network/forta-core-go/utils"
+)
+
+const defaultTaggedBlockPollInterval = time.Second * 5
+
+// TaggedBlockClient makes the block feed follow the block with a tag like "finalized"
+// instead of the latest block: it does not return the blocks after the tagged block.
+type TaggedBlockClient struct {
+	ethereum.Client
+	rpcClient *rpc.Client
+	tag       string
+
+	// taggedHead is the last known tagged block number. The blocks up to it are
+	// returned without asking for the tagged block again.
+	taggedHead *big.Int
+	mu         sync.RWMutex
+
+	lastTaggedBlock health.MessageTracker
+	lastErr         health.ErrorTracker
+}
+
+// NewTaggedBlockClient creates a new tagged block client.
+func NewTaggedBlockClient(ctx context.Context, ethClient ethereum.Client, url, tag string) (*TaggedBlockClient, error) {
+	rpcClient, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial json-rpc api for the %s block: %v", tag, err)
+	}
+	return &TaggedBlockClient{
+		Client:    ethClient,
+		rpcClient: rpcClient,
+		tag:       tag,
+	}, nil
+}
+
+func (c *TaggedBlockClient) taggedBlockNumber(ctx context.Context) (*big.Int, error) {
+	var block *struct {
+		Number string `json:"number"`
+	}
+	err := c.rpcClient.CallContext(ctx, &block, "eth_getBlockByNumber", c.tag, false)
+	if err == nil && (block == nil || len(block.Number) == 0) {
+		err = errors.New("empty response")
+	}
+	var blockNum *big.Int
+	if err == nil {
+		blockNum, err = utils.HexToBigInt(block.Number)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to get the %s block: %v", c.tag, err)
+		c.lastErr.Set(err)
+		return nil, err
+	}
+	c.lastErr.Set(nil)
+	c.lastTaggedBlock.Set(blockNum.String())
+	c.mu.Lock()
+	c.taggedHead = blockNum
+	c.mu.Unlock()
+	return blockNum, nil
+}
+
+// isTagged tells if the block number is known to be tagged already.
+func (c *TaggedBlockClient) isTagged(number *big.Int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return number != nil && c.taggedHead != nil && number.Cmp(c.taggedHead) <= 0
+}
+
+// BlockNumber returns the tagged block number instead of the latest.
+func (c *TaggedBlockClient) BlockNumber(ctx context.Context) (*big.Int, error) {
+	return c.taggedBlockNumber(ctx)
+}
+
+// BlockByNumber waits until the block is tagged before returning it. The tagged block
+// is checked again only after the feed reaches the last known one.
+func (c *TaggedBlockClient) BlockByNumber(ctx context.Context, number *big.Int) (*domain.Block, error) {
+	if c.isTagged(number) {
+		return c.Client.BlockByNumber(ctx, number)
+	}
+	for {
+		taggedNum, err := c.taggedBlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if number == nil {
+			number = taggedNum
+		}
+		if number.Cmp(taggedNum) <= 0 {
+			return c.Client.BlockByNumber(ctx, number)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(defaultTaggedBlockPollInterval):
+		}
+	}
+}
+
+// Close closes the clients.
+func (c *TaggedBlockClient) Close() {
+	c.rpcClient.Close()
+	c.Client.Close()
+}
+
+// Name returns the name of this implementation.
+func (c *TaggedBlockClient) Name() string {
+	return fmt.Sprintf("%s-block-client", c.tag)
+}
+
+// Health implements the health.Reporter interface.
+func (c *TaggedBlockClient) Health() health.Reports {
+	return health.Reports{
+		c.lastTaggedBlock.GetReport("block"),
+		c.lastErr.GetReport("error"),
+	}
+}
@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forta-network/forta-core-go/domain"
+	mock_ethereum "github.com/forta-network/forta-core-go/ethereum/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaggedBlockClient(t *testing.T) {
+	r := require.New(t)
+
+	var taggedBlockCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&taggedBlockCalls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x5"}}`))
+	}))
+	defer server.Close()
+
+	ethClient := mock_ethereum.NewMockClient(gomock.NewController(t))
+	client, err := NewTaggedBlockClient(context.Background(), ethClient, server.URL, "finalized")
+	r.NoError(err)
+
+	blockNum, err := client.BlockNumber(context.Background())
+	r.NoError(err)
+	r.Equal(int64(5), blockNum.Int64())
+
+	ethClient.EXPECT().BlockByNumber(gomock.Any(), big.NewInt(3)).Return(&domain.Block{Number: "0x3"}, nil)
+	block, err := client.BlockByNumber(context.Background(), big.NewInt(3))
+	r.NoError(err)
+	r.Equal("0x3", block.Number)
+	// the tagged block is not checked again for the blocks before the last known one
+	r.Equal(int32(1), atomic.LoadInt32(&taggedBlockCalls))
+
+	// the blocks after the tagged block are not returned
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	_, err = client.BlockByNumber(ctx, big.NewInt(6))
+	r.Error(err)
+	r.Equal(int32(2), atomic.LoadInt32(&taggedBlockCalls))
+}
@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FindingValidator rejects the malformed findings and repairs the ones which exceed the limits,
+// before they become alerts.
+type FindingValidator struct {
+	cfg config.FindingsConfig
+}
+
+// NewFindingValidator creates a new finding validator.
+func NewFindingValidator(cfg config.FindingsConfig) *FindingValidator {
+	return &FindingValidator{cfg: cfg}
+}
+
+// ValidateFindings returns the valid and the repaired findings, and the metrics about the invalid
+// and the repaired ones.
+func (fv *FindingValidator) ValidateFindings(agentCfg config.AgentConfig, findings []*protocol.Finding) ([]*protocol.Finding, []*protocol.AgentMetric) {
+	var (
+		validFindings []*protocol.Finding
+		metricsList   []*protocol.AgentMetric
+	)
+	for _, f := range findings {
+		if f == nil {
+			continue
+		}
+		lg := log.WithFields(log.Fields{
+			"agent":   agentCfg.ID,
+			"alertId": f.AlertId,
+		})
+		if err := fv.validate(f); err != nil {
+			lg.WithError(err).Warn("rejected invalid finding")
+			metricsList = append(metricsList, metrics.CreateAgentMetric(agentCfg.ID, metrics.MetricFindingInvalid, 1))
+			continue
+		}
+		if repairs := fv.repair(f); len(repairs) > 0 {
+			lg.WithField("repairs", repairs).Warn("repaired finding")
+			metricsList = append(metricsList, metrics.CreateAgentMetric(agentCfg.ID, metrics.MetricFindingRepaired, 1))
+		}
+		validFindings = append(validFindings, f)
+	}
+	return validFindings, metricsList
+}
+
+// validate checks the finding fields which cannot be repaired.
+func (fv *FindingValidator) validate(f *protocol.Finding) error {
+	if len(f.Name) == 0 {
+		return errors.New("empty name")
+	}
+	if len(f.AlertId) == 0 {
+		return errors.New("empty alert id")
+	}
+	if _, ok := protocol.Finding_Severity_name[int32(f.Severity)]; !ok {
+		return fmt.Errorf("unknown severity %d", f.Severity)
+	}
+	if _, ok := protocol.Finding_FindingType_name[int32(f.Type)]; !ok {
+		return fmt.Errorf("unknown type %d", f.Type)
+	}
+	return nil
+}
+
+// repair fits the finding into the limits and returns what was repaired.
+func (fv *FindingValidator) repair(f *protocol.Finding) (repairs []string) {
+	if len(f.Name) > fv.cfg.MaxNameLength {
+		f.Name = truncateString(f.Name, fv.cfg.MaxNameLength)
+		repairs = append(repairs, "truncated name")
+	}
+	if len(f.Description) > fv.cfg.MaxDescriptionLength {
+		f.Description = truncateString(f.Description, fv.cfg.MaxDescriptionLength)
+		repairs = append(repairs, "truncated description")
+	}
+
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, address := range f.Addresses {
+		if !common.IsHexAddress(address) || seen[address] {
+			continue
+		}
+		seen[address] = true
+		addresses = append(addresses, address)
+	}
+	if len(addresses) != len(f.Addresses) {
+		repairs = append(repairs, "removed invalid or duplicate addresses")
+	}
+	if len(addresses) > fv.cfg.MaxAddresses {
+		addresses = addresses[:fv.cfg.MaxAddresses]
+		repairs = append(repairs, "removed addresses over the limit")
+	}
+	f.Addresses = addresses
+
+	// keep the metadata in the key order so the result is always the same
+	keys := make([]string, 0, len(f.Metadata))
+	for key := range f.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var size int
+	for i, key := range keys {
+		size += len(key) + len(f.Metadata[key])
+		if i >= fv.cfg.MaxMetadataKeys || size > fv.cfg.MaxMetadataSize {
+			delete(f.Metadata, key)
+		}
+	}
+	if len(f.Metadata) != len(keys) {
+		repairs = append(repairs, "removed metadata over the limit")
+	}
+	return
+}
+
+// truncateString truncates the string without breaking the last character.
+func truncateString(s string, maxLen int) string {
+	s = s[:maxLen]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/metrics"
+)
+
+func TestFindingValidator(t *testing.T) {
+	r := require.New(t)
+
+	fv := NewFindingValidator(config.FindingsConfig{
+		MaxNameLength:        5,
+		MaxDescriptionLength: 10,
+		MaxAddresses:         1,
+		MaxMetadataKeys:      1,
+		MaxMetadataSize:      100,
+	})
+
+	findings, findingMetrics := fv.ValidateFindings(config.AgentConfig{ID: "0x1"}, []*protocol.Finding{
+		{Name: "valid", AlertId: "ALERT-1"},
+		{Name: "no alert id"},
+		{Name: "bad severity", AlertId: "ALERT-2", Severity: 100},
+		{
+			Name:        "repaired",
+			AlertId:     "ALERT-3",
+			Description: strings.Repeat("ü", 10),
+			Addresses: []string{
+				"not an address",
+				"0x0000000000000000000000000000000000000001",
+				"0x0000000000000000000000000000000000000001",
+				"0x0000000000000000000000000000000000000002",
+			},
+			Metadata: map[string]string{"a": "1", "b": "2"},
+		},
+	})
+	r.Len(findings, 2)
+	r.Len(findingMetrics, 3)
+	r.Equal(metrics.MetricFindingInvalid, findingMetrics[0].Name)
+	r.Equal(metrics.MetricFindingInvalid, findingMetrics[1].Name)
+	r.Equal(metrics.MetricFindingRepaired, findingMetrics[2].Name)
+
+	repaired := findings[1]
+	r.Equal("repai", repaired.Name)
+	r.Equal(strings.Repeat("ü", 5), repaired.Description)
+	r.Equal([]string{"0x0000000000000000000000000000000000000001"}, repaired.Addresses)
+	r.Equal(map[string]string{"a": "1"}, repaired.Metadata)
+}
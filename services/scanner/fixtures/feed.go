@@ -0,0 +1,200 @@
+package fixtures
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/feeds"
+	"github.com/forta-network/forta-core-go/utils"
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+)
+
+type feedHandler struct {
+	Handler func(evt *domain.BlockEvent) error
+	ErrCh   chan<- error
+}
+
+// ReplayFeedConfig configures the replay feed.
+type ReplayFeedConfig struct {
+	// WithTiming waits between the blocks as long as it was waited while recording.
+	// Otherwise, the blocks are played as fast as possible.
+	WithTiming bool
+}
+
+// ReplayFeed is a block feed which plays the blocks back from a fixtures file.
+type ReplayFeed struct {
+	ctx      context.Context
+	cfg      ReplayFeedConfig
+	filePath string
+	start    *big.Int
+	end      *big.Int
+	rate     time.Duration
+	started  bool
+
+	lastBlock health.MessageTracker
+
+	handlers   []feedHandler
+	handlersMu sync.RWMutex
+}
+
+// NewReplayFeed creates a new replay feed from the fixtures file.
+func NewReplayFeed(ctx context.Context, filePath string, cfg ReplayFeedConfig) (*ReplayFeed, error) {
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("failed to find fixtures file: %v", err)
+	}
+	return &ReplayFeed{
+		ctx:      ctx,
+		cfg:      cfg,
+		filePath: filePath,
+	}, nil
+}
+
+// IsStarted implements feeds.BlockFeed.
+func (rf *ReplayFeed) IsStarted() bool {
+	return rf.started
+}
+
+// Start plays all of the blocks in the file.
+func (rf *ReplayFeed) Start() {
+	if !rf.started {
+		go rf.loop()
+	}
+}
+
+// StartRange plays the blocks from the file which are in the range.
+func (rf *ReplayFeed) StartRange(start int64, end int64, rate int64) {
+	if !rf.started {
+		if rate > 0 {
+			rf.rate = time.Duration(rate) * time.Millisecond
+		}
+		rf.start = big.NewInt(start)
+		rf.end = big.NewInt(end)
+		go rf.loop()
+	}
+}
+
+// Subscribe implements feeds.BlockFeed.
+func (rf *ReplayFeed) Subscribe(handler func(evt *domain.BlockEvent) error) <-chan error {
+	rf.handlersMu.Lock()
+	defer rf.handlersMu.Unlock()
+
+	errCh := make(chan error)
+	rf.handlers = append(rf.handlers, feedHandler{
+		Handler: handler,
+		ErrCh:   errCh,
+	})
+	return errCh
+}
+
+func (rf *ReplayFeed) loop() {
+	rf.started = true
+	defer func() {
+		rf.started = false
+	}()
+	err := rf.play()
+	if err == nil {
+		err = feeds.ErrEndBlockReached
+	} else {
+		log.WithError(err).Warn("failed while replaying blocks")
+	}
+	rf.handlersMu.RLock()
+	handlers := rf.handlers
+	rf.handlersMu.RUnlock()
+	for _, handler := range handlers {
+		handler.ErrCh <- err
+	}
+}
+
+func (rf *ReplayFeed) play() error {
+	file, err := os.Open(rf.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open fixtures file: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read fixtures file: %v", err)
+	}
+	defer gz.Close()
+
+	decoder := json.NewDecoder(gz)
+	var lastRecordedAt time.Time
+	for {
+		var record Record
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode fixture record: %v", err)
+		}
+		// the tx events are derived from the blocks by the tx feed
+		if record.Type != RecordTypeBlock || record.Block == nil || record.Block.Block == nil {
+			continue
+		}
+
+		evt := record.Block
+		blockNum, err := utils.HexToBigInt(evt.Block.Number)
+		if err != nil {
+			return fmt.Errorf("invalid block number in fixtures: %v", err)
+		}
+		if rf.start != nil && blockNum.Cmp(rf.start) < 0 {
+			continue
+		}
+		if rf.end != nil && blockNum.Cmp(rf.end) > 0 {
+			return nil
+		}
+
+		var delay time.Duration
+		switch {
+		case rf.cfg.WithTiming && !lastRecordedAt.IsZero():
+			delay = record.RecordedAt.Sub(lastRecordedAt)
+		case rf.rate > 0:
+			delay = rf.rate
+		}
+		lastRecordedAt = record.RecordedAt
+		if delay > 0 {
+			select {
+			case <-rf.ctx.Done():
+				return rf.ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		if evt.Timestamps == nil {
+			evt.Timestamps = &domain.TrackingTimestamps{}
+		}
+		evt.Timestamps.Feed = time.Now().UTC()
+		rf.lastBlock.Set(blockNum.String())
+
+		rf.handlersMu.RLock()
+		handlers := rf.handlers
+		rf.handlersMu.RUnlock()
+		for _, handler := range handlers {
+			if err := handler.Handler(evt); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Name returns the name of this implementation.
+func (rf *ReplayFeed) Name() string {
+	return "block-feed"
+}
+
+// Health implements the health.Reporter interface.
+func (rf *ReplayFeed) Health() health.Reports {
+	return health.Reports{
+		rf.lastBlock.GetReport("last-block"),
+	}
+}
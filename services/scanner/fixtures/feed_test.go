@@ -0,0 +1,51 @@
+package fixtures
+
+import (
+	"context"
+	"math/big"
+	"path"
+	"testing"
+
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/feeds"
+	"github.com/stretchr/testify/require"
+)
+
+func testBlockEvent(number string) *domain.BlockEvent {
+	return &domain.BlockEvent{
+		EventType: domain.EventTypeBlock,
+		ChainID:   big.NewInt(1),
+		Block: &domain.Block{
+			Hash:   "0x" + number,
+			Number: number,
+		},
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	r := require.New(t)
+
+	filePath := path.Join(t.TempDir(), "events.jsonl.gz")
+	rec, err := NewRecorder(filePath)
+	r.NoError(err)
+	for _, number := range []string{"0x1", "0x2", "0x3"} {
+		blockEvt := testBlockEvent(number)
+		r.NoError(rec.RecordBlock(blockEvt))
+		r.NoError(rec.RecordTx(&domain.TransactionEvent{BlockEvt: blockEvt}))
+	}
+	r.NoError(rec.Close())
+
+	feed, err := NewReplayFeed(context.Background(), filePath, ReplayFeedConfig{})
+	r.NoError(err)
+
+	var played []string
+	errCh := feed.Subscribe(func(evt *domain.BlockEvent) error {
+		played = append(played, evt.Block.Number)
+		r.NotNil(evt.Timestamps)
+		return nil
+	})
+	feed.StartRange(2, 3, 0)
+
+	r.Equal(feeds.ErrEndBlockReached, <-errCh)
+	r.Equal([]string{"0x2", "0x3"}, played)
+}
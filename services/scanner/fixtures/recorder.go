@@ -0,0 +1,94 @@
+package fixtures
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/goccy/go-json"
+)
+
+// Record types
+const (
+	RecordTypeBlock = "block"
+	RecordTypeTx    = "tx"
+)
+
+// Record is a line in a fixtures file.
+type Record struct {
+	Type       string                   `json:"type"`
+	RecordedAt time.Time                `json:"recordedAt"`
+	Block      *domain.BlockEvent       `json:"block,omitempty"`
+	Tx         *domain.TransactionEvent `json:"tx,omitempty"`
+	// BlockHash refers to the block of the tx record so the block is not repeated.
+	BlockHash string `json:"blockHash,omitempty"`
+}
+
+// Recorder writes the block and the transaction events to a gzipped JSONL file.
+type Recorder struct {
+	file    *os.File
+	gz      *gzip.Writer
+	encoder *json.Encoder
+	mu      sync.Mutex
+}
+
+// NewRecorder creates a new recorder which writes to the given file.
+func NewRecorder(filePath string) (*Recorder, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fixtures file: %v", err)
+	}
+	gz := gzip.NewWriter(file)
+	return &Recorder{
+		file:    file,
+		gz:      gz,
+		encoder: json.NewEncoder(gz),
+	}, nil
+}
+
+// RecordBlock records the block event.
+func (rec *Recorder) RecordBlock(evt *domain.BlockEvent) error {
+	return rec.write(&Record{
+		Type:       RecordTypeBlock,
+		RecordedAt: time.Now().UTC(),
+		Block:      evt,
+	})
+}
+
+// RecordTx records the transaction event without its block.
+func (rec *Recorder) RecordTx(evt *domain.TransactionEvent) error {
+	txEvt := *evt
+	txEvt.BlockEvt = nil
+	var blockHash string
+	if evt.BlockEvt != nil && evt.BlockEvt.Block != nil {
+		blockHash = evt.BlockEvt.Block.Hash
+	}
+	return rec.write(&Record{
+		Type:       RecordTypeTx,
+		RecordedAt: time.Now().UTC(),
+		Tx:         &txEvt,
+		BlockHash:  blockHash,
+	})
+}
+
+func (rec *Recorder) write(record *Record) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := rec.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write fixture record: %v", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the file.
+func (rec *Recorder) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := rec.gz.Close(); err != nil {
+		return err
+	}
+	return rec.file.Close()
+}
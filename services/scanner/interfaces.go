@@ -12,6 +12,10 @@ type TxResult struct {
 	Request     *protocol.EvaluateTxRequest
 	Response    *protocol.EvaluateTxResponse
 	Timestamps  *domain.TrackingTimestamps
+	// Pending is set if the tx is from the mempool.
+	Pending bool
+	// SourceEvent is set if the agent evaluated an alert about the tx.
+	SourceEvent *AlertEvent
 }
 
 // BlockResult contains request and response data.
@@ -20,13 +24,30 @@ type BlockResult struct {
 	Request     *protocol.EvaluateBlockRequest
 	Response    *protocol.EvaluateBlockResponse
 	Timestamps  *domain.TrackingTimestamps
+	// SourceEvent is set if the agent evaluated an alert about the block.
+	SourceEvent *AlertEvent
+}
+
+// AlertEvent is an alert from an agent on this node, to be evaluated by the agents which subscribe to it.
+type AlertEvent struct {
+	Notification *protocol.NotifyRequest
+	// AgentChain contains the IDs of the agent which produced the alert and the agents of the source alerts.
+	// The alert is never sent to these agents so that the subscriptions cannot loop.
+	AgentChain []string
+}
+
+// Alert returns the alert in the event.
+func (evt *AlertEvent) Alert() *protocol.Alert {
+	return evt.Notification.SignedAlert.Alert
 }
 
 // AgentPool contains all of the agents which we can forward the block and tx requests
 // to and receive the results from.
 type AgentPool interface {
 	SendEvaluateTxRequest(req *protocol.EvaluateTxRequest)
+	SendEvaluatePendingTxRequest(req *protocol.EvaluateTxRequest)
 	TxResults() <-chan *TxResult
 	SendEvaluateBlockRequest(req *protocol.EvaluateBlockRequest)
 	BlockResults() <-chan *BlockResult
+	SendEvaluateAlertRequest(evt *AlertEvent)
 }
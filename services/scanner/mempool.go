@@ -0,0 +1,363 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/utils"
+	"github.com/forta-network/forta-node/config"
+	"golang.org/x/time/rate"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMempoolDedupSize         = 20000
+	defaultMempoolResubscribeDelay  = time.Second * 5
+	defaultMempoolTxFetchTimeout    = time.Second * 10
+	defaultMempoolPendingBufferSize = 1000
+	defaultMempoolFetchConcurrency  = 10
+)
+
+// MempoolFeed reads the pending transactions and emits the ones that are not mined yet.
+// The pending transactions refer to the next block number but they do not have a block hash.
+type MempoolFeed struct {
+	ctx       context.Context
+	cfg       config.MempoolConfig
+	rpcClient *rpc.Client
+	output    chan *domain.TransactionEvent
+	limiter   *rate.Limiter
+
+	chainID     *big.Int
+	latestBlock *domain.Block
+	seen        map[string]bool
+	seenOrder   []string
+
+	received  uint64
+	emitted   uint64
+	dropped   uint64
+	duplicate uint64
+
+	lastPendingTx health.TimeTracker
+	lastErr       health.ErrorTracker
+	mu            sync.Mutex
+}
+
+// NewMempoolFeed creates a new mempool feed.
+func NewMempoolFeed(ctx context.Context, cfg config.MempoolConfig, chainID int) (*MempoolFeed, error) {
+	rpcClient, err := rpc.DialContext(ctx, cfg.JsonRpc.Url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial json-rpc api for the pending transactions: %v", err)
+	}
+	for k, v := range cfg.JsonRpc.Headers {
+		rpcClient.SetHeader(k, v)
+	}
+	// the subscriptions need a websocket connection
+	if cfg.Mode == config.MempoolModeSubscribe && !isWebsocketURL(cfg.JsonRpc.Url) {
+		log.WithField("url", cfg.JsonRpc.Url).Warn("pending transaction subscriptions need a websocket url - polling instead")
+		cfg.Mode = config.MempoolModePoll
+	}
+	return &MempoolFeed{
+		ctx:       ctx,
+		cfg:       cfg,
+		rpcClient: rpcClient,
+		output:    make(chan *domain.TransactionEvent, defaultMempoolPendingBufferSize),
+		limiter:   rate.NewLimiter(rate.Limit(cfg.RateLimit), cfg.RateLimit),
+		chainID:   big.NewInt(int64(chainID)),
+		seen:      make(map[string]bool),
+	}, nil
+}
+
+func isWebsocketURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "ws" || u.Scheme == "wss")
+}
+
+// ReadOnlyPendingTxStream returns the pending transaction events.
+func (mf *MempoolFeed) ReadOnlyPendingTxStream() <-chan *domain.TransactionEvent {
+	return mf.output
+}
+
+// BlockMined updates the pending block number and makes the feed skip the mined transactions.
+func (mf *MempoolFeed) BlockMined(evt *domain.BlockEvent) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	mf.latestBlock = evt.Block
+	for _, tx := range evt.Block.Transactions {
+		mf.markSeenUnsafe(tx.Hash)
+	}
+}
+
+// markSeenUnsafe remembers the tx hash and tells if it was seen before.
+func (mf *MempoolFeed) markSeenUnsafe(txHash string) bool {
+	if mf.seen[txHash] {
+		return true
+	}
+	mf.seen[txHash] = true
+	mf.seenOrder = append(mf.seenOrder, txHash)
+	// forget the oldest ones
+	if len(mf.seenOrder) > defaultMempoolDedupSize {
+		delete(mf.seen, mf.seenOrder[0])
+		mf.seenOrder = mf.seenOrder[1:]
+	}
+	return false
+}
+
+// handlePendingTx emits the pending tx if it is new and the rate limit allows it.
+func (mf *MempoolFeed) handlePendingTx(tx *domain.Transaction) {
+	if !mf.checkNew(tx) {
+		return
+	}
+	if !mf.limiter.Allow() {
+		mf.countDropped()
+		return
+	}
+	mf.emit(tx)
+}
+
+// checkNew tells if the tx is neither mined nor seen before.
+func (mf *MempoolFeed) checkNew(tx *domain.Transaction) bool {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	mf.received++
+	// the mined transactions are sent to the agents with the blocks
+	if len(tx.BlockHash) > 0 || mf.markSeenUnsafe(tx.Hash) {
+		mf.duplicate++
+		return false
+	}
+	return true
+}
+
+func (mf *MempoolFeed) countDropped() {
+	mf.mu.Lock()
+	mf.dropped++
+	mf.mu.Unlock()
+}
+
+func (mf *MempoolFeed) emit(tx *domain.Transaction) {
+	mf.mu.Lock()
+	// the pending block number is not known until the first block
+	if mf.latestBlock == nil {
+		mf.dropped++
+		mf.mu.Unlock()
+		return
+	}
+	latestNum, err := utils.HexToBigInt(mf.latestBlock.Number)
+	if err != nil {
+		mf.mu.Unlock()
+		log.WithError(err).Error("invalid latest block number")
+		return
+	}
+	mf.mu.Unlock()
+
+	now := time.Now().UTC()
+	evt := &domain.TransactionEvent{
+		BlockEvt: &domain.BlockEvent{
+			EventType: domain.EventTypeBlock,
+			ChainID:   mf.chainID,
+			Block: &domain.Block{
+				Number:    utils.BigIntToHex(latestNum.Add(latestNum, big.NewInt(1))),
+				Timestamp: utils.BigIntToHex(big.NewInt(now.Unix())),
+			},
+		},
+		Transaction: tx,
+		Timestamps: &domain.TrackingTimestamps{
+			Block: now,
+			Feed:  now,
+		},
+	}
+	select {
+	case mf.output <- evt:
+		mf.mu.Lock()
+		mf.emitted++
+		mf.mu.Unlock()
+		mf.lastPendingTx.Set()
+	default: // the analyzer is behind so there is no point in queueing more
+		mf.countDropped()
+	}
+}
+
+// Start starts reading the pending transactions.
+func (mf *MempoolFeed) Start() error {
+	log.WithField("mode", mf.cfg.Mode).Infof("Starting %s", mf.Name())
+	switch mf.cfg.Mode {
+	case config.MempoolModePoll:
+		go mf.pollLoop()
+	default:
+		go mf.subscribeLoop()
+	}
+	return nil
+}
+
+func (mf *MempoolFeed) subscribeLoop() {
+	for {
+		err := mf.subscribe()
+		mf.lastErr.Set(err)
+		if err != nil {
+			log.WithError(err).Warn("pending transaction subscription failed")
+		}
+		select {
+		case <-mf.ctx.Done():
+			return
+		case <-time.After(defaultMempoolResubscribeDelay):
+		}
+	}
+}
+
+func (mf *MempoolFeed) subscribe() error {
+	txHashes := make(chan string)
+	sub, err := mf.rpcClient.EthSubscribe(mf.ctx, txHashes, "newPendingTransactions")
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to pending transactions: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	fetchSlots := make(chan struct{}, defaultMempoolFetchConcurrency)
+	for {
+		select {
+		case <-mf.ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case txHash := <-txHashes:
+			if mf.isSeen(txHash) {
+				continue
+			}
+			// the rate limit is checked before getting the tx so that the dropped ones cost no requests
+			if !mf.limiter.Allow() {
+				mf.countDropped()
+				continue
+			}
+			select {
+			case fetchSlots <- struct{}{}:
+			default: // too many requests in flight already
+				mf.countDropped()
+				continue
+			}
+			go func() {
+				defer func() { <-fetchSlots }()
+				mf.fetchPendingTx(txHash)
+			}()
+		}
+	}
+}
+
+// fetchPendingTx gets the pending tx by the hash and emits it.
+func (mf *MempoolFeed) fetchPendingTx(txHash string) {
+	tx, err := mf.getTransaction(txHash)
+	if err != nil {
+		mf.lastErr.Set(err)
+		return
+	}
+	// the tx can be dropped from the mempool already
+	if tx == nil {
+		return
+	}
+	if mf.checkNew(tx) {
+		mf.emit(tx)
+	}
+}
+
+func (mf *MempoolFeed) isSeen(txHash string) bool {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	return mf.seen[txHash]
+}
+
+func (mf *MempoolFeed) getTransaction(txHash string) (*domain.Transaction, error) {
+	ctx, cancel := context.WithTimeout(mf.ctx, defaultMempoolTxFetchTimeout)
+	defer cancel()
+	var tx *domain.Transaction
+	if err := mf.rpcClient.CallContext(ctx, &tx, "eth_getTransactionByHash", txHash); err != nil {
+		return nil, fmt.Errorf("failed to get pending transaction: %v", err)
+	}
+	return tx, nil
+}
+
+func (mf *MempoolFeed) pollLoop() {
+	ticker := time.NewTicker(time.Duration(mf.cfg.PollIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mf.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := mf.poll()
+		mf.lastErr.Set(err)
+		if err != nil {
+			log.WithError(err).Warn("failed to poll pending transactions")
+		}
+	}
+}
+
+func (mf *MempoolFeed) poll() error {
+	ctx, cancel := context.WithTimeout(mf.ctx, defaultMempoolTxFetchTimeout)
+	defer cancel()
+	var content struct {
+		Pending map[string]map[string]*domain.Transaction `json:"pending"`
+	}
+	if err := mf.rpcClient.CallContext(ctx, &content, "txpool_content"); err != nil {
+		return fmt.Errorf("failed to get txpool content: %v", err)
+	}
+	for _, txs := range content.Pending {
+		for _, tx := range txs {
+			if tx != nil {
+				mf.handlePendingTx(tx)
+			}
+		}
+	}
+	return nil
+}
+
+// Stop stops the feed.
+func (mf *MempoolFeed) Stop() error {
+	log.Infof("Stopping %s", mf.Name())
+	mf.rpcClient.Close()
+	return nil
+}
+
+// Name returns the name of this implementation.
+func (mf *MempoolFeed) Name() string {
+	return "mempool-feed"
+}
+
+// Health implements the health.Reporter interface.
+func (mf *MempoolFeed) Health() health.Reports {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return health.Reports{
+		mf.lastPendingTx.GetReport("event.pending.time"),
+		mf.lastErr.GetReport("error"),
+		&health.Report{
+			Name:    "pending.received",
+			Status:  health.StatusInfo,
+			Details: strconv.FormatUint(mf.received, 10),
+		},
+		&health.Report{
+			Name:    "pending.emitted",
+			Status:  health.StatusInfo,
+			Details: strconv.FormatUint(mf.emitted, 10),
+		},
+		&health.Report{
+			Name:    "pending.dropped",
+			Status:  health.StatusInfo,
+			Details: strconv.FormatUint(mf.dropped, 10),
+		},
+		&health.Report{
+			Name:    "pending.duplicate",
+			Status:  health.StatusInfo,
+			Details: strconv.FormatUint(mf.duplicate, 10),
+		},
+	}
+}
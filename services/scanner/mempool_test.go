@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forta-network/forta-node/config"
+)
+
+func TestMempoolFeed(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"pending":{"0xabc":{
+			"1":{"hash":"0x1","from":"0xabc","nonce":"0x1","blockHash":null},
+			"2":{"hash":"0x2","from":"0xabc","nonce":"0x2","blockHash":null}
+		}}}}`))
+	}))
+	defer server.Close()
+
+	mf, err := NewMempoolFeed(context.Background(), config.MempoolConfig{
+		Mode:      config.MempoolModePoll,
+		JsonRpc:   config.JsonRpcConfig{Url: server.URL},
+		RateLimit: 100,
+	}, 1)
+	r.NoError(err)
+
+	// tx 0x2 is already mined
+	mf.BlockMined(&domain.BlockEvent{
+		Block: &domain.Block{
+			Number:       "0x10",
+			Transactions: []domain.Transaction{{Hash: "0x2"}},
+		},
+	})
+
+	r.NoError(mf.poll())
+	r.Len(mf.output, 1)
+	evt := <-mf.output
+	r.Equal("0x1", evt.Transaction.Hash)
+	r.Equal("0x11", evt.BlockEvt.Block.Number)
+	r.Empty(evt.BlockEvt.Block.Hash)
+
+	// the same txs are not emitted again
+	r.NoError(mf.poll())
+	r.Len(mf.output, 0)
+}
+
+type testPendingTxService struct {
+	txHashes []string
+}
+
+func (s *testPendingTxService) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	go func() {
+		for _, txHash := range s.txHashes {
+			notifier.Notify(sub.ID, txHash)
+		}
+	}()
+	return sub, nil
+}
+
+func (s *testPendingTxService) GetTransactionByHash(txHash string) (map[string]interface{}, error) {
+	return map[string]interface{}{"hash": txHash, "from": "0xabc", "nonce": "0x1"}, nil
+}
+
+func TestMempoolFeed_Subscribe(t *testing.T) {
+	r := require.New(t)
+
+	rpcServer := rpc.NewServer()
+	r.NoError(rpcServer.RegisterName("eth", &testPendingTxService{txHashes: []string{"0x1", "0x2", "0x3"}}))
+	server := httptest.NewServer(rpcServer.WebsocketHandler([]string{"*"}))
+	defer server.Close()
+
+	// subscribing needs a websocket url
+	mf, err := NewMempoolFeed(context.Background(), config.MempoolConfig{
+		Mode:      config.MempoolModeSubscribe,
+		JsonRpc:   config.JsonRpcConfig{Url: server.URL},
+		RateLimit: 1,
+	}, 1)
+	r.NoError(err)
+	r.Equal(config.MempoolModePoll, mf.cfg.Mode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mf, err = NewMempoolFeed(ctx, config.MempoolConfig{
+		Mode:      config.MempoolModeSubscribe,
+		JsonRpc:   config.JsonRpcConfig{Url: "ws" + strings.TrimPrefix(server.URL, "http")},
+		RateLimit: 1,
+	}, 1)
+	r.NoError(err)
+	r.Equal(config.MempoolModeSubscribe, mf.cfg.Mode)
+	mf.BlockMined(&domain.BlockEvent{Block: &domain.Block{Number: "0x10"}})
+	go mf.subscribe()
+
+	// the rate limit allows only one of the txs and the others are not fetched
+	r.Eventually(func() bool {
+		mf.mu.Lock()
+		defer mf.mu.Unlock()
+		return mf.emitted == 1 && mf.dropped == 2
+	}, time.Second*5, time.Millisecond*10)
+	evt := <-mf.output
+	r.Equal("0x1", evt.Transaction.Hash)
+	r.Equal("0x11", evt.BlockEvt.Block.Number)
+	mf.mu.Lock()
+	r.Equal(uint64(1), mf.received)
+	mf.mu.Unlock()
+}
@@ -0,0 +1,229 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	eth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/forta-network/forta-core-go/clients/health"
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/forta-network/forta-core-go/ethereum"
+	"github.com/forta-network/forta-core-go/utils"
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/forta-network/forta-node/clients/messaging"
+)
+
+// Reorg contains the blocks which were replaced in the canonical chain.
+type Reorg struct {
+	Orphaned []messaging.OrphanedBlock
+	// Canonical contains the replacement blocks from the oldest to the newest.
+	Canonical []*domain.BlockEvent
+}
+
+// unresolvedBlock is where walking back stopped because the canonical block could not be fetched.
+type unresolvedBlock struct {
+	number       uint64
+	expectedHash string
+}
+
+// ReorgDetector keeps the recent block hashes and detects the reorgs by checking
+// if the parent hash of each new block matches the previous block.
+type ReorgDetector struct {
+	ctx         context.Context
+	ethClient   ethereum.Client
+	traceClient ethereum.Client
+	maxDepth    uint64
+	reevaluate  bool
+
+	hashes     map[uint64]string
+	unresolved []unresolvedBlock
+
+	lastReorg health.MessageTracker
+	lastErr   health.ErrorTracker
+	mu        sync.Mutex
+}
+
+// NewReorgDetector creates a new reorg detector. The canonical replacements of the orphaned
+// blocks are fetched only if they should be reevaluated. They are traced if there is a trace client.
+func NewReorgDetector(ctx context.Context, ethClient, traceClient ethereum.Client, maxDepth int, reevaluate bool) *ReorgDetector {
+	return &ReorgDetector{
+		ctx:         ctx,
+		ethClient:   ethClient,
+		traceClient: traceClient,
+		maxDepth:    uint64(maxDepth),
+		reevaluate:  reevaluate,
+		hashes:      make(map[uint64]string),
+	}
+}
+
+// Check checks the new block against the previous ones and returns the reorg if there is one.
+func (rd *ReorgDetector) Check(evt *domain.BlockEvent) (*Reorg, error) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	reorg, err := rd.check(evt)
+	rd.lastErr.Set(err)
+	if reorg != nil {
+		rd.lastReorg.Set(fmt.Sprintf("orphaned %d block(s) before block %s", len(reorg.Orphaned), evt.Block.Number))
+	}
+	return reorg, err
+}
+
+func (rd *ReorgDetector) check(evt *domain.BlockEvent) (*Reorg, error) {
+	blockNum, err := utils.HexToBigInt(evt.Block.Number)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block number: %v", err)
+	}
+	number := blockNum.Uint64()
+	defer rd.prune(number)
+
+	reorg := &Reorg{}
+	// the same block number can come again with a different hash
+	if prevHash, ok := rd.hashes[number]; ok && prevHash != evt.Block.Hash {
+		reorg.Orphaned = append(reorg.Orphaned, messaging.OrphanedBlock{Number: number, Hash: prevHash})
+	}
+	rd.hashes[number] = evt.Block.Hash
+
+	// walk back from the new block and continue the previous walks that failed
+	walks := append([]unresolvedBlock{{number: number - 1, expectedHash: evt.Block.ParentHash}}, rd.unresolved...)
+	rd.unresolved = nil
+	for _, walk := range walks {
+		if walkErr := rd.walkBack(reorg, number, walk, evt.ChainID); walkErr != nil {
+			err = walkErr
+		}
+	}
+	sort.Slice(reorg.Canonical, func(i, j int) bool {
+		return blockNumber(reorg.Canonical[i]) < blockNumber(reorg.Canonical[j])
+	})
+
+	if len(reorg.Orphaned) == 0 {
+		return nil, err
+	}
+	return reorg, err
+}
+
+// walkBack walks back until the parent hashes match the known blocks again. A known hash is
+// replaced only after getting the canonical block so the walk can continue from there later.
+func (rd *ReorgDetector) walkBack(reorg *Reorg, latest uint64, from unresolvedBlock, chainID *big.Int) (err error) {
+	expectedHash := from.expectedHash
+	n := from.number
+	defer func() {
+		if err != nil {
+			rd.unresolved = append(rd.unresolved, unresolvedBlock{number: n, expectedHash: expectedHash})
+		}
+	}()
+	for ; n > 0 && latest-n <= rd.maxDepth; n-- {
+		knownHash, ok := rd.hashes[n]
+		if !ok || knownHash == expectedHash {
+			return nil
+		}
+
+		canonical, err := rd.ethClient.BlockByHash(rd.ctx, expectedHash)
+		if err != nil {
+			return fmt.Errorf("failed to get canonical block %d: %v", n, err)
+		}
+		if rd.reevaluate {
+			canonicalEvt, err := rd.newBlockEvent(canonical, chainID)
+			if err != nil {
+				return err
+			}
+			reorg.Canonical = append(reorg.Canonical, canonicalEvt)
+		}
+		reorg.Orphaned = append(reorg.Orphaned, messaging.OrphanedBlock{Number: n, Hash: knownHash})
+		rd.hashes[n] = expectedHash
+		expectedHash = canonical.ParentHash
+	}
+	return nil
+}
+
+func blockNumber(evt *domain.BlockEvent) uint64 {
+	blockNum, err := utils.HexToBigInt(evt.Block.Number)
+	if err != nil {
+		return 0
+	}
+	return blockNum.Uint64()
+}
+
+// prune forgets the blocks which are deeper than the max depth.
+func (rd *ReorgDetector) prune(latest uint64) {
+	for n := range rd.hashes {
+		if n+rd.maxDepth < latest {
+			delete(rd.hashes, n)
+		}
+	}
+}
+
+// newBlockEvent creates the block event the same way the block feed does.
+func (rd *ReorgDetector) newBlockEvent(block *domain.Block, chainID *big.Int) (*domain.BlockEvent, error) {
+	var traces []domain.Trace
+	if rd.traceClient != nil {
+		traces = rd.traceBlock(block)
+	}
+	blockHash := common.HexToHash(block.Hash)
+	logs, err := rd.ethClient.GetLogs(rd.ctx, eth.FilterQuery{BlockHash: &blockHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs for canonical block: %v", err)
+	}
+	var logEntries []domain.LogEntry
+	b, err := json.Marshal(logs)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &logEntries); err != nil {
+		return nil, err
+	}
+	blockTs, err := block.GetTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get canonical block timestamp: %v", err)
+	}
+	return &domain.BlockEvent{
+		EventType: domain.EventTypeBlock,
+		Block:     block,
+		ChainID:   chainID,
+		Traces:    traces,
+		Logs:      logEntries,
+		Timestamps: &domain.TrackingTimestamps{
+			Block: *blockTs,
+			Feed:  time.Now().UTC(),
+		},
+	}, nil
+}
+
+// traceBlock traces the block by the number. The traces are ignored if the block
+// at the number is not the same block anymore, like the block feed does.
+func (rd *ReorgDetector) traceBlock(block *domain.Block) []domain.Trace {
+	blockNum, err := utils.HexToBigInt(block.Number)
+	if err != nil {
+		return nil
+	}
+	traces, err := rd.traceClient.TraceBlock(rd.ctx, blockNum)
+	if err != nil {
+		log.WithError(err).WithField("block", block.Number).Error("failed to trace canonical block")
+		return nil
+	}
+	if len(traces) > 0 && block.Hash != utils.String(traces[0].BlockHash) {
+		log.WithField("block", block.Number).Warn("trace block hash is different, ignoring canonical block traces")
+		return nil
+	}
+	return traces
+}
+
+// Name returns the name of this implementation.
+func (rd *ReorgDetector) Name() string {
+	return "reorg-detector"
+}
+
+// Health implements the health.Reporter interface.
+func (rd *ReorgDetector) Health() health.Reports {
+	return health.Reports{
+		rd.lastReorg.GetReport("last"),
+		rd.lastErr.GetReport("error"),
+	}
+}
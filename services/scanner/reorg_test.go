@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/forta-network/forta-core-go/domain"
+	mock_ethereum "github.com/forta-network/forta-core-go/ethereum/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forta-network/forta-node/clients/messaging"
+)
+
+func testReorgBlock(number, hash, parentHash string) *domain.BlockEvent {
+	return &domain.BlockEvent{
+		ChainID: big.NewInt(1),
+		Block: &domain.Block{
+			Number:     number,
+			Hash:       hash,
+			ParentHash: parentHash,
+			Timestamp:  "0x1",
+		},
+	}
+}
+
+func TestReorgDetector(t *testing.T) {
+	r := require.New(t)
+
+	ethClient := mock_ethereum.NewMockClient(gomock.NewController(t))
+	rd := NewReorgDetector(context.Background(), ethClient, nil, 10, true)
+
+	for _, evt := range []*domain.BlockEvent{
+		testReorgBlock("0x1", "0xa1", "0xa0"),
+		testReorgBlock("0x2", "0xa2", "0xa1"),
+		testReorgBlock("0x3", "0xa3", "0xa2"),
+	} {
+		reorg, err := rd.Check(evt)
+		r.NoError(err)
+		r.Nil(reorg)
+	}
+
+	// blocks 2 and 3 are replaced
+	ethClient.EXPECT().BlockByHash(gomock.Any(), "0xb3").Return(testReorgBlock("0x3", "0xb3", "0xb2").Block, nil)
+	ethClient.EXPECT().BlockByHash(gomock.Any(), "0xb2").Return(testReorgBlock("0x2", "0xb2", "0xa1").Block, nil)
+	ethClient.EXPECT().GetLogs(gomock.Any(), gomock.Any()).Return(nil, nil).Times(2)
+
+	reorg, err := rd.Check(testReorgBlock("0x4", "0xb4", "0xb3"))
+	r.NoError(err)
+	r.NotNil(reorg)
+	r.Equal([]messaging.OrphanedBlock{
+		{Number: 3, Hash: "0xa3"},
+		{Number: 2, Hash: "0xa2"},
+	}, reorg.Orphaned)
+	r.Len(reorg.Canonical, 2)
+	r.Equal("0xb2", reorg.Canonical[0].Block.Hash)
+	r.Equal("0xb3", reorg.Canonical[1].Block.Hash)
+
+	// the canonical chain continues normally
+	reorg, err = rd.Check(testReorgBlock("0x5", "0xb5", "0xb4"))
+	r.NoError(err)
+	r.Nil(reorg)
+}
+
+func TestReorgDetector_ContinuesAfterError(t *testing.T) {
+	r := require.New(t)
+
+	ethClient := mock_ethereum.NewMockClient(gomock.NewController(t))
+	rd := NewReorgDetector(context.Background(), ethClient, nil, 10, false)
+
+	for _, evt := range []*domain.BlockEvent{
+		testReorgBlock("0x1", "0xa1", "0xa0"),
+		testReorgBlock("0x2", "0xa2", "0xa1"),
+		testReorgBlock("0x3", "0xa3", "0xa2"),
+	} {
+		_, err := rd.Check(evt)
+		r.NoError(err)
+	}
+
+	// block 2 cannot be fetched while walking back
+	ethClient.EXPECT().BlockByHash(gomock.Any(), "0xb3").Return(testReorgBlock("0x3", "0xb3", "0xb2").Block, nil)
+	ethClient.EXPECT().BlockByHash(gomock.Any(), "0xb2").Return(nil, errors.New("unavailable"))
+	reorg, err := rd.Check(testReorgBlock("0x4", "0xb4", "0xb3"))
+	r.Error(err)
+	r.Equal([]messaging.OrphanedBlock{{Number: 3, Hash: "0xa3"}}, reorg.Orphaned)
+
+	// the next block continues from block 2
+	ethClient.EXPECT().BlockByHash(gomock.Any(), "0xb2").Return(testReorgBlock("0x2", "0xb2", "0xa1").Block, nil)
+	reorg, err = rd.Check(testReorgBlock("0x5", "0xb5", "0xb4"))
+	r.NoError(err)
+	r.Equal([]messaging.OrphanedBlock{{Number: 2, Hash: "0xa2"}}, reorg.Orphaned)
+
+	reorg, err = rd.Check(testReorgBlock("0x6", "0xb6", "0xb5"))
+	r.NoError(err)
+	r.Nil(reorg)
+}
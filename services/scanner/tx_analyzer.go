@@ -34,6 +34,10 @@ type TxAnalyzerServiceConfig struct {
 	AlertSender clients.AlertSender
 	AgentPool   AgentPool
 	MsgClient   clients.MessageClient
+	// PendingTxChannel is set if the pending transactions are enabled.
+	PendingTxChannel <-chan *domain.TransactionEvent
+	// FindingValidator is set to check the findings before they become alerts.
+	FindingValidator *FindingValidator
 }
 
 // WARNING, this must be deterministic (any maps must be converted to sorted lists)
@@ -53,11 +57,22 @@ func (t *TxAnalyzerService) calculateAlertID(result *TxResult, f *protocol.Findi
 		result.AgentConfig.ID,
 		strings.Join(addrs, ""),
 		strings.Join(f.Addresses, "")}, "")
+	// do not collide with the alert for the mined tx
+	if result.Pending {
+		idStr += "pending"
+	}
+	// the same combined finding can be based on different alerts
+	if result.SourceEvent != nil {
+		idStr += result.SourceEvent.Alert().Id
+	}
 	return crypto.Keccak256Hash([]byte(idStr)).Hex()
 }
 
 func (t *TxAnalyzerService) publishMetrics(result *TxResult) {
 	m := metrics.GetTxMetrics(result.AgentConfig, result.Response, result.Timestamps)
+	if result.Pending {
+		m = append(m, metrics.CreateAgentMetric(result.AgentConfig.ID, metrics.MetricTxPending, 1))
+	}
 	t.cfg.MsgClient.PublishProto(messaging.SubjectMetricAgent, &protocol.AgentMetricList{Metrics: m})
 }
 
@@ -82,9 +97,14 @@ func (t *TxAnalyzerService) findingToAlert(result *TxResult, ts time.Time, f *pr
 	if !f.Private && !result.Response.Private {
 		alertType = protocol.AlertType_TRANSACTION
 		tags["txHash"] = result.Request.Event.Transaction.Hash
-		tags["blockHash"] = result.Request.Event.Block.BlockHash
 		tags["blockNumber"] = blockNumber.String()
+		if result.Pending {
+			tags["pending"] = "true"
+		} else {
+			tags["blockHash"] = result.Request.Event.Block.BlockHash
+		}
 	}
+	addSourceAlertIDs(tags, f, result.SourceEvent)
 
 	return &protocol.Alert{
 		Id:         alertID,
@@ -104,6 +124,12 @@ func (t *TxAnalyzerService) Start() error {
 		for result := range t.cfg.AgentPool.TxResults() {
 			ts := time.Now().UTC()
 
+			if t.cfg.FindingValidator != nil {
+				findings, findingMetrics := t.cfg.FindingValidator.ValidateFindings(result.AgentConfig, result.Response.Findings)
+				result.Response.Findings = findings
+				metrics.SendAgentMetrics(t.cfg.MsgClient, findingMetrics)
+			}
+
 			rt := &clients.AgentRoundTrip{
 				AgentConfig:    result.AgentConfig,
 				EvalTxRequest:  result.Request,
@@ -118,7 +144,6 @@ func (t *TxAnalyzerService) Start() error {
 				}
 			}
 
-			//TODO: validate finding returned is well-formed
 			for _, f := range result.Response.Findings {
 				alert, err := t.findingToAlert(result, ts, f)
 				if err != nil {
@@ -130,6 +155,19 @@ func (t *TxAnalyzerService) Start() error {
 				); err != nil {
 					log.WithError(err).Panic("failed to sign alert and notify")
 				}
+				// let the subscribed agents evaluate the public alerts
+				if !result.Pending && alert.Type != protocol.AlertType_PRIVATE {
+					t.cfg.AgentPool.SendEvaluateAlertRequest(newAlertEvent(&protocol.NotifyRequest{
+						SignedAlert: &protocol.SignedAlert{
+							Alert:       alert,
+							ChainId:     result.Request.Event.Network.ChainId,
+							BlockNumber: result.Request.Event.Block.BlockNumber,
+						},
+						EvalTxRequest: result.Request,
+						AgentInfo:     alert.Agent,
+						Timestamps:    result.Timestamps.ToMessage(),
+					}, result.AgentConfig.ID, result.SourceEvent))
+				}
 			}
 			t.publishMetrics(result)
 
@@ -141,25 +179,43 @@ func (t *TxAnalyzerService) Start() error {
 	go func() {
 		// for each transaction
 		for tx := range t.cfg.TxChannel {
-			// convert to message
-			msg, err := tx.ToMessage()
-			if err != nil {
-				log.WithError(err).Error("error converting tx event to message (skipping)")
-				continue
+			t.sendTx(tx, false)
+		}
+	}()
+
+	if t.cfg.PendingTxChannel != nil {
+		go func() {
+			for tx := range t.cfg.PendingTxChannel {
+				t.sendTx(tx, true)
 			}
+		}()
+	}
 
-			// create a request
-			requestId := uuid.Must(uuid.NewUUID())
-			request := &protocol.EvaluateTxRequest{RequestId: requestId.String(), Event: msg}
+	return nil
+}
 
-			// forward to the pool
-			t.cfg.AgentPool.SendEvaluateTxRequest(request)
+func (t *TxAnalyzerService) sendTx(tx *domain.TransactionEvent, pending bool) {
+	// convert to message
+	msg, err := tx.ToMessage()
+	if err != nil {
+		log.WithError(err).Error("error converting tx event to message (skipping)")
+		return
+	}
 
-			t.lastInputActivity.Set()
-		}
-	}()
+	// create a request
+	requestId := uuid.Must(uuid.NewUUID())
+	request := &protocol.EvaluateTxRequest{RequestId: requestId.String(), Event: msg}
+
+	// forward to the pool
+	if pending {
+		// the receipt of a pending tx is not known yet
+		msg.Receipt = &protocol.TransactionEvent_EthReceipt{TransactionHash: msg.Transaction.Hash}
+		t.cfg.AgentPool.SendEvaluatePendingTxRequest(request)
+	} else {
+		t.cfg.AgentPool.SendEvaluateTxRequest(request)
+	}
 
-	return nil
+	t.lastInputActivity.Set()
 }
 
 func (t *TxAnalyzerService) Stop() error {
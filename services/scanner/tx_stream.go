@@ -2,12 +2,16 @@ package scanner
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/forta-network/forta-core-go/clients/health"
 	"github.com/forta-network/forta-core-go/domain"
 	"github.com/forta-network/forta-core-go/ethereum"
 	"github.com/forta-network/forta-core-go/feeds"
+	"github.com/forta-network/forta-core-go/utils"
+	"github.com/forta-network/forta-node/clients"
+	"github.com/forta-network/forta-node/clients/messaging"
 	"github.com/forta-network/forta-node/config"
 
 	log "github.com/sirupsen/logrus"
@@ -20,6 +24,7 @@ type TxStreamService struct {
 	blockOutput chan *domain.BlockEvent
 	txOutput    chan *domain.TransactionEvent
 	txFeed      feeds.TransactionFeed
+	done        chan struct{}
 
 	lastBlockActivity health.TimeTracker
 	lastTxActivity    health.TimeTracker
@@ -29,6 +34,27 @@ type TxStreamServiceConfig struct {
 	JsonRpcConfig       config.JsonRpcConfig
 	TraceJsonRpcConfig  config.JsonRpcConfig
 	SkipBlocksOlderThan *time.Duration
+	Recorder            EventRecorder
+	Throttler           Throttler
+	Checkpointer        *BlockCheckpointer
+	ReorgDetector       *ReorgDetector
+	MsgClient           clients.MessageClient
+	Confirmations       config.ConfirmationsConfig
+	Mempool             *MempoolFeed
+	// CatchUpTo is the last block of the catch-up range. The transactions from the blocks up to
+	// this one are not skipped for being too old.
+	CatchUpTo uint64
+}
+
+// Throttler holds back the stream while the consumers are lagging.
+type Throttler interface {
+	WaitIfThrottled(ctx context.Context)
+}
+
+// EventRecorder records the events streamed to the analyzers.
+type EventRecorder interface {
+	RecordBlock(evt *domain.BlockEvent) error
+	RecordTx(evt *domain.TransactionEvent) error
 }
 
 func (t *TxStreamService) ReadOnlyBlockStream() <-chan *domain.BlockEvent {
@@ -39,15 +65,116 @@ func (t *TxStreamService) ReadOnlyTxStream() <-chan *domain.TransactionEvent {
 	return t.txOutput
 }
 
+// Checkpointer returns the block checkpointer if checkpointing is enabled.
+func (t *TxStreamService) Checkpointer() *BlockCheckpointer {
+	return t.cfg.Checkpointer
+}
+
+// Mempool returns the mempool feed if the pending transactions are enabled.
+func (t *TxStreamService) Mempool() *MempoolFeed {
+	return t.cfg.Mempool
+}
+
+// ReorgDetector returns the reorg detector if reorg detection is enabled.
+func (t *TxStreamService) ReorgDetector() *ReorgDetector {
+	return t.cfg.ReorgDetector
+}
+
 func (t *TxStreamService) handleBlock(evt *domain.BlockEvent) error {
+	// blocking here slows down the block feed
+	if t.cfg.Throttler != nil {
+		t.cfg.Throttler.WaitIfThrottled(t.ctx)
+	}
+	if t.cfg.ReorgDetector != nil {
+		t.handleReorg(evt)
+	}
+	if t.cfg.Mempool != nil {
+		t.cfg.Mempool.BlockMined(evt)
+	}
+	t.streamBlock(evt)
+	return nil
+}
+
+// streamBlock records the block and sends it to the analyzers.
+func (t *TxStreamService) streamBlock(evt *domain.BlockEvent) {
+	if t.cfg.Recorder != nil {
+		if err := t.cfg.Recorder.RecordBlock(evt); err != nil {
+			log.WithError(err).Error("failed to record block")
+		}
+	}
 	t.blockOutput <- evt
 	t.lastBlockActivity.Set()
-	return nil
+	if t.cfg.Checkpointer != nil {
+		t.cfg.Checkpointer.BlockStarted(evt)
+	}
+}
+
+// handleReorg notifies about the blocks orphaned before the new block
+// and streams their canonical replacements first.
+func (t *TxStreamService) handleReorg(evt *domain.BlockEvent) {
+	reorg, err := t.cfg.ReorgDetector.Check(evt)
+	if err != nil {
+		log.WithError(err).Error("failed to check for chain reorg")
+	}
+	if reorg == nil {
+		return
+	}
+	log.WithFields(log.Fields{
+		"block":     evt.Block.Number,
+		"orphaned":  len(reorg.Orphaned),
+		"canonical": len(reorg.Canonical),
+	}).Warn("detected chain reorg")
+	if t.cfg.MsgClient != nil {
+		t.cfg.MsgClient.Publish(messaging.SubjectScannerReorg, messaging.ReorgPayload{OrphanedBlocks: reorg.Orphaned})
+	}
+	// the canonical blocks go through the same path as the blocks from the feed
+	for _, canonicalEvt := range reorg.Canonical {
+		t.streamBlock(canonicalEvt)
+		for i := range canonicalEvt.Block.Transactions {
+			t.handleTx(&domain.TransactionEvent{
+				BlockEvt:    canonicalEvt,
+				Transaction: &canonicalEvt.Block.Transactions[i],
+				Timestamps: &domain.TrackingTimestamps{
+					Block: canonicalEvt.Timestamps.Block,
+					Feed:  time.Now().UTC(),
+				},
+			})
+		}
+	}
+}
+
+// isTooOld tells if a block after the catch-up range is too old to analyze its transactions.
+func (t *TxStreamService) isTooOld(block *domain.Block) bool {
+	if t.cfg.CatchUpTo == 0 || t.cfg.SkipBlocksOlderThan == nil {
+		return false
+	}
+	blockNum, err := utils.HexToBigInt(block.Number)
+	if err != nil || blockNum.Uint64() <= t.cfg.CatchUpTo {
+		return false
+	}
+	age, err := block.Age()
+	return err == nil && age != nil && *age > *t.cfg.SkipBlocksOlderThan
 }
 
 func (t *TxStreamService) handleTx(evt *domain.TransactionEvent) error {
+	// the tx feed does not skip anything while catching up so the old blocks are skipped here
+	if t.isTooOld(evt.BlockEvt.Block) {
+		log.WithFields(log.Fields{
+			"block": evt.BlockEvt.Block.Number,
+			"tx":    evt.Transaction.Hash,
+		}).Warn("dropping tx from a block that is too old")
+		return nil
+	}
+	if t.cfg.Recorder != nil {
+		if err := t.cfg.Recorder.RecordTx(evt); err != nil {
+			log.WithError(err).Error("failed to record tx")
+		}
+	}
 	t.txOutput <- evt
 	t.lastTxActivity.Set()
+	if t.cfg.Checkpointer != nil {
+		t.cfg.Checkpointer.TxDispatched(evt)
+	}
 	return nil
 }
 
@@ -57,10 +184,18 @@ func (t *TxStreamService) Start() error {
 		if err := t.txFeed.ForEachTransaction(t.handleBlock, t.handleTx); err != nil {
 			log.WithError(err).Panic("tx feed error")
 		}
+		// only block ranges come to an end
+		log.Info("tx feed reached the end block")
+		close(t.done)
 	}()
 	return nil
 }
 
+// Done is closed after the last block of the range is streamed.
+func (t *TxStreamService) Done() <-chan struct{} {
+	return t.done
+}
+
 func (t *TxStreamService) Stop() error {
 	log.Infof("Stopping %s", t.Name())
 	if t.txOutput != nil {
@@ -69,6 +204,9 @@ func (t *TxStreamService) Stop() error {
 	if t.blockOutput != nil {
 		close(t.blockOutput)
 	}
+	if closer, ok := t.cfg.Recorder.(io.Closer); ok {
+		return closer.Close()
+	}
 	return nil
 }
 
@@ -81,6 +219,11 @@ func (t *TxStreamService) Health() health.Reports {
 	return health.Reports{
 		t.lastBlockActivity.GetReport("event.block.time"),
 		t.lastTxActivity.GetReport("event.transaction.time"),
+		&health.Report{
+			Name:    "confirmations",
+			Status:  health.StatusInfo,
+			Details: t.cfg.Confirmations.String(),
+		},
 	}
 }
 
@@ -88,7 +231,11 @@ func NewTxStreamService(ctx context.Context, ethClient ethereum.Client, blockFee
 	txOutput := make(chan *domain.TransactionEvent)
 	blockOutput := make(chan *domain.BlockEvent)
 
-	txFeed, err := feeds.NewTransactionFeed(ctx, ethClient, blockFeed, cfg.SkipBlocksOlderThan, 10)
+	skipBlocksOlderThan := cfg.SkipBlocksOlderThan
+	if cfg.CatchUpTo > 0 {
+		skipBlocksOlderThan = nil
+	}
+	txFeed, err := feeds.NewTransactionFeed(ctx, ethClient, blockFeed, skipBlocksOlderThan, 10)
 	if err != nil {
 		return nil, err
 	}
@@ -99,5 +246,6 @@ func NewTxStreamService(ctx context.Context, ethClient ethereum.Client, blockFee
 		blockOutput: blockOutput,
 		txOutput:    txOutput,
 		txFeed:      txFeed,
+		done:        make(chan struct{}),
 	}, nil
 }
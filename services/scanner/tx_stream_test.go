@@ -0,0 +1,24 @@
+package scanner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/forta-network/forta-core-go/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxStreamService_IsTooOld(t *testing.T) {
+	r := require.New(t)
+
+	maxAge := time.Minute * 10
+	ts := &TxStreamService{cfg: TxStreamServiceConfig{SkipBlocksOlderThan: &maxAge, CatchUpTo: 100}}
+	oldTimestamp := fmt.Sprintf("0x%x", time.Now().Add(-time.Hour).Unix())
+	newTimestamp := fmt.Sprintf("0x%x", time.Now().Unix())
+
+	// the old blocks are not skipped while catching up but they are after that
+	r.False(ts.isTooOld(&domain.Block{Number: "0x64", Timestamp: oldTimestamp}))
+	r.True(ts.isTooOld(&domain.Block{Number: "0x65", Timestamp: oldTimestamp}))
+	r.False(ts.isTooOld(&domain.Block{Number: "0x65", Timestamp: newTimestamp}))
+}
@@ -0,0 +1,117 @@
+package supervisor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/forta-network/forta-node/clients/messaging"
+	"github.com/forta-network/forta-node/config"
+	"github.com/forta-network/forta-node/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultAgentStatsInterval = time.Second * 30
+
+func (sup *SupervisorService) syncAgentStats() {
+	ticker := time.NewTicker(defaultAgentStatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sup.ctx.Done():
+			return
+		case <-ticker.C:
+			sup.doSyncAgentStats()
+			sup.lastAgentStats.Set()
+		}
+	}
+}
+
+type agentStats struct {
+	containerID   string
+	containerName string
+	agentConfig   config.AgentConfig
+	stats         *types.StatsJSON
+}
+
+func (sup *SupervisorService) doSyncAgentStats() {
+	// copy the agent containers as they can be recreated while sampling
+	sup.mu.RLock()
+	var samples []*agentStats
+	for _, container := range sup.containers {
+		if container.IsAgent {
+			samples = append(samples, &agentStats{
+				containerID:   container.ID,
+				containerName: container.Name,
+				agentConfig:   *container.AgentConfig,
+			})
+		}
+	}
+	sup.mu.RUnlock()
+
+	// sampling takes about a second for each container so do it concurrently
+	var wg sync.WaitGroup
+	for _, sample := range samples {
+		wg.Add(1)
+		go func(sample *agentStats) {
+			defer wg.Done()
+			stats, err := sup.client.GetContainerStats(sup.ctx, sample.containerID)
+			if err != nil {
+				log.WithError(err).WithField("container", sample.containerName).Warn("failed to get agent container stats")
+				return
+			}
+			sample.stats = stats
+		}(sample)
+	}
+	wg.Wait()
+
+	limits := config.GetAgentResourceLimits(sup.config.Config.ResourcesConfig)
+	prevStats := make(map[string]*types.StatsJSON)
+	chainMetrics := make(map[int][]*protocol.AgentMetric)
+	for _, sample := range samples {
+		if sample.stats == nil {
+			continue
+		}
+		agentCfg := sample.agentConfig
+		ms := metrics.GetContainerMetrics(agentCfg.ID, sample.stats, sup.prevAgentStats[sample.containerID], limits)
+		chainMetrics[agentCfg.ChainID] = append(chainMetrics[agentCfg.ChainID], ms...)
+		prevStats[sample.containerID] = sample.stats
+	}
+	// the stopped containers are forgotten
+	sup.prevAgentStats = prevStats
+
+	for chainID, ms := range chainMetrics {
+		sup.publishAgentMetrics(chainID, ms)
+	}
+}
+
+// handleAgentOOM reports the agent container which was killed for exceeding the memory limit.
+func (sup *SupervisorService) handleAgentOOM(containerID string) {
+	sup.mu.RLock()
+	var agentCfg *config.AgentConfig
+	for _, container := range sup.containers {
+		if container.IsAgent && container.ID == containerID {
+			agentCfg = container.AgentConfig
+			break
+		}
+	}
+	sup.mu.RUnlock()
+	if agentCfg == nil {
+		return
+	}
+
+	log.WithField("agent", agentCfg.ID).Warn("agent container ran out of memory")
+	sup.publishAgentMetrics(agentCfg.ChainID, []*protocol.AgentMetric{
+		metrics.CreateAgentMetric(agentCfg.ID, metrics.MetricContainerOOM, 1),
+	})
+}
+
+func (sup *SupervisorService) publishAgentMetrics(chainID int, ms []*protocol.AgentMetric) {
+	if len(ms) == 0 {
+		return
+	}
+	sup.msgClient.PublishProto(messaging.ChainSubject(messaging.SubjectMetricAgent, chainID), &protocol.AgentMetricList{
+		Metrics: ms,
+	})
+}
@@ -5,11 +5,13 @@ import (
 
 	"github.com/forta-network/forta-core-go/utils"
 	"github.com/forta-network/forta-node/clients"
+	"github.com/forta-network/forta-node/clients/messaging"
 
 	"fmt"
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -25,59 +27,172 @@ func (sup *SupervisorService) healthCheck() {
 			return
 
 		case <-ticker.C:
-			if err := sup.doHealthCheck(); err != nil {
-				log.Errorf("failed to do health check: %v", err)
-			}
+		case <-sup.healthCheckTrigger:
+		}
+		if err := sup.doHealthCheck(); err != nil {
+			log.Errorf("failed to do health check: %v", err)
 		}
 	}
 }
 
+// handleDockerEvent checks the containers right after one of them stops.
+func (sup *SupervisorService) handleDockerEvent(event events.Message) {
+	if event.Type != events.ContainerEventType || (event.Action != "die" && event.Action != "oom") {
+		return
+	}
+	if event.Action == "oom" {
+		sup.handleAgentOOM(event.Actor.ID)
+	}
+	log.WithFields(log.Fields{
+		"action": event.Action,
+		"id":     event.Actor.ID,
+	}).Info("container stopped - triggering health check")
+	select {
+	case sup.healthCheckTrigger <- struct{}{}:
+	default:
+	}
+}
+
 func (sup *SupervisorService) doHealthCheck() error {
 	sup.mu.RLock()
-	defer sup.mu.RUnlock()
-	for _, knownContainer := range sup.containers {
-		var foundContainer *types.Container
+	// the recreated containers are updated after the check
+	recreated := make(map[*Container]*clients.DockerContainer)
+	err := sup.checkContainersUnsafe(recreated)
+	sup.mu.RUnlock()
+
+	if len(recreated) > 0 {
+		sup.mu.Lock()
+		for knownContainer, newContainer := range recreated {
+			sup.replaceContainerUnsafe(knownContainer, newContainer)
+		}
+		sup.mu.Unlock()
+	}
+	return err
+}
+
+// replaceContainerUnsafe updates the references to a recreated container and
+// attaches the new container to the networks of the old one.
+func (sup *SupervisorService) replaceContainerUnsafe(knownContainer *Container, newContainer *clients.DockerContainer) {
+	oldID := knownContainer.ID
+	knownContainer.DockerContainer = *newContainer
+	for _, chainContainers := range sup.chainContainers {
+		if chainContainers.scanner != nil && chainContainers.scanner.ID == oldID {
+			chainContainers.scanner = newContainer
+		}
+		if chainContainers.jsonRpc != nil && chainContainers.jsonRpc.ID == oldID {
+			chainContainers.jsonRpc = newContainer
+		}
+	}
+	if sup.egressProxy != nil && sup.egressProxy.ID == oldID {
+		sup.egressProxy = newContainer
+	}
 
-		// this has a threshold so that the healthcheck doesn't fail while a container is starting
-		err := utils.TryTimes(func(attempt int) error {
+	// the networks of the stopped agents may be removed already
+	networkIDs := knownContainer.networkIDs
+	knownContainer.networkIDs = nil
+	for _, networkID := range networkIDs {
+		if err := sup.attachNetworkUnsafe(newContainer.ID, networkID); err != nil {
+			log.WithError(err).Warnf("failed to attach recreated container '%s' to network '%s'", knownContainer.Name, networkID)
+		}
+	}
+}
+
+func (sup *SupervisorService) checkContainersUnsafe(recreated map[*Container]*clients.DockerContainer) error {
+	for _, knownContainer := range sup.containers {
+		foundContainer, ok := sup.findCachedContainer(knownContainer.ID)
+		if !ok {
+			// the container may not be in the cache yet, so look it up from docker
 			var err error
-			foundContainer, err = sup.client.GetContainerByID(sup.ctx, knownContainer.ID)
-			currAttempt := attempt + 1
-			if err != nil && errors.Is(err, clients.ErrContainerNotFound) {
-				log.Warnf("healthcheck: container '%s' with id '%s' was not found (attempt=%d/%d)", knownContainer.Name, knownContainer.ID, currAttempt, maxAttempts)
-				return err
-			}
-			// If the container is found alive at later attempts, make it obvious.
-			if currAttempt > 1 {
-				log.Infof("healthcheck: container '%s' with id '%s' was found alive (attempt=%d/%d)", knownContainer.Name, knownContainer.ID, currAttempt, maxAttempts)
+			foundContainer, err = sup.findContainer(knownContainer)
+			if err != nil {
+				// If this ever happens, then we have a critical gap in our logic.
+				log.Error(err.Error())
+				continue
 			}
-			return nil
-		}, maxAttempts, 1*time.Second)
-		if err != nil {
-			// If this ever happens, then we have a critical gap in our logic.
-			log.Error(err.Error())
-			continue
 		}
-		if err := sup.ensureUp(knownContainer, foundContainer); err != nil {
+		newContainer, err := sup.ensureUp(knownContainer, foundContainer)
+		if err != nil {
 			return err
 		}
+		if newContainer != nil {
+			recreated[knownContainer] = newContainer
+		}
 	}
 	return nil
 }
 
-func (sup *SupervisorService) ensureUp(knownContainer *Container, foundContainer *types.Container) error {
-	switch foundContainer.State {
-	case "created", "running", "restarting", "paused", "dead":
+func (sup *SupervisorService) findContainer(knownContainer *Container) (foundContainer *types.Container, err error) {
+	// this has a threshold so that the healthcheck doesn't fail while a container is starting
+	err = utils.TryTimes(func(attempt int) error {
+		var err error
+		foundContainer, err = sup.client.GetContainerByID(sup.ctx, knownContainer.ID)
+		currAttempt := attempt + 1
+		if err != nil && errors.Is(err, clients.ErrContainerNotFound) {
+			log.Warnf("healthcheck: container '%s' with id '%s' was not found (attempt=%d/%d)", knownContainer.Name, knownContainer.ID, currAttempt, maxAttempts)
+			return err
+		}
+		// If the container is found alive at later attempts, make it obvious.
+		if currAttempt > 1 {
+			log.Infof("healthcheck: container '%s' with id '%s' was found alive (attempt=%d/%d)", knownContainer.Name, knownContainer.ID, currAttempt, maxAttempts)
+		}
 		return nil
-	case "exited":
-		log.Warnf("starting exited container '%s'", knownContainer.Name)
-		_, err := sup.client.StartContainer(sup.ctx, knownContainer.Config)
+	}, maxAttempts, 1*time.Second)
+	return
+}
+
+func (sup *SupervisorService) findCachedContainer(id string) (*types.Container, bool) {
+	if sup.containerCache == nil {
+		return nil, false
+	}
+	return sup.containerCache.FindByID(id)
+}
+
+// ensureUp restarts the exited and the dead containers by the restart policy. The dead containers
+// are recreated and returned.
+func (sup *SupervisorService) ensureUp(knownContainer *Container, foundContainer *types.Container) (*clients.DockerContainer, error) {
+	switch foundContainer.State {
+	case "created", "running", "restarting", "paused":
+		return nil, nil
+	case "exited", "dead":
+		restart, failedNow := knownContainer.restarts.shouldRestart(time.Now())
+		if failedNow {
+			sup.handleContainerFailure(knownContainer)
+		}
+		if !restart {
+			return nil, nil
+		}
+		if foundContainer.State == "exited" {
+			log.Warnf("starting exited container '%s'", knownContainer.Name)
+			_, err := sup.client.StartContainer(sup.ctx, knownContainer.Config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to start container '%s': %v", knownContainer.Name, err)
+			}
+			return nil, nil
+		}
+		// a dead container cannot be started again
+		log.Warnf("recreating dead container '%s'", knownContainer.Name)
+		if err := sup.client.RemoveContainer(sup.ctx, knownContainer.ID); err != nil {
+			return nil, fmt.Errorf("failed to remove dead container '%s': %v", knownContainer.Name, err)
+		}
+		if err := sup.client.WaitContainerPrune(sup.ctx, knownContainer.ID); err != nil {
+			return nil, fmt.Errorf("failed while waiting for dead container '%s' to be removed: %v", knownContainer.Name, err)
+		}
+		newContainer, err := sup.client.StartContainer(sup.ctx, knownContainer.Config)
 		if err != nil {
-			return fmt.Errorf("failed to start container '%s': %v", knownContainer.Name, err)
+			return nil, fmt.Errorf("failed to recreate container '%s': %v", knownContainer.Name, err)
 		}
-		return nil
+		return newContainer, nil
 	default:
 		log.Panicf("unhandled container state: %s", foundContainer.State)
 	}
-	return nil
+	return nil, nil
+}
+
+// handleContainerFailure reports the container which is not restarted anymore.
+func (sup *SupervisorService) handleContainerFailure(knownContainer *Container) {
+	log.WithField("restartPolicy", sup.config.Config.RestartPolicy).Errorf("container '%s' exited too many times - marked as failed", knownContainer.Name)
+	if knownContainer.IsAgent {
+		agentCfg := *knownContainer.AgentConfig
+		sup.msgClient.Publish(messaging.ChainSubject(messaging.SubjectAgentsStatusFailed, agentCfg.ChainID), messaging.AgentPayload{agentCfg})
+	}
 }
@@ -0,0 +1,80 @@
+package supervisor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/forta-network/forta-node/config"
+)
+
+// restartTracker applies the restart policy to a container.
+type restartTracker struct {
+	cfg config.RestartPolicyConfig
+
+	// restarts are the restart times in the window
+	restarts []time.Time
+	exitedAt time.Time
+	failed   bool
+	mu       sync.Mutex
+}
+
+func newRestartTracker(cfg config.RestartPolicyConfig) *restartTracker {
+	return &restartTracker{cfg: cfg}
+}
+
+// shouldRestart tells if the exited container should be restarted now. The container is
+// marked as failed if it was restarted too many times in the window and that is reported once.
+func (rt *restartTracker) shouldRestart(now time.Time) (restart bool, failedNow bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.failed {
+		return false, false
+	}
+
+	// forget the restarts before the window
+	window := time.Duration(rt.cfg.WindowSeconds) * time.Second
+	var restarts []time.Time
+	for _, restartedAt := range rt.restarts {
+		if now.Sub(restartedAt) < window {
+			restarts = append(restarts, restartedAt)
+		}
+	}
+	rt.restarts = restarts
+
+	if len(rt.restarts) >= rt.cfg.MaxRestarts {
+		rt.failed = true
+		return false, true
+	}
+
+	// wait longer after each restart in the window
+	if rt.exitedAt.IsZero() {
+		rt.exitedAt = now
+	}
+	if now.Sub(rt.exitedAt) < rt.backoffUnsafe() {
+		return false, false
+	}
+
+	rt.restarts = append(rt.restarts, now)
+	rt.exitedAt = time.Time{}
+	return true, false
+}
+
+func (rt *restartTracker) backoffUnsafe() time.Duration {
+	backoff := time.Duration(rt.cfg.InitialBackoffSeconds) * time.Second
+	maxBackoff := time.Duration(rt.cfg.MaxBackoffSeconds) * time.Second
+	for i := 0; i < len(rt.restarts) && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// isFailed tells if the container reached the max restarts.
+func (rt *restartTracker) isFailed() bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.failed
+}
@@ -0,0 +1,53 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/forta-network/forta-node/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartTracker(t *testing.T) {
+	r := require.New(t)
+
+	rt := newRestartTracker(config.RestartPolicyConfig{
+		InitialBackoffSeconds: 5,
+		MaxBackoffSeconds:     15,
+		MaxRestarts:           3,
+		WindowSeconds:         600,
+	})
+	now := time.Now()
+
+	// waits for the initial backoff after the first exit
+	restart, failed := rt.shouldRestart(now)
+	r.False(restart)
+	r.False(failed)
+	restart, _ = rt.shouldRestart(now.Add(time.Second * 5))
+	r.True(restart)
+
+	// waits twice as long after the first restart
+	now = now.Add(time.Second * 10)
+	restart, _ = rt.shouldRestart(now)
+	r.False(restart)
+	restart, _ = rt.shouldRestart(now.Add(time.Second * 5))
+	r.False(restart)
+	restart, _ = rt.shouldRestart(now.Add(time.Second * 10))
+	r.True(restart)
+
+	// the backoff does not exceed the max
+	now = now.Add(time.Second * 20)
+	rt.shouldRestart(now)
+	restart, _ = rt.shouldRestart(now.Add(time.Second * 15))
+	r.True(restart)
+
+	// fails after the max restarts in the window
+	now = now.Add(time.Second * 30)
+	restart, failed = rt.shouldRestart(now)
+	r.False(restart)
+	r.True(failed)
+	r.True(rt.isFailed())
+	restart, failed = rt.shouldRestart(now.Add(time.Hour))
+	r.False(restart)
+	r.False(failed)
+}
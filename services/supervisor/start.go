@@ -12,6 +12,7 @@ import (
 	"github.com/forta-network/forta-core-go/manifest"
 	"github.com/forta-network/forta-core-go/release"
 
+	"github.com/docker/docker/api/types"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ipfs/go-cid"
 	log "github.com/sirupsen/logrus"
@@ -47,10 +48,12 @@ type SupervisorService struct {
 	maxLogSize  string
 	maxLogFiles int
 
-	scannerContainer *clients.DockerContainer
-	jsonRpcContainer *clients.DockerContainer
-	containers       []*Container
-	mu               sync.RWMutex
+	agentSeccompProfile string
+
+	chainContainers map[int]*chainContainers
+	egressProxy     *clients.DockerContainer
+	containers      []*Container
+	mu              sync.RWMutex
 
 	lastRun                   health.TimeTracker
 	lastStop                  health.TimeTracker
@@ -63,6 +66,14 @@ type SupervisorService struct {
 
 	agentLogsClient agentlogs.Client
 	prevAgentLogs   agentlogs.Agents
+
+	// containerCache is updated by the docker events and triggers the health checks
+	containerCache     *clients.ContainerCache
+	healthCheckTrigger chan struct{}
+
+	// prevAgentStats are the last stats samples by the agent container ID
+	prevAgentStats map[string]*types.StatsJSON
+	lastAgentStats health.TimeTracker
 }
 
 type SupervisorServiceConfig struct {
@@ -71,11 +82,21 @@ type SupervisorServiceConfig struct {
 	Key        *keystore.Key
 }
 
+// chainContainers are the service containers which are run for each chain.
+type chainContainers struct {
+	scanner *clients.DockerContainer
+	jsonRpc *clients.DockerContainer
+}
+
 // Container extends the default container data.
 type Container struct {
 	clients.DockerContainer
 	IsAgent     bool
 	AgentConfig *config.AgentConfig
+
+	restarts *restartTracker
+	// networkIDs are the networks attached after the container was started
+	networkIDs []string
 }
 
 func (sup *SupervisorService) Start() error {
@@ -83,7 +104,14 @@ func (sup *SupervisorService) Start() error {
 		return err
 	}
 
+	sup.containerCache = clients.NewContainerCache(sup.ctx, sup.client)
+	sup.containerCache.OnEvent(sup.handleDockerEvent)
+	if err := sup.containerCache.Start(); err != nil {
+		return fmt.Errorf("failed to start the container cache: %v", err)
+	}
+
 	go sup.healthCheck()
+	go sup.syncAgentStats()
 
 	return nil
 }
@@ -127,6 +155,10 @@ func (sup *SupervisorService) start() error {
 	sup.maxLogSize = sup.config.Config.Log.MaxLogSize
 	sup.maxLogFiles = sup.config.Config.Log.MaxLogFiles
 
+	if err := sup.loadAgentSeccompProfile(); err != nil {
+		return err
+	}
+
 	if err := sup.removeOldContainers(); err != nil {
 		return err
 	}
@@ -204,10 +236,92 @@ func (sup *SupervisorService) start() error {
 	}
 	sup.registerMessageHandlers()
 
-	sup.jsonRpcContainer, err = sup.client.StartContainer(sup.ctx, clients.DockerContainerConfig{
-		Name:  config.DockerJSONRPCProxyContainerName,
+	for _, chainID := range sup.chainIDs() {
+		if err := sup.startChainContainers(chainID, commonNodeImage, hostFortaDir, releaseInfo, nodeNetworkID, internalNetworkID); err != nil {
+			return err
+		}
+	}
+
+	if sup.config.Config.AgentEgress.NeedsProxy() {
+		if err := sup.startEgressProxy(commonNodeImage, hostFortaDir, nodeNetworkID, internalNetworkID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startEgressProxy starts the proxy which the restricted agents reach the allowed hosts through.
+func (sup *SupervisorService) startEgressProxy(commonNodeImage, hostFortaDir, nodeNetworkID, internalNetworkID string) error {
+	egressProxyContainer, err := sup.client.StartContainer(sup.ctx, clients.DockerContainerConfig{
+		Name:  config.DockerEgressProxyContainerName,
+		Image: commonNodeImage,
+		Cmd:   []string{config.DefaultFortaNodeBinaryPath, "egress-proxy"},
+		Volumes: map[string]string{
+			// give access to host docker
+			"/var/run/docker.sock": "/var/run/docker.sock",
+			hostFortaDir:           config.DefaultContainerFortaDirPath,
+		},
+		Ports: map[string]string{
+			"": config.DefaultHealthPort, // random host port
+		},
+		NetworkID:   nodeNetworkID,
+		MaxLogFiles: sup.maxLogFiles,
+		MaxLogSize:  sup.maxLogSize,
+	})
+	if err != nil {
+		return err
+	}
+	sup.addContainerUnsafe(egressProxyContainer)
+	sup.egressProxy = egressProxyContainer
+
+	if !sup.config.Config.ExposeNats {
+		return sup.attachToNetwork(config.DockerEgressProxyContainerName, internalNetworkID)
+	}
+	return nil
+}
+
+// chainIDs returns zero for the primary chain and the IDs of the additional chains.
+func (sup *SupervisorService) chainIDs() []int {
+	chainIDs := []int{0}
+	for _, chainCfg := range sup.config.Config.Chains {
+		if chainCfg.ChainID != sup.config.Config.ChainID {
+			chainIDs = append(chainIDs, chainCfg.ChainID)
+		}
+	}
+	return chainIDs
+}
+
+// localAlertsPort returns the host port to expose the scanner api of the chain on.
+func (sup *SupervisorService) localAlertsPort(chainID int) string {
+	if sup.config.Config.LocalAlerts.Disable {
+		return ""
+	}
+	if chainID == 0 {
+		return sup.config.Config.LocalAlerts.Port
+	}
+	for _, chainCfg := range sup.config.Config.Chains {
+		if chainCfg.ChainID == chainID {
+			return chainCfg.LocalAlertsPort
+		}
+	}
+	return ""
+}
+
+// startChainContainers starts the JSON-RPC proxy and the scanner for the chain.
+func (sup *SupervisorService) startChainContainers(
+	chainID int, commonNodeImage, hostFortaDir string, releaseInfo *release.ReleaseInfo, nodeNetworkID, internalNetworkID string,
+) error {
+	env := map[string]string{}
+	if chainID != 0 {
+		env[config.EnvChainID] = strconv.Itoa(chainID)
+	}
+
+	jsonRpcContainer, err := sup.client.StartContainer(sup.ctx, clients.DockerContainerConfig{
+		Name:  config.JSONRPCProxyContainerName(chainID),
 		Image: commonNodeImage,
 		Cmd:   []string{config.DefaultFortaNodeBinaryPath, "json-rpc"},
+		Env:   env,
 		Volumes: map[string]string{
 			// give access to host docker
 			"/var/run/docker.sock": "/var/run/docker.sock",
@@ -224,21 +338,30 @@ func (sup *SupervisorService) start() error {
 	if err != nil {
 		return err
 	}
-	sup.addContainerUnsafe(sup.jsonRpcContainer)
+	sup.addContainerUnsafe(jsonRpcContainer)
 
-	sup.scannerContainer, err = sup.client.StartContainer(sup.ctx, clients.DockerContainerConfig{
-		Name:  config.DockerScannerContainerName,
+	scannerPorts := map[string]string{
+		"": config.DefaultHealthPort, // random host port
+	}
+	// expose the scanner api only to the host so the local alerts can be queried
+	if port := sup.localAlertsPort(chainID); len(port) > 0 {
+		scannerPorts["127.0.0.1:"+port] = config.DefaultScannerAPIPort
+	}
+	scannerEnv := map[string]string{
+		config.EnvReleaseInfo: releaseInfo.String(),
+	}
+	for k, v := range env {
+		scannerEnv[k] = v
+	}
+	scannerContainer, err := sup.client.StartContainer(sup.ctx, clients.DockerContainerConfig{
+		Name:  config.ScannerContainerName(chainID),
 		Image: commonNodeImage,
 		Cmd:   []string{config.DefaultFortaNodeBinaryPath, "scanner"},
-		Env: map[string]string{
-			config.EnvReleaseInfo: releaseInfo.String(),
-		},
+		Env:   scannerEnv,
 		Volumes: map[string]string{
 			hostFortaDir: config.DefaultContainerFortaDirPath,
 		},
-		Ports: map[string]string{
-			"": config.DefaultHealthPort, // random host port
-		},
+		Ports: scannerPorts,
 		Files: map[string][]byte{
 			"passphrase": []byte(sup.config.Passphrase),
 		},
@@ -250,17 +373,24 @@ func (sup *SupervisorService) start() error {
 	if err != nil {
 		return err
 	}
-	sup.addContainerUnsafe(sup.scannerContainer)
+	sup.addContainerUnsafe(scannerContainer)
+
+	if sup.chainContainers == nil {
+		sup.chainContainers = make(map[int]*chainContainers)
+	}
+	sup.chainContainers[chainID] = &chainContainers{
+		scanner: scannerContainer,
+		jsonRpc: jsonRpcContainer,
+	}
 
 	if !sup.config.Config.ExposeNats {
-		if err := sup.attachToNetwork(config.DockerScannerContainerName, internalNetworkID); err != nil {
+		if err := sup.attachToNetwork(config.ScannerContainerName(chainID), internalNetworkID); err != nil {
 			return err
 		}
-		if err := sup.attachToNetwork(config.DockerJSONRPCProxyContainerName, internalNetworkID); err != nil {
+		if err := sup.attachToNetwork(config.JSONRPCProxyContainerName(chainID), internalNetworkID); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
@@ -269,12 +399,26 @@ func (sup *SupervisorService) attachToNetwork(containerName, nodeNetworkID strin
 	if err != nil {
 		return fmt.Errorf("failed to get '%s' container while attaching to node network: %v", containerName, err)
 	}
-	if err := sup.client.AttachNetwork(sup.ctx, container.ID, nodeNetworkID); err != nil {
+	if err := sup.attachNetworkUnsafe(container.ID, nodeNetworkID); err != nil {
 		return fmt.Errorf("failed to attach '%s' container to node network: %v", containerName, err)
 	}
 	return nil
 }
 
+// attachNetworkUnsafe attaches the container to the network and keeps the network
+// so that it can be attached again if the container is recreated.
+func (sup *SupervisorService) attachNetworkUnsafe(containerID, networkID string) error {
+	if err := sup.client.AttachNetwork(sup.ctx, containerID, networkID); err != nil {
+		return err
+	}
+	for _, container := range sup.containers {
+		if container.ID == containerID {
+			container.networkIDs = append(container.networkIDs, networkID)
+		}
+	}
+	return nil
+}
+
 func (sup *SupervisorService) ensureNodeImages() error {
 	for _, image := range []struct {
 		Name string
@@ -305,12 +449,17 @@ func (sup *SupervisorService) removeOldContainers() error {
 	var containersToRemove []*containerDefinition
 
 	// gather old service containers
-	for _, containerName := range []string{
+	containerNames := []string{
 		config.DockerScannerContainerName,
 		config.DockerJSONRPCProxyContainerName,
 		config.DockerNatsContainerName,
 		config.DockerIpfsContainerName,
-	} {
+		config.DockerEgressProxyContainerName,
+	}
+	for _, chainID := range sup.chainIDs()[1:] {
+		containerNames = append(containerNames, config.ScannerContainerName(chainID), config.JSONRPCProxyContainerName(chainID))
+	}
+	for _, containerName := range containerNames {
 		container, err := sup.client.GetContainerByName(sup.ctx, containerName)
 		if err != nil {
 			log.WithError(err).WithField("containerName", containerName).Info("did not find old service container - ignoring")
@@ -465,17 +614,36 @@ func (sup *SupervisorService) Health() health.Reports {
 	sup.mu.RLock()
 	defer sup.mu.RUnlock()
 
+	expectedContainers := sup.expectedContainers()
 	containersStatus := health.StatusOK
-	if len(sup.containers) < config.DockerSupervisorManagedContainers {
+	if len(sup.containers) < expectedContainers {
 		containersStatus = health.StatusFailing
 	}
 
+	// only the failed node containers make the node fail
+	var failedContainers []string
+	failedStatus := health.StatusInfo
+	for _, container := range sup.containers {
+		if !container.restarts.isFailed() {
+			continue
+		}
+		failedContainers = append(failedContainers, container.Name)
+		if !container.IsAgent {
+			failedStatus = health.StatusFailing
+		}
+	}
+
 	return health.Reports{
 		&health.Report{
 			Name:    "containers.managed",
 			Status:  containersStatus,
 			Details: strconv.Itoa(len(sup.containers)),
 		},
+		&health.Report{
+			Name:    "containers.expected",
+			Status:  health.StatusInfo,
+			Details: strconv.Itoa(expectedContainers),
+		},
 		&health.Report{
 			Name:    "event.run-agent.time",
 			Status:  health.StatusInfo,
@@ -490,9 +658,25 @@ func (sup *SupervisorService) Health() health.Reports {
 		sup.lastTelemetryRequestError.GetReport("event.telemetry-sync.error"),
 		sup.lastAgentLogsRequest.GetReport("event.agent-logs-sync.time"),
 		sup.lastAgentLogsRequestError.GetReport("event.agent-logs-sync.error"),
+		&health.Report{
+			Name:    "containers.failed",
+			Status:  failedStatus,
+			Details: strings.Join(failedContainers, ","),
+		},
+		sup.lastAgentStats.GetReport("event.agent-stats-sample.time"),
 	}
 }
 
+// expectedContainers returns the minimum number of containers: the common service containers,
+// the scanner and the JSON-RPC proxy for each additional chain and the egress proxy if it is needed.
+func (sup *SupervisorService) expectedContainers() int {
+	expected := config.DockerSupervisorManagedContainers + 2*(len(sup.chainIDs())-1)
+	if sup.config.Config.AgentEgress.NeedsProxy() {
+		expected++
+	}
+	return expected
+}
+
 func NewSupervisorService(ctx context.Context, cfg SupervisorServiceConfig) (*SupervisorService, error) {
 	dockerClient, err := clients.NewDockerClient("supervisor")
 	if err != nil {
@@ -532,5 +716,7 @@ func NewSupervisorService(ctx context.Context, cfg SupervisorServiceConfig) (*Su
 		config:           cfg,
 		healthClient:     health.NewClient(),
 		agentLogsClient:  agentlogs.NewClient(cfg.Config.AgentLogsConfig.URL),
+
+		healthCheckTrigger: make(chan struct{}, 1),
 	}, nil
 }
@@ -3,16 +3,20 @@ package supervisor
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path"
 
 	"github.com/forta-network/forta-node/clients"
 	"github.com/forta-network/forta-node/clients/messaging"
 	"github.com/forta-network/forta-node/config"
+	"github.com/goccy/go-json"
 
 	log "github.com/sirupsen/logrus"
 )
 
 var (
 	errAgentAlreadyRunning = errors.New("agent already running")
+	errAgentFailed         = errors.New("agent failed")
 )
 
 func (sup *SupervisorService) startAgent(agent config.AgentConfig) error {
@@ -23,15 +27,47 @@ func (sup *SupervisorService) startAgent(agent config.AgentConfig) error {
 	sup.mu.Lock()
 	defer sup.mu.Unlock()
 
-	_, ok := sup.getContainerUnsafe(agent.ContainerName())
+	container, ok := sup.getContainerUnsafe(agent.ContainerName())
 	if ok {
+		if container.restarts.isFailed() {
+			return errAgentFailed
+		}
 		return errAgentAlreadyRunning
 	}
 
-	nwID, err := sup.client.CreatePublicNetwork(sup.ctx, agent.ContainerName())
+	chainContainers, ok := sup.chainContainers[agent.ChainID]
+	if !ok {
+		return fmt.Errorf("chain %d is not configured", agent.ChainID)
+	}
+
+	// the restricted agents can only reach the node containers on their network
+	restricted := sup.config.Config.AgentEgress.IsRestricted(agent)
+	var (
+		nwID string
+		err  error
+	)
+	if restricted {
+		nwID, err = sup.client.CreateInternalNetwork(sup.ctx, restrictedNetworkName(agent))
+	} else {
+		nwID, err = sup.client.CreatePublicNetwork(sup.ctx, agent.ContainerName())
+	}
 	if err != nil {
 		return err
 	}
+	networkContainerIDs := []string{chainContainers.scanner.ID, chainContainers.jsonRpc.ID}
+
+	env := map[string]string{
+		config.EnvJsonRpcHost:   config.JSONRPCProxyContainerName(agent.ChainID),
+		config.EnvJsonRpcPort:   "8545",
+		config.EnvAgentGrpcPort: agent.GrpcPort(),
+	}
+	if restricted && sup.egressProxy != nil {
+		proxyURL := fmt.Sprintf("http://%s:%s", config.DockerEgressProxyContainerName, config.DefaultEgressProxyPort)
+		env[config.EnvHTTPProxy] = proxyURL
+		env[config.EnvHTTPSProxy] = proxyURL
+		env[config.EnvNoProxy] = config.JSONRPCProxyContainerName(agent.ChainID)
+		networkContainerIDs = append(networkContainerIDs, sup.egressProxy.ID)
+	}
 
 	limits := config.GetAgentResourceLimits(sup.config.Config.ResourcesConfig)
 
@@ -40,25 +76,22 @@ func (sup *SupervisorService) startAgent(agent config.AgentConfig) error {
 		Image:          agent.Image,
 		NetworkID:      nwID,
 		LinkNetworkIDs: []string{},
-		Env: map[string]string{
-			config.EnvJsonRpcHost:   config.DockerJSONRPCProxyContainerName,
-			config.EnvJsonRpcPort:   "8545",
-			config.EnvAgentGrpcPort: agent.GrpcPort(),
-		},
-		MaxLogFiles: sup.maxLogFiles,
-		MaxLogSize:  sup.maxLogSize,
-		CPUQuota:    limits.CPUQuota,
-		Memory:      limits.Memory,
+		Env:            env,
+		MaxLogFiles:    sup.maxLogFiles,
+		MaxLogSize:     sup.maxLogSize,
+		CPUQuota:       limits.CPUQuota,
+		Memory:         limits.Memory,
 		Labels: map[string]string{
 			clients.DockerLabelFortaSupervisorStrategyVersion: SupervisorStrategyVersion,
 		},
+		Security: sup.agentSecurityConfig(agent),
 	})
 	if err != nil {
 		return err
 	}
-	// Attach the scanner and the JSON-RPC proxy to the agent's network.
-	for _, containerID := range []string{sup.scannerContainer.ID, sup.jsonRpcContainer.ID} {
-		err := sup.client.AttachNetwork(sup.ctx, containerID, nwID)
+	// Attach the scanner, the JSON-RPC proxy and the egress proxy to the agent's network.
+	for _, containerID := range networkContainerIDs {
+		err := sup.attachNetworkUnsafe(containerID, nwID)
 		if err != nil {
 			return err
 		}
@@ -69,6 +102,57 @@ func (sup *SupervisorService) startAgent(agent config.AgentConfig) error {
 	return nil
 }
 
+// restrictedNetworkName returns the internal network name for the agent. It is different from
+// the public network name so that the existing public network is not reused.
+func restrictedNetworkName(agent config.AgentConfig) string {
+	return fmt.Sprintf("%s-restricted", agent.ContainerName())
+}
+
+// agentSecurityConfig returns the hardening profile of the agent container.
+func (sup *SupervisorService) agentSecurityConfig(agent config.AgentConfig) *clients.DockerSecurityConfig {
+	securityCfg := sup.config.Config.AgentSecurity
+	if securityCfg.Disable {
+		return nil
+	}
+	dockerSecurityCfg := &clients.DockerSecurityConfig{
+		NoNewPrivileges: true,
+		PidsLimit:       securityCfg.PidsLimit,
+		SeccompProfile:  sup.agentSeccompProfile,
+	}
+	// the image defaults are kept for the filesystem, the user and the capabilities
+	if !securityCfg.Strict {
+		return dockerSecurityCfg
+	}
+	if securityCfg.IsCompatibilityAgent(agent) {
+		log.WithField("agent", agent.ID).Warn("running agent in the compatibility mode")
+		return dockerSecurityCfg
+	}
+	dockerSecurityCfg.ReadOnlyRootFS = true
+	dockerSecurityCfg.Tmpfs = map[string]string{
+		"/tmp": fmt.Sprintf("rw,noexec,nosuid,size=%dm", securityCfg.TmpfsSizeMiB),
+	}
+	dockerSecurityCfg.CapDrop = []string{"ALL"}
+	dockerSecurityCfg.User = securityCfg.User
+	return dockerSecurityCfg
+}
+
+// loadAgentSeccompProfile reads the seccomp profile file for the agents, if it is configured.
+func (sup *SupervisorService) loadAgentSeccompProfile() error {
+	securityCfg := sup.config.Config.AgentSecurity
+	if securityCfg.Disable || len(securityCfg.SeccompProfile) == 0 {
+		return nil
+	}
+	b, err := os.ReadFile(path.Join(sup.config.Config.FortaDir, securityCfg.SeccompProfile))
+	if err != nil {
+		return fmt.Errorf("failed to read the agent seccomp profile: %v", err)
+	}
+	if !json.Valid(b) {
+		return errors.New("invalid agent seccomp profile: not json")
+	}
+	sup.agentSeccompProfile = string(b)
+	return nil
+}
+
 func (sup *SupervisorService) getContainerUnsafe(name string) (*Container, bool) {
 	for _, container := range sup.containers {
 		if container.Name == name {
@@ -79,15 +163,17 @@ func (sup *SupervisorService) getContainerUnsafe(name string) (*Container, bool)
 }
 
 func (sup *SupervisorService) addContainerUnsafe(container *clients.DockerContainer, agentConfig ...*config.AgentConfig) {
+	restarts := newRestartTracker(sup.config.Config.RestartPolicy)
 	if agentConfig != nil {
 		sup.containers = append(sup.containers, &Container{
 			DockerContainer: *container,
 			IsAgent:         true,
 			AgentConfig:     agentConfig[0],
+			restarts:        restarts,
 		})
 		return
 	}
-	sup.containers = append(sup.containers, &Container{DockerContainer: *container})
+	sup.containers = append(sup.containers, &Container{DockerContainer: *container, restarts: restarts})
 }
 
 func (sup *SupervisorService) handleAgentRun(payload messaging.AgentPayload) error {
@@ -101,7 +187,12 @@ func (sup *SupervisorService) handleAgentRun(payload messaging.AgentPayload) err
 		err := sup.startAgent(agent)
 		if err == errAgentAlreadyRunning {
 			log.Infof("agent container '%s' is already running - skipped", agent.ContainerName())
-			sup.msgClient.Publish(messaging.SubjectAgentsStatusRunning, messaging.AgentPayload{agent})
+			sup.msgClient.Publish(messaging.ChainSubject(messaging.SubjectAgentsStatusRunning, agent.ChainID), messaging.AgentPayload{agent})
+			continue
+		}
+		if err == errAgentFailed {
+			log.Warnf("agent container '%s' has failed - skipped", agent.ContainerName())
+			sup.msgClient.Publish(messaging.ChainSubject(messaging.SubjectAgentsStatusFailed, agent.ChainID), messaging.AgentPayload{agent})
 			continue
 		}
 		if err != nil {
@@ -110,7 +201,7 @@ func (sup *SupervisorService) handleAgentRun(payload messaging.AgentPayload) err
 		}
 
 		// Broadcast the agent status.
-		sup.msgClient.Publish(messaging.SubjectAgentsStatusRunning, messaging.AgentPayload{agent})
+		sup.msgClient.Publish(messaging.ChainSubject(messaging.SubjectAgentsStatusRunning, agent.ChainID), messaging.AgentPayload{agent})
 	}
 	return nil
 }
@@ -144,14 +235,17 @@ func (sup *SupervisorService) handleAgentStop(payload messaging.AgentPayload) er
 	}
 	sup.containers = remainingContainers
 
-	// Broadcast the agent statuses.
+	// Broadcast the agent statuses. The agents in one payload are always from the same chain.
 	if len(payload) > 0 {
-		sup.msgClient.Publish(messaging.SubjectAgentsStatusStopped, payload)
+		sup.msgClient.Publish(messaging.ChainSubject(messaging.SubjectAgentsStatusStopped, payload[0].ChainID), payload)
 	}
 	return nil
 }
 
 func (sup *SupervisorService) registerMessageHandlers() {
-	sup.msgClient.Subscribe(messaging.SubjectAgentsActionRun, messaging.AgentsHandler(sup.handleAgentRun))
-	sup.msgClient.Subscribe(messaging.SubjectAgentsActionStop, messaging.AgentsHandler(sup.handleAgentStop))
+	// the agents of each chain are managed through the subjects of that chain
+	for _, chainID := range sup.chainIDs() {
+		sup.msgClient.Subscribe(messaging.ChainSubject(messaging.SubjectAgentsActionRun, chainID), messaging.AgentsHandler(sup.handleAgentRun))
+		sup.msgClient.Subscribe(messaging.ChainSubject(messaging.SubjectAgentsActionStop, chainID), messaging.AgentsHandler(sup.handleAgentStop))
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/forta-network/forta-core-go/protocol"
 	"github.com/forta-network/forta-core-go/release"
 
 	"github.com/docker/docker/api/types"
@@ -141,6 +142,8 @@ func (s *Suite) initialContainerCheck() {
 	} {
 		s.dockerClient.EXPECT().GetContainerByName(s.service.ctx, containerName).Return(&types.Container{ID: testGenericContainerID}, nil)
 	}
+	// the egress proxy is not running without the egress policy
+	s.dockerClient.EXPECT().GetContainerByName(s.service.ctx, config.DockerEgressProxyContainerName).Return(nil, clients.ErrContainerNotFound)
 
 	s.dockerClient.EXPECT().GetContainers(s.service.ctx).Return([]types.Container{
 		{
@@ -197,6 +200,25 @@ func (s *Suite) TestAgentRun() {
 	s.r.NoError(s.service.handleAgentRun(agentPayload))
 }
 
+// TestAgentRunRestricted tests running an agent with the egress policy.
+func (s *Suite) TestAgentRunRestricted() {
+	s.service.config.Config.AgentEgress.Restrict = true
+
+	agentConfig, agentPayload := testAgentData()
+	// Creates an internal network for the agent instead of a public one.
+	s.agentImageClient.EXPECT().EnsureLocalImage(s.service.ctx, "agent test-agent", agentConfig.Image).Return(nil)
+	s.dockerClient.EXPECT().CreateInternalNetwork(s.service.ctx, testAgentContainerName+"-restricted").Return(testAgentNetworkID, nil)
+	s.dockerClient.EXPECT().StartContainer(s.service.ctx, (configMatcher)(clients.DockerContainerConfig{
+		Name: agentConfig.ContainerName(),
+	})).Return(&clients.DockerContainer{Name: agentConfig.ContainerName(), ID: testAgentContainerID}, nil)
+	s.dockerClient.EXPECT().AttachNetwork(s.service.ctx, testScannerContainerID, testAgentNetworkID)
+	s.dockerClient.EXPECT().AttachNetwork(s.service.ctx, testProxyContainerID, testAgentNetworkID)
+
+	s.msgClient.EXPECT().Publish(messaging.SubjectAgentsStatusRunning, agentPayload)
+
+	s.r.NoError(s.service.handleAgentRun(agentPayload))
+}
+
 // TestAgentRunAgain tests running an agent twice.
 func (s *Suite) TestAgentRunAgain() {
 	s.TestAgentRun()
@@ -231,3 +253,82 @@ func (s *Suite) TestAgentStopNone() {
 
 	s.r.NoError(s.service.handleAgentStop(agentPayload))
 }
+
+// TestAgentSecurityConfig tests the hardening profile of the agent containers.
+func (s *Suite) TestAgentSecurityConfig() {
+	agentConfig, _ := testAgentData()
+	s.service.config.Config.AgentSecurity = config.AgentSecurityConfig{
+		User:         "65534:65534",
+		PidsLimit:    256,
+		TmpfsSizeMiB: 64,
+	}
+
+	// the image defaults are kept unless the strict mode is enabled
+	securityCfg := s.service.agentSecurityConfig(agentConfig)
+	s.r.False(securityCfg.ReadOnlyRootFS)
+	s.r.Empty(securityCfg.CapDrop)
+	s.r.Empty(securityCfg.User)
+	s.r.True(securityCfg.NoNewPrivileges)
+	s.r.Equal(int64(256), securityCfg.PidsLimit)
+
+	s.service.config.Config.AgentSecurity.Strict = true
+	securityCfg = s.service.agentSecurityConfig(agentConfig)
+	s.r.True(securityCfg.ReadOnlyRootFS)
+	s.r.True(securityCfg.NoNewPrivileges)
+	s.r.Equal([]string{"ALL"}, securityCfg.CapDrop)
+	s.r.Equal("65534:65534", securityCfg.User)
+	s.r.Equal(int64(256), securityCfg.PidsLimit)
+
+	// the compatibility mode keeps the image defaults
+	s.service.config.Config.AgentSecurity.CompatibilityAgents = []string{testAgentID}
+	securityCfg = s.service.agentSecurityConfig(agentConfig)
+	s.r.False(securityCfg.ReadOnlyRootFS)
+	s.r.Empty(securityCfg.CapDrop)
+	s.r.Empty(securityCfg.User)
+	s.r.True(securityCfg.NoNewPrivileges)
+
+	s.service.config.Config.AgentSecurity.Disable = true
+	s.r.Nil(s.service.agentSecurityConfig(agentConfig))
+}
+
+// TestAgentStats tests publishing the agent container resource usage.
+func (s *Suite) TestAgentStats() {
+	s.TestAgentRun()
+
+	agentStats := &types.StatsJSON{}
+	agentStats.CPUStats.CPUUsage.TotalUsage = 200
+	agentStats.CPUStats.SystemUsage = 1000
+	agentStats.CPUStats.OnlineCPUs = 1
+	agentStats.MemoryStats.Usage = 1024
+	s.dockerClient.EXPECT().GetContainerStats(s.service.ctx, testAgentContainerID).Return(agentStats, nil)
+	s.msgClient.EXPECT().PublishProto(messaging.SubjectMetricAgent, gomock.Any()).Do(func(subject string, payload interface{}) {
+		metricList := payload.(*protocol.AgentMetricList)
+		s.r.Len(metricList.Metrics, 2)
+		for _, metric := range metricList.Metrics {
+			s.r.Equal(testAgentID, metric.AgentId)
+		}
+	})
+
+	s.service.doSyncAgentStats()
+	s.r.Equal(agentStats, s.service.prevAgentStats[testAgentContainerID])
+}
+
+// TestReplaceRecreatedContainer tests that a recreated container replaces the old one everywhere.
+func (s *Suite) TestReplaceRecreatedContainer() {
+	const newScannerContainerID = "new-scanner-container-id"
+	var scanner *Container
+	for _, container := range s.service.containers {
+		if container.ID == testScannerContainerID {
+			scanner = container
+		}
+	}
+	s.r.NotNil(scanner)
+	s.r.Equal([]string{testNatsNetworkID}, scanner.networkIDs)
+
+	// The new container is attached to the nats network again
+	s.dockerClient.EXPECT().AttachNetwork(s.service.ctx, newScannerContainerID, testNatsNetworkID)
+	s.service.replaceContainerUnsafe(scanner, &clients.DockerContainer{ID: newScannerContainerID})
+	s.r.Equal(newScannerContainerID, scanner.ID)
+	s.r.Equal(newScannerContainerID, s.service.chainContainers[0].scanner.ID)
+	s.r.Equal([]string{testNatsNetworkID}, scanner.networkIDs)
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/goccy/go-json"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultAlertQueryLimit = 100
+	maxAlertQueryLimit     = 1000
+)
+
+var alertsBucket = []byte("alerts")
+
+// StoredAlert is an alert kept in the local alert store.
+type StoredAlert struct {
+	ID          string                `json:"id"`
+	AlertID     string                `json:"alertId"`
+	AgentID     string                `json:"agentId"`
+	BlockNumber uint64                `json:"blockNumber"`
+	BlockHash   string                `json:"blockHash,omitempty"`
+	TxHash      string                `json:"txHash,omitempty"`
+	Severity    string                `json:"severity"`
+	BatchRef    string                `json:"batchRef,omitempty"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	Orphaned    bool                  `json:"orphaned,omitempty"`
+	SignedAlert *protocol.SignedAlert `json:"signedAlert"`
+}
+
+// NewStoredAlert creates a stored alert from the signed alert and the context it was published in.
+func NewStoredAlert(signedAlert *protocol.SignedAlert, blockHash, txHash, batchRef string) *StoredAlert {
+	alert := signedAlert.Alert
+	storedAlert := &StoredAlert{
+		ID:          alert.Id,
+		BlockHash:   blockHash,
+		TxHash:      txHash,
+		BatchRef:    batchRef,
+		SignedAlert: signedAlert,
+	}
+	if alert.Agent != nil {
+		storedAlert.AgentID = alert.Agent.Id
+	}
+	if alert.Finding != nil {
+		storedAlert.AlertID = alert.Finding.AlertId
+		storedAlert.Severity = alert.Finding.Severity.String()
+	}
+	storedAlert.BlockNumber, _ = hexutil.DecodeUint64(signedAlert.BlockNumber)
+	storedAlert.CreatedAt, _ = time.Parse(time.RFC3339, alert.Timestamp)
+	if storedAlert.CreatedAt.IsZero() {
+		storedAlert.CreatedAt = time.Now().UTC()
+	}
+	return storedAlert
+}
+
+// AlertQuery filters the stored alerts. Zero values do not filter.
+type AlertQuery struct {
+	From       time.Time
+	To         time.Time
+	AgentID    string
+	Severity   string
+	BlockStart uint64
+	BlockEnd   uint64
+	ID         string
+	AlertID    string
+	Limit      int
+}
+
+func (q *AlertQuery) matches(alert *StoredAlert) bool {
+	if len(q.AgentID) > 0 && q.AgentID != alert.AgentID {
+		return false
+	}
+	if len(q.Severity) > 0 && q.Severity != alert.Severity {
+		return false
+	}
+	if q.BlockStart > 0 && alert.BlockNumber < q.BlockStart {
+		return false
+	}
+	if q.BlockEnd > 0 && alert.BlockNumber > q.BlockEnd {
+		return false
+	}
+	if len(q.ID) > 0 && q.ID != alert.ID {
+		return false
+	}
+	if len(q.AlertID) > 0 && q.AlertID != alert.AlertID {
+		return false
+	}
+	return true
+}
+
+// AlertStore keeps the alerts locally so they can be queried later.
+type AlertStore interface {
+	Put(alerts ...*StoredAlert) error
+	Query(q *AlertQuery) ([]*StoredAlert, error)
+	Prune(olderThan time.Time) (int, error)
+	MarkOrphaned(blockHashes ...string) (int, error)
+	Close() error
+}
+
+type boltAlertStore struct {
+	db *bolt.DB
+}
+
+// NewBoltAlertStore creates a new alert store backed by a bbolt database at the given path.
+func NewBoltAlertStore(filePath string) (*boltAlertStore, error) {
+	db, err := bolt.Open(filePath, 0644, &bolt.Options{Timeout: time.Second * 5})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open alert store: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(alertsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create alerts bucket: %v", err)
+	}
+	return &boltAlertStore{db: db}, nil
+}
+
+// alertKey makes the keys sort by the creation time.
+func alertKey(t time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return append(key, id...)
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// Put stores the alerts.
+func (store *boltAlertStore) Put(alerts ...*StoredAlert) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(alertsBucket)
+		for _, alert := range alerts {
+			b, err := json.Marshal(alert)
+			if err != nil {
+				return fmt.Errorf("failed to encode alert: %v", err)
+			}
+			if err := bucket.Put(alertKey(alert.CreatedAt, alert.ID), b); err != nil {
+				return fmt.Errorf("failed to put alert: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Query returns the alerts which match the query, from the newest to the oldest.
+func (store *boltAlertStore) Query(q *AlertQuery) ([]*StoredAlert, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultAlertQueryLimit
+	}
+	if limit > maxAlertQueryLimit {
+		limit = maxAlertQueryLimit
+	}
+
+	var alerts []*StoredAlert
+	err := store.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(alertsBucket).Cursor()
+
+		var k, v []byte
+		if q.To.IsZero() {
+			k, v = c.Last()
+		} else {
+			// seek to the first key after the range and step back
+			k, v = c.Seek(timeKey(q.To.Add(time.Nanosecond)))
+			if k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+
+		var fromKey []byte
+		if !q.From.IsZero() {
+			fromKey = timeKey(q.From)
+		}
+
+		for ; k != nil && len(alerts) < limit; k, v = c.Prev() {
+			if fromKey != nil && bytes.Compare(k, fromKey) < 0 {
+				break
+			}
+			var alert StoredAlert
+			if err := json.Unmarshal(v, &alert); err != nil {
+				return fmt.Errorf("failed to decode alert: %v", err)
+			}
+			if q.matches(&alert) {
+				alerts = append(alerts, &alert)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// Prune deletes the alerts created before the given time.
+func (store *boltAlertStore) Prune(olderThan time.Time) (int, error) {
+	var count int
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(alertsBucket)
+		c := bucket.Cursor()
+		end := timeKey(olderThan)
+		// collect first: deleting while iterating makes the cursor skip keys
+		var keys [][]byte
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		count = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune alerts: %v", err)
+	}
+	return count, nil
+}
+
+// MarkOrphaned marks the alerts from the given blocks as orphaned after a chain reorg.
+// This only changes the local copies, the alerts that were sent in a batch stay published.
+func (store *boltAlertStore) MarkOrphaned(blockHashes ...string) (int, error) {
+	orphaned := make(map[string]bool)
+	for _, blockHash := range blockHashes {
+		orphaned[blockHash] = true
+	}
+	var count int
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(alertsBucket)
+		// collect first: updating while iterating is not safe
+		updates := make(map[string][]byte)
+		err := bucket.ForEach(func(k, v []byte) error {
+			var alert StoredAlert
+			if err := json.Unmarshal(v, &alert); err != nil {
+				return fmt.Errorf("failed to decode alert: %v", err)
+			}
+			if alert.Orphaned || !orphaned[alert.BlockHash] {
+				return nil
+			}
+			alert.Orphaned = true
+			b, err := json.Marshal(&alert)
+			if err != nil {
+				return fmt.Errorf("failed to encode alert: %v", err)
+			}
+			updates[string(k)] = b
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, v := range updates {
+			if err := bucket.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		count = len(updates)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark orphaned alerts: %v", err)
+	}
+	return count, nil
+}
+
+// Close closes the store.
+func (store *boltAlertStore) Close() error {
+	return store.db.Close()
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func testStoredAlert(id, agentID string, severity protocol.Finding_Severity, blockNumber string, createdAt time.Time) *StoredAlert {
+	return NewStoredAlert(&protocol.SignedAlert{
+		Alert: &protocol.Alert{
+			Id:        id,
+			Timestamp: createdAt.Format(time.RFC3339),
+			Agent:     &protocol.AgentInfo{Id: agentID},
+			Finding:   &protocol.Finding{AlertId: "TEST-1", Severity: severity},
+		},
+		BlockNumber: blockNumber,
+	}, "hash-"+blockNumber, "0xtx", "batch-ref")
+}
+
+func TestBoltAlertStore(t *testing.T) {
+	r := require.New(t)
+
+	dir, err := ioutil.TempDir("", "alerts")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	alertStore, err := NewBoltAlertStore(path.Join(dir, "alerts.db"))
+	r.NoError(err)
+	defer alertStore.Close()
+
+	t0 := time.Now().Truncate(time.Second).UTC()
+	r.NoError(alertStore.Put(
+		testStoredAlert("alert1", "agent1", protocol.Finding_LOW, "0x1", t0),
+		testStoredAlert("alert2", "agent2", protocol.Finding_HIGH, "0x2", t0.Add(time.Second)),
+		testStoredAlert("alert3", "agent1", protocol.Finding_HIGH, "0x3", t0.Add(time.Second*2)),
+	))
+
+	alerts, err := alertStore.Query(&AlertQuery{})
+	r.NoError(err)
+	r.Len(alerts, 3)
+	r.Equal("alert3", alerts[0].ID)
+	r.Equal("0xtx", alerts[0].TxHash)
+	r.Equal("batch-ref", alerts[0].BatchRef)
+	r.Equal(uint64(3), alerts[0].BlockNumber)
+
+	alerts, err = alertStore.Query(&AlertQuery{AgentID: "agent1", Severity: "HIGH"})
+	r.NoError(err)
+	r.Len(alerts, 1)
+	r.Equal("alert3", alerts[0].ID)
+
+	alerts, err = alertStore.Query(&AlertQuery{From: t0.Add(time.Second), To: t0.Add(time.Second)})
+	r.NoError(err)
+	r.Len(alerts, 1)
+	r.Equal("alert2", alerts[0].ID)
+
+	alerts, err = alertStore.Query(&AlertQuery{BlockStart: 1, BlockEnd: 2, Limit: 1})
+	r.NoError(err)
+	r.Len(alerts, 1)
+	r.Equal("alert2", alerts[0].ID)
+
+	count, err := alertStore.MarkOrphaned("hash-0x2")
+	r.NoError(err)
+	r.Equal(1, count)
+	alerts, err = alertStore.Query(&AlertQuery{ID: "alert2"})
+	r.NoError(err)
+	r.Len(alerts, 1)
+	r.True(alerts[0].Orphaned)
+
+	count, err = alertStore.Prune(t0.Add(time.Second * 2))
+	r.NoError(err)
+	r.Equal(2, count)
+
+	alerts, err = alertStore.Query(&AlertQuery{})
+	r.NoError(err)
+	r.Len(alerts, 1)
+	r.Equal("alert3", alerts[0].ID)
+}
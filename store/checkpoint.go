@@ -0,0 +1,50 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Checkpoint is the last block that the scanner fully dispatched to the agents.
+type Checkpoint struct {
+	BlockNumber uint64    `json:"blockNumber"`
+	BlockHash   string    `json:"blockHash"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// ReadCheckpoint reads the checkpoint from the file. It returns nil if there is no checkpoint yet.
+func ReadCheckpoint(filePath string) (*Checkpoint, error) {
+	b, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(b, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %v", err)
+	}
+	return &checkpoint, nil
+}
+
+// WriteCheckpoint replaces the checkpoint file.
+func WriteCheckpoint(filePath string, checkpoint *Checkpoint) error {
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %v", err)
+	}
+	// write and rename so the checkpoint is never half-written
+	tmpPath := filePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, b, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("failed to replace checkpoint: %v", err)
+	}
+	return nil
+}
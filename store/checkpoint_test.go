@@ -0,0 +1,24 @@
+package store
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	r := require.New(t)
+
+	filePath := path.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := ReadCheckpoint(filePath)
+	r.NoError(err)
+	r.Nil(checkpoint)
+
+	written := &Checkpoint{BlockNumber: 123, BlockHash: "0x123", UpdatedAt: time.Now().UTC().Truncate(time.Second)}
+	r.NoError(WriteCheckpoint(filePath, written))
+	checkpoint, err = ReadCheckpoint(filePath)
+	r.NoError(err)
+	r.Equal(written, checkpoint)
+}
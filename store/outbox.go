@@ -0,0 +1,197 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	outboxItemExt       = ".json"
+	outboxDeadLetterDir = "dead-letter"
+)
+
+// OutboxItem is a signed batch which is waiting to be delivered.
+type OutboxItem struct {
+	ID          string                  `json:"-"`
+	CreatedAt   time.Time               `json:"createdAt"`
+	Ref         string                  `json:"ref"`
+	SignedBatch *protocol.SignedPayload `json:"signedBatch"`
+}
+
+// BatchOutbox keeps the signed batches on disk until they are delivered.
+type BatchOutbox interface {
+	Put(item *OutboxItem) error
+	Peek() (*OutboxItem, bool, error)
+	Remove(item *OutboxItem) error
+	DeadLetter(item *OutboxItem) error
+	Stats() (count int, oldest time.Time)
+}
+
+type fileBatchOutbox struct {
+	dir   string
+	seq   uint64
+	items []string // sorted item IDs
+	times map[string]time.Time
+	mu    sync.Mutex
+}
+
+// NewFileBatchOutbox creates a new outbox which stores each item as a file in the given dir.
+// The items that were left from previous runs are loaded in the order they were put.
+func NewFileBatchOutbox(dir string) (*fileBatchOutbox, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox dir: %v", err)
+	}
+	outbox := &fileBatchOutbox{
+		dir:   dir,
+		times: make(map[string]time.Time),
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox dir: %v", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, outboxItemExt) {
+			continue
+		}
+		item, err := outbox.read(strings.TrimSuffix(name, outboxItemExt))
+		if err != nil {
+			log.WithError(err).WithField("file", name).Warn("skipping unreadable outbox item")
+			continue
+		}
+		outbox.items = append(outbox.items, item.ID)
+		outbox.times[item.ID] = item.CreatedAt
+	}
+	sort.Strings(outbox.items)
+	return outbox, nil
+}
+
+// Put writes the item to the end of the outbox.
+func (outbox *fileBatchOutbox) Put(item *OutboxItem) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now().UTC()
+	}
+	outbox.seq++
+	// zero-padded so that the lexical order of the file names is the insertion order
+	item.ID = fmt.Sprintf("%020d-%06d", item.CreatedAt.UnixNano(), outbox.seq%1000000)
+
+	b, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox item: %v", err)
+	}
+	// write to a temp file first so a crash can never leave a partial item behind
+	tmpPath := path.Join(outbox.dir, item.ID+".tmp")
+	if err := ioutil.WriteFile(tmpPath, b, 0644); err != nil {
+		return fmt.Errorf("failed to write outbox item: %v", err)
+	}
+	if err := os.Rename(tmpPath, outbox.itemPath(item.ID)); err != nil {
+		return fmt.Errorf("failed to move outbox item: %v", err)
+	}
+	outbox.items = append(outbox.items, item.ID)
+	outbox.times[item.ID] = item.CreatedAt
+	return nil
+}
+
+// Peek returns the oldest item in the outbox. The items which cannot be read anymore
+// are moved to the dead-letter dir so that they do not block the rest.
+func (outbox *fileBatchOutbox) Peek() (*OutboxItem, bool, error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	for len(outbox.items) > 0 {
+		id := outbox.items[0]
+		item, err := outbox.read(id)
+		if err == nil {
+			return item, true, nil
+		}
+		log.WithError(err).WithField("id", id).Error("moving unreadable outbox item to dead-letter dir")
+		if err := outbox.deadLetterUnsafe(id); err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil
+}
+
+// Remove deletes the item from the outbox.
+func (outbox *fileBatchOutbox) Remove(item *OutboxItem) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	if err := os.Remove(outbox.itemPath(item.ID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove outbox item: %v", err)
+	}
+	outbox.removeUnsafe(item.ID)
+	return nil
+}
+
+// DeadLetter moves the item which can never be delivered out of the outbox. It is kept in
+// the dead-letter dir for inspection.
+func (outbox *fileBatchOutbox) DeadLetter(item *OutboxItem) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	return outbox.deadLetterUnsafe(item.ID)
+}
+
+func (outbox *fileBatchOutbox) deadLetterUnsafe(id string) error {
+	deadLetterDir := path.Join(outbox.dir, outboxDeadLetterDir)
+	if err := os.MkdirAll(deadLetterDir, 0755); err != nil {
+		return fmt.Errorf("failed to create dead-letter dir: %v", err)
+	}
+	if err := os.Rename(outbox.itemPath(id), path.Join(deadLetterDir, id+outboxItemExt)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to move outbox item to dead-letter dir: %v", err)
+	}
+	outbox.removeUnsafe(id)
+	return nil
+}
+
+func (outbox *fileBatchOutbox) removeUnsafe(id string) {
+	for i, itemID := range outbox.items {
+		if itemID == id {
+			outbox.items = append(outbox.items[:i], outbox.items[i+1:]...)
+			break
+		}
+	}
+	delete(outbox.times, id)
+}
+
+// Stats returns the number of items and the creation time of the oldest item.
+func (outbox *fileBatchOutbox) Stats() (count int, oldest time.Time) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	if len(outbox.items) == 0 {
+		return 0, time.Time{}
+	}
+	return len(outbox.items), outbox.times[outbox.items[0]]
+}
+
+func (outbox *fileBatchOutbox) read(id string) (*OutboxItem, error) {
+	b, err := ioutil.ReadFile(outbox.itemPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox item: %v", err)
+	}
+	var item OutboxItem
+	if err := json.Unmarshal(b, &item); err != nil {
+		return nil, fmt.Errorf("failed to decode outbox item '%s': %v", id, err)
+	}
+	item.ID = id
+	return &item, nil
+}
+
+func (outbox *fileBatchOutbox) itemPath(id string) string {
+	return path.Join(outbox.dir, id+outboxItemExt)
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/forta-network/forta-core-go/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBatchOutbox(t *testing.T) {
+	r := require.New(t)
+
+	dir, err := ioutil.TempDir("", "outbox")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	outbox, err := NewFileBatchOutbox(dir)
+	r.NoError(err)
+
+	_, ok, err := outbox.Peek()
+	r.NoError(err)
+	r.False(ok)
+
+	item1 := &OutboxItem{Ref: "ref1", SignedBatch: &protocol.SignedPayload{Encoded: "batch1"}}
+	item2 := &OutboxItem{Ref: "ref2", SignedBatch: &protocol.SignedPayload{Encoded: "batch2"}}
+	r.NoError(outbox.Put(item1))
+	r.NoError(outbox.Put(item2))
+
+	count, oldest := outbox.Stats()
+	r.Equal(2, count)
+	r.True(oldest.Equal(item1.CreatedAt))
+
+	// reopen and expect the same items in the same order
+	outbox, err = NewFileBatchOutbox(dir)
+	r.NoError(err)
+
+	item, ok, err := outbox.Peek()
+	r.NoError(err)
+	r.True(ok)
+	r.Equal("ref1", item.Ref)
+	r.Equal("batch1", item.SignedBatch.Encoded)
+
+	r.NoError(outbox.Remove(item))
+	item, ok, err = outbox.Peek()
+	r.NoError(err)
+	r.True(ok)
+	r.Equal("ref2", item.Ref)
+
+	r.NoError(outbox.Remove(item))
+	count, _ = outbox.Stats()
+	r.Equal(0, count)
+}
+
+func TestFileBatchOutbox_DeadLetter(t *testing.T) {
+	r := require.New(t)
+
+	dir, err := ioutil.TempDir("", "outbox")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	outbox, err := NewFileBatchOutbox(dir)
+	r.NoError(err)
+
+	item1 := &OutboxItem{Ref: "ref1", SignedBatch: &protocol.SignedPayload{Encoded: "batch1"}}
+	item2 := &OutboxItem{Ref: "ref2", SignedBatch: &protocol.SignedPayload{Encoded: "batch2"}}
+	item3 := &OutboxItem{Ref: "ref3", SignedBatch: &protocol.SignedPayload{Encoded: "batch3"}}
+	r.NoError(outbox.Put(item1))
+	r.NoError(outbox.Put(item2))
+	r.NoError(outbox.Put(item3))
+
+	// the item which can never be sent is moved aside
+	r.NoError(outbox.DeadLetter(item1))
+	_, err = os.Stat(path.Join(dir, outboxDeadLetterDir, item1.ID+outboxItemExt))
+	r.NoError(err)
+
+	// the item which cannot be read anymore does not block the next one
+	r.NoError(ioutil.WriteFile(outbox.itemPath(item2.ID), []byte("{"), 0644))
+	item, ok, err := outbox.Peek()
+	r.NoError(err)
+	r.True(ok)
+	r.Equal("ref3", item.Ref)
+
+	count, _ := outbox.Stats()
+	r.Equal(1, count)
+}
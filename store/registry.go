@@ -11,6 +11,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/forta-network/forta-core-go/ethereum"
+	"github.com/forta-network/forta-core-go/ipfs"
 	"github.com/forta-network/forta-core-go/manifest"
 	"github.com/forta-network/forta-core-go/registry"
 	"github.com/forta-network/forta-core-go/utils"
@@ -22,9 +23,26 @@ type RegistryStore interface {
 	GetAgentsIfChanged(scanner string) ([]*config.AgentConfig, bool, error)
 }
 
+// signedAgentManifest extends the agent manifest with the fields that only the node reads.
+type signedAgentManifest struct {
+	Manifest *agentManifest `json:"manifest"`
+}
+
+type agentManifest struct {
+	manifest.AgentManifest
+	Filters *config.TxFilters `json:"filters"`
+	// PendingTransactions is how an agent opts in to the pending transactions.
+	PendingTransactions bool `json:"pendingTransactions"`
+	// AlertSubscriptions are the alerts of the other agents that the agent wants to evaluate.
+	AlertSubscriptions []config.AlertSubscription `json:"alertSubscriptions"`
+	// TimeoutSeconds and MaxFindings are kept within the node-wide bounds.
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	MaxFindings    int `json:"maxFindings"`
+}
+
 type registryStore struct {
 	ctx context.Context
-	mc  manifest.Client
+	ic  ipfs.Client
 	rc  registry.Client
 	cfg config.Config
 
@@ -99,11 +117,11 @@ func (rs *registryStore) makeAgentConfig(agentID string, ref string) (*config.Ag
 	if len(ref) == 0 {
 		return nil, nil
 	}
-	var agentData *manifest.SignedAgentManifest
+	var agentData signedAgentManifest
 
 	var err error
 	for i := 0; i < 10; i++ {
-		agentData, err = rs.mc.GetAgentManifest(rs.ctx, ref)
+		err = rs.ic.UnmarshalJson(rs.ctx, ref, &agentData)
 		if err == nil {
 			break
 		}
@@ -113,7 +131,7 @@ func (rs *registryStore) makeAgentConfig(agentID string, ref string) (*config.Ag
 		return nil, err
 	}
 
-	if agentData.Manifest.ImageReference == nil {
+	if agentData.Manifest == nil || agentData.Manifest.ImageReference == nil {
 		return nil, fmt.Errorf("invalid agent image reference, it is nil")
 	}
 
@@ -123,14 +141,20 @@ func (rs *registryStore) makeAgentConfig(agentID string, ref string) (*config.Ag
 	}
 
 	return &config.AgentConfig{
-		ID:       agentID,
-		Image:    image,
-		Manifest: ref,
+		ID:         agentID,
+		Image:      image,
+		Manifest:   ref,
+		Filters:    agentData.Manifest.Filters,
+		PendingTxs: agentData.Manifest.PendingTransactions,
+
+		AlertSubscriptions: agentData.Manifest.AlertSubscriptions,
+		TimeoutSeconds:     agentData.Manifest.TimeoutSeconds,
+		MaxFindings:        agentData.Manifest.MaxFindings,
 	}, nil
 }
 
 func NewRegistryStore(ctx context.Context, cfg config.Config, ethClient ethereum.Client) (*registryStore, error) {
-	mc, err := manifest.NewClient(cfg.Registry.IPFS.GatewayURL)
+	ic, err := ipfs.NewClient(cfg.Registry.IPFS.GatewayURL)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +171,7 @@ func NewRegistryStore(ctx context.Context, cfg config.Config, ethClient ethereum
 	return &registryStore{
 		ctx: ctx,
 		cfg: cfg,
-		mc:  mc,
+		ic:  ic,
 		rc:  rc,
 	}, nil
 }
@@ -180,9 +204,15 @@ func (rs *privateRegistryStore) FindAgentGlobally(agentID string) (*config.Agent
 
 func (rs *privateRegistryStore) makePrivateModeAgentConfig(id string, image string) *config.AgentConfig {
 	return &config.AgentConfig{
-		ID:      id,
-		Image:   image,
-		IsLocal: true,
+		ID:         id,
+		Image:      image,
+		IsLocal:    true,
+		Filters:    rs.cfg.PrivateModeConfig.AgentFilters[image],
+		PendingTxs: rs.cfg.PrivateModeConfig.PendingTxAgents[image],
+
+		AlertSubscriptions: rs.cfg.PrivateModeConfig.AgentAlertSubscriptions[image],
+		TimeoutSeconds:     rs.cfg.PrivateModeConfig.AgentTimeoutSeconds[image],
+		MaxFindings:        rs.cfg.PrivateModeConfig.AgentMaxFindings[image],
 	}
 }
 